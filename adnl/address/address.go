@@ -1,8 +1,12 @@
 package address
 
 import (
-	"github.com/chaindead/tonutils-go/tl"
+	"encoding/binary"
+	"fmt"
 	"net"
+	"strconv"
+
+	"github.com/chaindead/tonutils-go/tl"
 )
 
 func init() {
@@ -10,15 +14,114 @@ func init() {
 	tl.Register(List{}, "adnl.addressList addrs:(vector adnl.Address) version:int reinit_date:int priority:int expire_at:int = adnl.AddressList")
 }
 
+var _UDPID = tl.CRC("adnl.address.udp ip:int port:int = adnl.Address")
+var _UDP6ID = tl.CRC("adnl.address.udp6 ip:int128 port:int = adnl.Address")
+
+const _MaxAddresses = 64
+
+// UDP - udp endpoint, it is serialized as adnl.address.udp6 when IP is not IPv4
 type UDP struct {
 	IP   net.IP `tl:"int"`
 	Port int32  `tl:"int"`
 }
 
+// List - address list, addresses are ordered by priority, the first one should be tried first
 type List struct {
-	Addresses  []*UDP `tl:"vector struct boxed"`
-	Version    int32  `tl:"int"`
-	ReinitDate int32  `tl:"int"`
-	Priority   int32  `tl:"int"`
-	ExpireAt   int32  `tl:"int"`
+	Addresses  []*UDP
+	Version    int32
+	ReinitDate int32
+	Priority   int32
+	ExpireAt   int32
+}
+
+// String - returns address in host:port format, suitable for both IPv4 and IPv6
+func (u *UDP) String() string {
+	return net.JoinHostPort(u.IP.String(), strconv.Itoa(int(u.Port)))
+}
+
+// IsIPv6 - true when address should be serialized as adnl.address.udp6
+func (u *UDP) IsIPv6() bool {
+	return u.IP.To4() == nil
+}
+
+func (l *List) Parse(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("too short data for addresses vector")
+	}
+	num := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	if num > _MaxAddresses {
+		return nil, fmt.Errorf("too many addresses in list: %d", num)
+	}
+
+	l.Addresses = make([]*UDP, 0, num)
+	for i := uint32(0); i < num; i++ {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("too short data for address %d", i)
+		}
+
+		var err error
+		var addr UDP
+		switch binary.LittleEndian.Uint32(data) {
+		case _UDPID:
+			data, err = tl.Parse(&addr, data, true)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse udp address %d: %w", i, err)
+			}
+		case _UDP6ID:
+			if len(data) < 4+16+4 {
+				return nil, fmt.Errorf("too short data for udp6 address %d", i)
+			}
+			addr.IP = append(net.IP{}, data[4:20]...)
+			addr.Port = int32(binary.LittleEndian.Uint32(data[20:]))
+			data = data[24:]
+		default:
+			return nil, fmt.Errorf("unsupported address type %x", data[:4])
+		}
+		l.Addresses = append(l.Addresses, &addr)
+	}
+
+	if len(data) < 16 {
+		return nil, fmt.Errorf("too short data for address list")
+	}
+	l.Version = int32(binary.LittleEndian.Uint32(data))
+	l.ReinitDate = int32(binary.LittleEndian.Uint32(data[4:]))
+	l.Priority = int32(binary.LittleEndian.Uint32(data[8:]))
+	l.ExpireAt = int32(binary.LittleEndian.Uint32(data[12:]))
+	return data[16:], nil
+}
+
+func (l *List) Serialize() ([]byte, error) {
+	buf := appendUint32(nil, uint32(len(l.Addresses)))
+	for i, addr := range l.Addresses {
+		if ip4 := addr.IP.To4(); ip4 != nil {
+			data, err := tl.Serialize(UDP{IP: ip4, Port: addr.Port}, true)
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize udp address %d: %w", i, err)
+			}
+			buf = append(buf, data...)
+			continue
+		}
+
+		ip6 := addr.IP.To16()
+		if ip6 == nil {
+			return nil, fmt.Errorf("invalid ip of address %d", i)
+		}
+		buf = appendUint32(buf, _UDP6ID)
+		buf = append(buf, ip6...)
+		buf = appendUint32(buf, uint32(addr.Port))
+	}
+
+	buf = appendUint32(buf, uint32(l.Version))
+	buf = appendUint32(buf, uint32(l.ReinitDate))
+	buf = appendUint32(buf, uint32(l.Priority))
+	buf = appendUint32(buf, uint32(l.ExpireAt))
+	return buf, nil
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	tmp := make([]byte, 4)
+	binary.LittleEndian.PutUint32(tmp, v)
+	return append(buf, tmp...)
 }
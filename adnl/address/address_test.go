@@ -0,0 +1,61 @@
+package address
+
+import (
+	"net"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+func TestList_Serialize(t *testing.T) {
+	list := List{
+		Addresses: []*UDP{
+			{IP: net.ParseIP("2001:db8::1"), Port: 30303},
+			{IP: net.ParseIP("1.2.3.4"), Port: 17555},
+		},
+		Version:    1,
+		ReinitDate: 2,
+		Priority:   3,
+		ExpireAt:   4,
+	}
+
+	data, err := tl.Serialize(list, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// boxed id, vector len, udp6, udp, 4 ints
+	if len(data) != 4+4+24+12+16 {
+		t.Fatal("incorrect serialized size", len(data))
+	}
+
+	var parsed List
+	if _, err = tl.Parse(&parsed, data, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.Addresses) != 2 || parsed.Version != 1 || parsed.ReinitDate != 2 || parsed.Priority != 3 || parsed.ExpireAt != 4 {
+		t.Fatal("incorrect parsed list")
+	}
+	if !parsed.Addresses[0].IsIPv6() || parsed.Addresses[0].String() != "[2001:db8::1]:30303" {
+		t.Fatal("incorrect ipv6 address", parsed.Addresses[0].String())
+	}
+	if parsed.Addresses[1].IsIPv6() || parsed.Addresses[1].String() != "1.2.3.4:17555" {
+		t.Fatal("incorrect ipv4 address", parsed.Addresses[1].String())
+	}
+
+	// ipv4 only list should stay compatible with plain adnl.address.udp
+	v4 := List{Addresses: []*UDP{{IP: net.IPv4(1, 2, 3, 4).To4(), Port: 1}}}
+	data, err = tl.Serialize(&v4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	udp, err := tl.Serialize(UDP{IP: net.IPv4(1, 2, 3, 4).To4(), Port: 1}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data[4:4+len(udp)]) != string(udp) {
+		t.Fatal("incorrect ipv4 serialization")
+	}
+}
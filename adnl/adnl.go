@@ -68,11 +68,14 @@ type ADNL struct {
 	ourAddresses address.List
 
 	activeQueries map[string]chan tl.Serializable
+	pongs         map[int64]chan bool
 
 	customMessageHandler CustomMessageHandler
 	queryHandler         QueryHandler
 	onDisconnect         DisconnectHandler
 	onChannel            func(ch *Channel)
+	// internalHandler - processes service messages of the gateway, like relay ones, before user handlers
+	internalHandler func(msg any) (handled bool, err error)
 
 	lastReceiveAt time.Time
 
@@ -94,6 +97,7 @@ func initADNL(key ed25519.PrivateKey) *ADNL {
 
 		msgParts:      map[string]*partitionedMessage{},
 		activeQueries: map[string]chan tl.Serializable{},
+		pongs:         map[int64]chan bool{},
 	}
 }
 
@@ -214,9 +218,24 @@ func (a *ADNL) processPacket(packet *PacketContent, ch *Channel) (err error) {
 }
 
 func (a *ADNL) processMessage(message any, ch *Channel) error {
+	if h := a.internalHandler; h != nil {
+		if handled, err := h(message); handled {
+			return err
+		}
+	}
+
 	switch ms := message.(type) {
 	case MessagePong:
-		// TODO: record
+		a.mx.RLock()
+		waiter := a.pongs[ms.Value]
+		a.mx.RUnlock()
+
+		if waiter != nil {
+			select {
+			case waiter <- true:
+			default:
+			}
+		}
 	case MessagePing:
 		buf, err := a.buildRequest(ch, MessagePong{Value: ms.Value})
 		if err != nil {
@@ -466,6 +485,22 @@ func (a *ADNL) query(ctx context.Context, ch *Channel, req, result tl.Serializab
 	}
 }
 
+// expectPong - returns channel which receives signal when pong with the given value arrives,
+// returned function should be called to stop waiting
+func (a *ADNL) expectPong(value int64) (<-chan bool, func()) {
+	ch := make(chan bool, 1)
+
+	a.mx.Lock()
+	a.pongs[value] = ch
+	a.mx.Unlock()
+
+	return ch, func() {
+		a.mx.Lock()
+		delete(a.pongs, value)
+		a.mx.Unlock()
+	}
+}
+
 func (a *ADNL) Answer(ctx context.Context, queryID []byte, result tl.Serializable) error {
 	packets, err := a.buildRequestMaySplit(nil, &MessageAnswer{
 		ID:   queryID,
@@ -298,3 +298,136 @@ func connHandler(client Peer) error {
 	})
 	return nil
 }
+
+func TestGateway_RegisterClientAny(t *testing.T) {
+	bPub, bPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, aPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewGateway(bPriv)
+	err = b.StartServer("127.0.0.1:9075")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	b.SetConnectionHandler(connHandler)
+
+	a := NewGateway(aPriv)
+	err = a.StartClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// first address is dead, second should be chosen
+	p, err := a.RegisterClientAny(ctx, []string{"127.0.0.1:9076", "127.0.0.1:9075"}, bPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res MessagePong
+	err = p.Query(ctx, &MessagePing{7755}, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value != 7755 {
+		t.Fatal("value not eq")
+	}
+
+	_, cPriv, _ := ed25519.GenerateKey(nil)
+	c := NewGateway(cPriv)
+	defer c.Close()
+
+	if err = c.StartServer("[::1]:9085"); err != nil {
+		t.Skip("ipv6 is not available:", err)
+	}
+
+	list := c.GetAddressList()
+	if len(list.Addresses) != 1 || !list.Addresses[0].IsIPv6() || list.Addresses[0].String() != "[::1]:9085" {
+		t.Fatal("incorrect ipv6 address list")
+	}
+}
+
+func TestGateway_Relay(t *testing.T) {
+	rPub, rPriv, _ := ed25519.GenerateKey(nil)
+	nPub, nPriv, _ := ed25519.GenerateKey(nil)
+	_, cPriv, _ := ed25519.GenerateKey(nil)
+
+	r := NewGateway(rPriv)
+	r.EnableRelay(10)
+	if err := r.StartServer("127.0.0.1:9095"); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// node behind nat, reachable only through relay
+	n := NewGateway(nPriv)
+	n.SetConnectionHandler(connHandler)
+	if err := n.StartClient(); err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := n.ConnectRelay(ctx, "127.0.0.1:9095", rPub); err != nil {
+		t.Fatal(err)
+	}
+
+	list := n.GetAddressList()
+	if len(list.Addresses) != 1 || list.Addresses[0].String() != "127.0.0.1:9095" {
+		t.Fatal("relay address should be announced")
+	}
+
+	c := NewGateway(cPriv)
+	if err := c.StartClient(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	p, err := c.RegisterClient(list.Addresses[0].String(), nPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotCustom := make(chan bool, 1)
+	p.SetCustomMessageHandler(func(msg *MessageCustom) error {
+		if m, ok := msg.Data.(TestMsg); ok && len(m.Data) == 1280 {
+			gotCustom <- true
+		}
+		return nil
+	})
+
+	// several queries, to also pass channel packets through relay
+	for i := int64(0); i < 5; i++ {
+		var res MessagePong
+		if err = p.Query(ctx, &MessagePing{100 + i}, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Value != 100+i {
+			t.Fatal("value not eq")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// big answer is split into parts
+	if err = p.SendCustomMessage(ctx, TestMsg{Data: []byte{1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-gotCustom:
+	case <-ctx.Done():
+		t.Fatal("custom message is not received through relay")
+	}
+}
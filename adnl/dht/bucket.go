@@ -3,12 +3,19 @@ package dht
 import (
 	"sort"
 	"sync"
+	"time"
 )
 
 type Bucket struct {
 	k     uint
 	nodes dhtNodeList
-	mx    sync.RWMutex
+	// replacements - nodes which wait for a free place, when bucket is full
+	replacements dhtNodeList
+	// checking - worst node is pinged before its eviction
+	checking *dhtNode
+
+	lastRefreshAt time.Time
+	mx            sync.RWMutex
 }
 
 func newBucket(k uint) *Bucket {
@@ -39,24 +46,74 @@ func (b *Bucket) getNode(id string) *dhtNode {
 	return nil
 }
 
-func (b *Bucket) addNode(node *dhtNode) {
+// addNode - adds node to the bucket, when bucket is full node is kept as a replacement,
+// and the worst node is returned, it should be pinged and evicted if it is not alive
+func (b *Bucket) addNode(node *dhtNode) (stored, toCheck *dhtNode) {
 	b.mx.Lock()
 	defer b.mx.Unlock()
-	defer b.sortAndFilter()
 
-	for i, n := range b.nodes {
+	for _, n := range b.nodes {
 		if n != nil && n.id() == node.id() {
-			b.nodes[i] = node
-			return
+			// keep collected ping and score, only refresh signed info
+			n.updateSignedNode(node.signedNode())
+			return n, nil
 		}
 	}
 
-	b.nodes = append(b.nodes, node)
+	if len(b.nodes) < b.capacity() {
+		b.nodes = append(b.nodes, node)
+		sort.Sort(b.nodes)
+		return node, nil
+	}
+
+	b.addReplacement(node)
+
+	if b.checking != nil {
+		// one check at a time
+		return node, nil
+	}
+	b.checking = b.nodes[len(b.nodes)-1]
+	return node, b.checking
+}
+
+// finishCheck - evicts checked node if it is not alive and puts the freshest replacement instead of it
+func (b *Bucket) finishCheck(node *dhtNode, alive bool) (evicted bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.checking == node {
+		b.checking = nil
+	}
+
+	if alive || len(b.replacements) == 0 {
+		return false
+	}
+
+	for i, n := range b.nodes {
+		if n == node {
+			b.nodes[i] = b.replacements[len(b.replacements)-1]
+			b.replacements = b.replacements[:len(b.replacements)-1]
+			sort.Sort(b.nodes)
+			return true
+		}
+	}
+	return false
 }
 
-func (b *Bucket) sortAndFilter() {
-	sort.Sort(b.nodes)
-	if len(b.nodes) > int(b.k*5) {
-		b.nodes = b.nodes[:b.k*5]
+func (b *Bucket) addReplacement(node *dhtNode) {
+	for i, n := range b.replacements {
+		if n.id() == node.id() {
+			b.replacements = append(b.replacements[:i], b.replacements[i+1:]...)
+			break
+		}
+	}
+
+	if len(b.replacements) >= int(b.k) {
+		b.replacements = b.replacements[1:]
 	}
+	b.replacements = append(b.replacements, node)
+}
+
+func (b *Bucket) capacity() int {
+	return int(b.k * 5)
 }
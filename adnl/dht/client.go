@@ -31,16 +31,18 @@ type ADNL interface {
 type Gateway interface {
 	Close() error
 	GetID() []byte
-	RegisterClient(addr string, key ed25519.PublicKey) (adnl.Peer, error)
+	RegisterClientAny(ctx context.Context, addrs []string, key ed25519.PublicKey) (adnl.Peer, error)
 }
 
 type Client struct {
-	knownNodes map[string]*dhtNode // unused, nodes are stored in buckets
-	buckets    [256]*Bucket
-	mx         sync.RWMutex // unused, buckets has its own mutex
+	buckets [256]*Bucket
+	mx      sync.RWMutex // unused, buckets has its own mutex
 
 	gateway Gateway
 
+	evictions uint64
+	refreshes uint64
+
 	globalCtx       context.Context
 	globalCtxCancel func()
 }
@@ -96,9 +98,16 @@ func NewClientFromConfig(gateway Gateway, cfg *liteclient.GlobalConfig) (*Client
 		}
 
 		for _, addr := range node.AddrList.Addrs {
-			ip := make(net.IP, 4)
-			ii := int32(addr.IP)
-			binary.BigEndian.PutUint32(ip, uint32(ii))
+			var ip net.IP
+			switch addr.Type {
+			case "adnl.address.udp6":
+				ip = append(net.IP{}, addr.IP6...)
+			default:
+				ip = make(net.IP, 4)
+				ii := int32(addr.IP)
+				binary.BigEndian.PutUint32(ip, uint32(ii))
+			}
+
 			n.AddrList.Addresses = append(n.AddrList.Addresses, &address.UDP{
 				IP:   ip,
 				Port: int32(addr.Port),
@@ -120,7 +129,6 @@ func NewClient(gateway Gateway, nodes []*Node) (*Client, error) {
 	}
 
 	c := &Client{
-		knownNodes:      map[string]*dhtNode{},
 		buckets:         buckets,
 		globalCtx:       globalCtx,
 		globalCtxCancel: cancel,
@@ -166,13 +174,21 @@ func (c *Client) addNode(node *Node) (_ *dhtNode, err error) {
 		node.AddrList.Addresses = node.AddrList.Addresses[:8]
 	}
 
-	// TODO: maybe use other addresses too
-	addr := node.AddrList.Addresses[0].IP.String() + ":" + fmt.Sprint(node.AddrList.Addresses[0].Port)
+	// addresses are in priority order, they are tried one by one when connecting
+	addrs := make([]string, 0, len(node.AddrList.Addresses))
+	for _, a := range node.AddrList.Addresses {
+		addrs = append(addrs, a.String())
+	}
 
-	kNode := c.connectToNode(kid, addr, pub.Key)
-	bucket.addNode(kNode)
+	kNode := c.connectToNode(kid, addrs, pub.Key)
+	kNode.node = node
 
-	return kNode, nil
+	stored, toCheck := bucket.addNode(kNode)
+	if toCheck != nil {
+		go c.checkEviction(bucket, toCheck)
+	}
+
+	return stored, nil
 }
 
 func (c *Client) FindOverlayNodes(ctx context.Context, overlayKey []byte, continuation ...*Continuation) (*overlay.NodesList, *Continuation, error) {
@@ -321,61 +337,11 @@ func (c *Client) Store(
 		return 0, nil, err
 	}
 
-	checked := map[string]bool{}
+	c.lookupNodes(ctx, keyId)
 	plist := c.buildPriorityList(keyId)
 
 	const activeQueries = 6
 
-	for {
-		currentLen := len(checked)
-
-	chk:
-		for {
-			var wg sync.WaitGroup
-			for i := 0; i < activeQueries; i++ {
-				node, _ := plist.getNode()
-				if node == nil {
-					break chk
-				}
-
-				nodeId := node.id()
-				if checked[nodeId] {
-					continue
-				}
-				checked[nodeId] = true
-
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-
-					Logger("Search nodes", nodeId)
-
-					storeCallCtx, cancel := context.WithTimeout(ctx, queryTimeout)
-					nodes, err := node.findNodes(storeCallCtx, keyId, _K)
-					cancel()
-					if err != nil {
-						return
-					}
-
-					Logger("Adding nodes", len(nodes))
-					for _, n := range nodes {
-						if _, err = c.addNode(n); err != nil {
-							continue
-						}
-					}
-				}()
-			}
-			wg.Wait()
-		}
-		plist = c.buildPriorityList(keyId)
-		if len(checked) == currentLen {
-			Logger("S list stops growing:", len(checked))
-			break
-		} else {
-			Logger("K iteration ends. Current size:", len(checked))
-		}
-	}
-
 	stored := int32(0)
 
 	for {
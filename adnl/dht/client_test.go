@@ -44,8 +44,8 @@ func (m *MockGateway) StartServer(listenAddr string) error {
 	return nil
 }
 
-func (m *MockGateway) RegisterClient(addr string, key ed25519.PublicKey) (adnl.Peer, error) {
-	return m.reg(addr, key)
+func (m *MockGateway) RegisterClientAny(ctx context.Context, addrs []string, key ed25519.PublicKey) (adnl.Peer, error) {
+	return m.reg(addrs[0], key)
 }
 
 type MockADNL struct {
@@ -111,9 +111,9 @@ var cnf = &liteclient.GlobalConfig{
 						Type: "adnl.addressList",
 						Addrs: []liteclient.DHTAddress{
 							{
-								"adnl.address.udp",
-								-1185526007,
-								22096,
+								Type: "adnl.address.udp",
+								IP:   -1185526007,
+								Port: 22096,
 							},
 						}},
 					Version:   -1,
@@ -128,9 +128,9 @@ var cnf = &liteclient.GlobalConfig{
 						Type: "adnl.addressList",
 						Addrs: []liteclient.DHTAddress{
 							{
-								"adnl.address.udp",
-								-1307380860,
-								15888,
+								Type: "adnl.address.udp",
+								IP:   -1307380860,
+								Port: 15888,
 							},
 						}},
 					Version:   -1,
@@ -305,140 +305,6 @@ func TestClient_FindValue(t *testing.T) {
 	}
 }
 
-// TODO: remove knownNodes
-//func TestClient_NewClientFromConfig(t *testing.T) {
-//	byteKey1, err := base64.StdEncoding.DecodeString("6PGkPQSbyFp12esf1NqmDOaLoFA8i9+Mp5+cAx5wtTU=")
-//	if err != nil {
-//		t.Fatal("failed to decode test public key, err: ", err)
-//	}
-//
-//	pubKey1 := ed25519.PublicKey(byteKey1)
-//	adnlPubKey1 := adnl.PublicKeyED25519{Key: pubKey1}
-//
-//	tKeyId1, err := tl.Hash(adnlPubKey1)
-//	if err != nil {
-//		t.Fatal("failed to prepare test key id, err: ", err)
-//	}
-//
-//	hexTKeyId1 := hex.EncodeToString(tKeyId1)
-//
-//	tAddr1 := makeStrAddress(-1185526007, 22096)
-//
-//	node1 := dhtNode{
-//		adnlId:    tKeyId1,
-//		addr:      tAddr1,
-//		serverKey: pubKey1,
-//	}
-//
-//	byteKey2, err := base64.StdEncoding.DecodeString("bn8klhFZgE2sfIDfvVI6m6+oVNi1nBRlnHoxKtR9WBU=")
-//	if err != nil {
-//		t.Fatal("failed to decode test public key, err: ", err)
-//	}
-//
-//	pubKey2 := ed25519.PublicKey(byteKey2)
-//	adnlPubKey2 := adnl.PublicKeyED25519{Key: pubKey2}
-//
-//	tKeyId2, err := tl.Hash(adnlPubKey2)
-//	if err != nil {
-//		t.Fatal("failed to prepare test key id, err: ", err)
-//	}
-//
-//	hexTKeyId2 := hex.EncodeToString(tKeyId2)
-//
-//	tAddr2 := makeStrAddress(-1307380860, 15888)
-//
-//	node2 := dhtNode{
-//		adnlId:    tKeyId2,
-//		addr:      tAddr2,
-//		serverKey: pubKey2,
-//	}
-//
-//	tests := []struct {
-//		name         string
-//		tNode1       dhtNode
-//		tNode2       dhtNode
-//		wantLenNodes int
-//		checkAdd1    bool
-//		checkAdd2    bool
-//	}{
-//		{
-//			"positive case (all nodes valid)", node1, node2, 2, true, true,
-//		},
-//		{
-//			"negative case (one of two nodes with bad sign)", node1, node2, 2, true, true,
-//		},
-//	}
-//
-//	for _, test := range tests {
-//		t.Run(test.name, func(t *testing.T) {
-//			gateway := &MockGateway{}
-//			gateway.reg = func(addr string, peerKey ed25519.PublicKey) (adnl.Peer, error) {
-//				return MockADNL{
-//					query: func(ctx context.Context, req, result tl.Serializable) error {
-//						switch request := req.(type) {
-//						case Ping:
-//							if test.name == "positive case (all nodes valid)" {
-//								reflect.ValueOf(result).Elem().Set(reflect.ValueOf(Pong{ID: request.ID}))
-//							} else if test.name == "negative case (one of two nodes with bad sign)" {
-//								if addr == tAddr1 {
-//									reflect.ValueOf(result).Elem().Set(reflect.ValueOf(Pong{ID: request.ID}))
-//								} else if addr == tAddr2 {
-//									return errors.New("node is not answering")
-//								}
-//							}
-//						default:
-//							return fmt.Errorf("mock err: unsupported request type '%s'", reflect.TypeOf(req).String())
-//						}
-//						return nil
-//					},
-//				}, nil
-//			}
-//
-//			cli, err := NewClientFromConfig(gateway, cnf)
-//			if err != nil {
-//				t.Fatal(err)
-//			}
-//
-//			if len(cli.knownNodes) != test.wantLenNodes {
-//				t.Errorf("added nodes count (known'%d') but expected(%d)", len(cli.knownNodes), test.wantLenNodes)
-//			}
-//
-//			resDhtNode1, ok1 := cli.knownNodes[hexTKeyId1]
-//			if ok1 != test.checkAdd1 {
-//				t.Errorf("invalid active nodes addition")
-//			}
-//			if ok1 {
-//				if !bytes.Equal(resDhtNode1.adnlId, test.tNode1.adnlId) {
-//					t.Errorf("invalid active node id")
-//				}
-//				if resDhtNode1.addr != test.tNode1.addr {
-//					t.Errorf("invalid active node address")
-//				}
-//				if !resDhtNode1.serverKey.Equal(test.tNode1.serverKey) {
-//					t.Errorf("invalid active node server key")
-//				}
-//			}
-//
-//			resDhtNode2, ok2 := cli.knownNodes[hexTKeyId2]
-//			if ok2 != test.checkAdd2 {
-//				t.Errorf("invalid active nodes addition")
-//			}
-//
-//			if ok2 {
-//				if !bytes.Equal(resDhtNode2.adnlId, test.tNode2.adnlId) {
-//					t.Errorf("invalid active node id")
-//				}
-//				if resDhtNode2.addr != test.tNode2.addr {
-//					t.Errorf("invalid active node address")
-//				}
-//				if !resDhtNode2.serverKey.Equal(test.tNode2.serverKey) {
-//					t.Errorf("invalid active node server key")
-//				}
-//			}
-//		})
-//	}
-//}
-
 func TestClient_FindAddressesUnit(t *testing.T) {
 	testAddr := "516618cf6cbe9004f6883e742c9a2e3ca53ed02e3e36f4cef62a98ee1e449174" // ADNL address of foundation.ton
 	adnlAddr, err := hex.DecodeString(testAddr)
@@ -22,9 +22,12 @@ const _MaxFailCount = 3
 type dhtNode struct {
 	adnlId []byte
 	client *Client
+	// node - signed node info, it is returned to other nodes when we work as a server
+	node *Node
 
-	ping      int64
-	addr      string
+	ping int64
+	// addrs - addresses of the node in priority order
+	addrs     []string
 	serverKey ed25519.PublicKey
 
 	currentState int
@@ -56,16 +59,36 @@ func (l dhtNodeList) Less(i, j int) bool {
 	return l[i].ping < l[j].ping
 }
 
-func (c *Client) connectToNode(id []byte, addr string, serverKey ed25519.PublicKey) *dhtNode {
+func (c *Client) connectToNode(id []byte, addrs []string, serverKey ed25519.PublicKey) *dhtNode {
 	n := &dhtNode{
 		adnlId:    id,
-		addr:      addr,
+		addrs:     addrs,
 		serverKey: serverKey,
 		client:    c,
 	}
 	return n
 }
 
+// signedNode - returns signed node info, which is given to other nodes
+func (n *dhtNode) signedNode() *Node {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	return n.node
+}
+
+// updateSignedNode - replaces signed node info if the given one is not older
+func (n *dhtNode) updateSignedNode(node *Node) {
+	if node == nil {
+		return
+	}
+
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if n.node == nil || node.Version >= n.node.Version {
+		n.node = node
+	}
+}
+
 func (n *dhtNode) findNodes(ctx context.Context, id []byte, K int32) (result []*Node, err error) {
 	val, err := tl.Serialize(FindNode{
 		Key: id,
@@ -255,7 +278,7 @@ func (n *dhtNode) query(ctx context.Context, req, res tl.Serializable) error {
 
 	atomic.AddInt32(&n.inFlyQueries, 1)
 
-	peer, err := n.client.gateway.RegisterClient(n.addr, n.serverKey)
+	peer, err := n.client.gateway.RegisterClientAny(ctx, n.addrs, n.serverKey)
 	if err != nil {
 		atomic.AddInt32(&n.inFlyQueries, -1)
 		return err
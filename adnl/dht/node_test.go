@@ -27,7 +27,7 @@ func newCorrectDhtNode(a byte, b byte, c byte, d byte, port string) (*dhtNode, e
 	}
 	resDhtNode := &dhtNode{
 		adnlId:    kId,
-		addr:      net.IPv4(a, b, c, d).To4().String() + ":" + port,
+		addrs:     []string{net.IPv4(a, b, c, d).To4().String() + ":" + port},
 		serverKey: tPubKey,
 	}
 	return resDhtNode, nil
@@ -328,9 +328,6 @@ func TestNode_findValue(t *testing.T) {
 					}
 				}
 			}
-			for _, val := range cli.knownNodes {
-				testNode = val
-			}
 
 			siteAddr, err := hex.DecodeString(test.addr)
 			if err != nil {
@@ -0,0 +1,61 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl/overlay"
+)
+
+// OverlayNodes - adapter of the client for overlay.PeerManager,
+// it continues search from the previous position, to find new nodes on each call
+type OverlayNodes struct {
+	// TTL - ttl of the stored nodes
+	TTL time.Duration
+	// Replicas - number of dht nodes to store overlay nodes on
+	Replicas int
+
+	client        *Client
+	continuations map[string]*Continuation
+	mx            sync.Mutex
+}
+
+func (c *Client) OverlayNodes() *OverlayNodes {
+	return &OverlayNodes{
+		TTL:           30 * time.Minute,
+		Replicas:      5,
+		client:        c,
+		continuations: map[string]*Continuation{},
+	}
+}
+
+func (o *OverlayNodes) FindOverlayNodes(ctx context.Context, overlayKey []byte) ([]overlay.Node, error) {
+	o.mx.Lock()
+	cont := o.continuations[string(overlayKey)]
+	o.mx.Unlock()
+
+	nodes, cont, err := o.client.FindOverlayNodes(ctx, overlayKey, cont)
+	if errors.Is(err, ErrDHTValueIsNotFound) {
+		// start from the beginning next time
+		cont = nil
+	}
+
+	o.mx.Lock()
+	o.continuations[string(overlayKey)] = cont
+	o.mx.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return nodes.List, nil
+}
+
+func (o *OverlayNodes) StoreOverlayNodes(ctx context.Context, overlayKey []byte, nodes []overlay.Node) error {
+	if _, _, err := o.client.StoreOverlayNodes(ctx, overlayKey, &overlay.NodesList{List: nodes}, o.TTL, o.Replicas); err != nil {
+		return fmt.Errorf("failed to store overlay nodes: %w", err)
+	}
+	return nil
+}
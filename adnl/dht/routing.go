@@ -0,0 +1,266 @@
+package dht
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+// RoutingTableStats - metrics of the dht routing table
+type RoutingTableStats struct {
+	// Nodes - number of nodes in buckets
+	Nodes int
+	// GoodNodes - nodes without failed queries
+	GoodNodes int
+	// Replacements - nodes waiting for a place in full buckets
+	Replacements int
+	// Buckets - number of not empty buckets
+	Buckets int
+	// Evictions - number of dead nodes replaced since start
+	Evictions uint64
+	// Refreshes - number of bucket refresh lookups since start
+	Refreshes uint64
+}
+
+// RoutingTableStats - returns current metrics of the routing table
+func (c *Client) RoutingTableStats() RoutingTableStats {
+	var st RoutingTableStats
+	for _, b := range c.buckets {
+		b.mx.RLock()
+		if len(b.nodes) > 0 {
+			st.Buckets++
+		}
+		for _, n := range b.nodes {
+			st.Nodes++
+			if atomic.LoadInt32(&n.badScore) == 0 {
+				st.GoodNodes++
+			}
+		}
+		st.Replacements += len(b.replacements)
+		b.mx.RUnlock()
+	}
+	st.Evictions = atomic.LoadUint64(&c.evictions)
+	st.Refreshes = atomic.LoadUint64(&c.refreshes)
+	return st
+}
+
+// SaveRoutingTable - writes signed nodes of the routing table to file, it can be loaded on the next start
+func (c *Client) SaveRoutingTable(path string) error {
+	var list NodesList
+	for _, b := range c.buckets {
+		for _, n := range b.getNodes() {
+			if n == nil {
+				continue
+			}
+			if node := n.signedNode(); node != nil {
+				list.List = append(list.List, node)
+			}
+		}
+	}
+
+	data, err := tl.Serialize(list, true)
+	if err != nil {
+		return fmt.Errorf("failed to serialize nodes: %w", err)
+	}
+
+	// write to temp file first, to not corrupt the existing table on failure
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write routing table: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace routing table file: %w", err)
+	}
+	return nil
+}
+
+// LoadRoutingTable - adds nodes saved by SaveRoutingTable, nodes with bad signatures are skipped.
+// Returns number of added nodes.
+func (c *Client) LoadRoutingTable(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read routing table: %w", err)
+	}
+
+	var list NodesList
+	if _, err = tl.Parse(&list, data, true); err != nil {
+		return 0, fmt.Errorf("failed to parse routing table: %w", err)
+	}
+
+	added := 0
+	for _, node := range list.List {
+		if err = node.CheckSignature(); err != nil {
+			continue
+		}
+		if _, err = c.addNode(node); err != nil {
+			continue
+		}
+		added++
+	}
+	return added, nil
+}
+
+// StartRefresh - refreshes buckets in background with the given interval, until client is closed
+func (c *Client) StartRefresh(interval time.Duration) {
+	go func() {
+		for {
+			select {
+			case <-c.globalCtx.Done():
+				return
+			case <-time.After(interval):
+			}
+
+			c.RefreshBuckets(c.globalCtx, interval)
+		}
+	}()
+}
+
+// RefreshBuckets - does lookup of a random id in each bucket which had no lookups for staleAfter,
+// discovered nodes are added to the routing table. Buckets closer to us than the next after the last not empty one
+// are skipped, they cannot contain nodes in practice.
+// Returns number of refreshed buckets.
+func (c *Client) RefreshBuckets(ctx context.Context, staleAfter time.Duration) int {
+	ourId := c.gateway.GetID()
+
+	last := 0
+	for i, b := range c.buckets {
+		if len(b.getNodes()) > 0 {
+			last = i
+		}
+	}
+	if last < len(c.buckets)-1 {
+		// next one too, to discover nodes closer to us
+		last++
+	}
+
+	refreshed := 0
+	for i := 0; i <= last; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		b := c.buckets[i]
+		b.mx.RLock()
+		fresh := time.Since(b.lastRefreshAt) < staleAfter
+		b.mx.RUnlock()
+		if fresh {
+			continue
+		}
+
+		c.lookupNodes(ctx, randomIDInBucket(ourId, i))
+		atomic.AddUint64(&c.refreshes, 1)
+		refreshed++
+	}
+	return refreshed
+}
+
+// checkEviction - pings the worst node of the full bucket, and replaces it if it is not alive
+func (c *Client) checkEviction(b *Bucket, node *dhtNode) {
+	ctx, cancel := context.WithTimeout(c.globalCtx, queryTimeout)
+	err := node.doPing(ctx)
+	cancel()
+
+	if b.finishCheck(node, err == nil) {
+		atomic.AddUint64(&c.evictions, 1)
+		Logger("DHT node", node.id(), "evicted from routing table")
+	}
+}
+
+// lookupNodes - iteratively searches for nodes closest to id, discovered nodes are added to the routing table
+func (c *Client) lookupNodes(ctx context.Context, keyId []byte) {
+	idx := affinity(keyId, c.gateway.GetID())
+	if idx >= uint(len(c.buckets)) {
+		idx = uint(len(c.buckets)) - 1
+	}
+
+	b := c.buckets[idx]
+	b.mx.Lock()
+	b.lastRefreshAt = time.Now()
+	b.mx.Unlock()
+
+	checked := map[string]bool{}
+	plist := c.buildPriorityList(keyId)
+
+	const activeQueries = 6
+
+	for {
+		currentLen := len(checked)
+
+	chk:
+		for {
+			var wg sync.WaitGroup
+			for i := 0; i < activeQueries; i++ {
+				node, _ := plist.getNode()
+				if node == nil {
+					break chk
+				}
+
+				nodeId := node.id()
+				if checked[nodeId] {
+					continue
+				}
+				checked[nodeId] = true
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					Logger("Search nodes", nodeId)
+
+					findCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+					nodes, err := node.findNodes(findCtx, keyId, _K)
+					cancel()
+					if err != nil {
+						return
+					}
+
+					Logger("Adding nodes", len(nodes))
+					for _, n := range nodes {
+						if _, err = c.addNode(n); err != nil {
+							continue
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		}
+		plist = c.buildPriorityList(keyId)
+		if len(checked) == currentLen {
+			Logger("S list stops growing:", len(checked))
+			break
+		} else {
+			Logger("K iteration ends. Current size:", len(checked))
+		}
+	}
+}
+
+// randomIDInBucket - generates random id which has exactly i common leading bits with our id
+func randomIDInBucket(ourId []byte, i int) []byte {
+	id := make([]byte, 32)
+	_, _ = rand.Read(id)
+
+	byteIdx, bit := i/8, uint(i%8)
+	copy(id[:byteIdx], ourId[:byteIdx])
+
+	common := byte(0xFF) << (8 - bit)
+	differ := byte(0x80) >> bit
+	id[byteIdx] = ourId[byteIdx]&common | ^ourId[byteIdx]&differ | id[byteIdx]&^(common|differ)
+	return id
+}
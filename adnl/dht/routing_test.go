@@ -0,0 +1,183 @@
+package dht
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/adnl/address"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+func TestRandomIDInBucket(t *testing.T) {
+	our := make([]byte, 32)
+	_, _ = rand.Read(our)
+
+	for i := 0; i < 256; i++ {
+		if a := affinity(randomIDInBucket(our, i), our); a != uint(i) {
+			t.Fatal("id of bucket", i, "has affinity", a)
+		}
+	}
+}
+
+func TestClient_RoutingTable(t *testing.T) {
+	var alive = true
+	gate := &MockGateway{
+		reg: func(addr string, key ed25519.PublicKey) (adnl.Peer, error) {
+			return MockADNL{
+				query: func(ctx context.Context, req, result tl.Serializable) error {
+					if !alive {
+						return errors.New("timeout")
+					}
+					*result.(*any) = Pong{}
+					return nil
+				},
+			}, nil
+		},
+	}
+
+	client, err := NewClient(gate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// all nodes have affinity 0 with zero id of mock gateway, so they go to the same bucket
+	var nodes []*Node
+	for i := 0; i < _K*5+2; i++ {
+		for {
+			n, err := newCorrectNode(10, 0, 0, byte(i), 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, _ := tl.Hash(n.ID)
+			if id[0]&0x80 != 0 {
+				nodes = append(nodes, n)
+				break
+			}
+		}
+	}
+
+	for _, n := range nodes[:_K*5] {
+		if _, err = client.addNode(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := client.RoutingTableStats()
+	if st.Nodes != _K*5 || st.Buckets != 1 || st.Replacements != 0 {
+		t.Fatal("incorrect stats", st)
+	}
+
+	// known node is merged into the existing entry, collected stats are kept
+	existing := client.buckets[0].getNode(hexID(t, nodes[0]))
+	atomic.StoreInt64(&existing.ping, 777)
+	atomic.StoreInt32(&existing.badScore, 1)
+	stored, err := client.addNode(nodes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != existing || atomic.LoadInt64(&stored.ping) != 777 || atomic.LoadInt32(&stored.badScore) != 1 {
+		t.Fatal("existing node should be kept")
+	}
+	atomic.StoreInt64(&existing.ping, 0)
+	atomic.StoreInt32(&existing.badScore, 0)
+
+	waitCheck := func() {
+		for i := 0; i < 100; i++ {
+			client.buckets[0].mx.RLock()
+			checking := client.buckets[0].checking
+			client.buckets[0].mx.RUnlock()
+			if checking == nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("eviction check is not finished")
+	}
+
+	// worst node is alive, new one waits as replacement
+	if _, err = client.addNode(nodes[_K*5]); err != nil {
+		t.Fatal(err)
+	}
+	waitCheck()
+
+	st = client.RoutingTableStats()
+	if st.Nodes != _K*5 || st.Replacements != 1 || st.Evictions != 0 {
+		t.Fatal("incorrect stats after alive check", st)
+	}
+
+	// worst node is dead, replaced by the freshest replacement
+	alive = false
+	if _, err = client.addNode(nodes[_K*5+1]); err != nil {
+		t.Fatal(err)
+	}
+	waitCheck()
+
+	st = client.RoutingTableStats()
+	if st.Nodes != _K*5 || st.Replacements != 1 || st.Evictions != 1 {
+		t.Fatal("incorrect stats after eviction", st)
+	}
+	if client.buckets[0].getNode(hexID(t, nodes[_K*5+1])) == nil {
+		t.Fatal("replacement should be in bucket")
+	}
+
+	path := filepath.Join(t.TempDir(), "dht.bin")
+	if err = client.SaveRoutingTable(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewClient(gate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+
+	num, err := loaded.LoadRoutingTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != _K*5 || loaded.RoutingTableStats().Nodes != _K*5 {
+		t.Fatal("incorrect number of loaded nodes", num)
+	}
+}
+
+func hexID(t *testing.T, n *Node) string {
+	id, err := tl.Hash(n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+func TestClient_AddNodeAddresses(t *testing.T) {
+	client, err := NewClient(&MockGateway{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	n, err := newCorrectNode(1, 2, 3, 4, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.AddrList.Addresses = append(n.AddrList.Addresses, &address.UDP{IP: net.IPv4(5, 6, 7, 8).To4(), Port: 2000})
+
+	stored, err := client.addNode(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all addresses are kept in priority order, to connect to the first reachable one
+	if len(stored.addrs) != 2 || stored.addrs[0] != "1.2.3.4:1000" || stored.addrs[1] != "5.6.7.8:2000" {
+		t.Fatal("incorrect node addresses", stored.addrs)
+	}
+}
@@ -0,0 +1,316 @@
+package dht
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/adnl/address"
+	"github.com/chaindead/tonutils-go/adnl/overlay"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+const _MaxValueSize = 768
+const _MaxFindK = 10
+
+type ServerGateway interface {
+	GetAddressList() address.List
+}
+
+type storedValue struct {
+	value     *Value
+	expiresAt time.Time
+}
+
+// Server - dht node, answers queries of other nodes and keeps values stored by them.
+// Routing table of the client is used to answer nodes search, client should use the same adnl key as server.
+type Server struct {
+	// MaxValues - limit of values to keep, new values are rejected when it is reached
+	MaxValues int
+	// MaxTTL - values which are stored for a longer time are rejected
+	MaxTTL time.Duration
+
+	client *Client
+	gate   ServerGateway
+	key    ed25519.PrivateKey
+
+	values map[string]*storedValue
+	mx     sync.RWMutex
+}
+
+// NewServer - creates dht server, it does not touch connection handler of the gateway,
+// so it can be shared with other services: HandleConnection should be called from it for each new peer.
+func NewServer(client *Client, gate ServerGateway, key ed25519.PrivateKey) *Server {
+	s := &Server{
+		MaxValues: 1 << 16,
+		MaxTTL:    time.Hour + time.Minute,
+		client:    client,
+		gate:      gate,
+		key:       key,
+		values:    map[string]*storedValue{},
+	}
+	return s
+}
+
+// HandleConnection - sets dht query handler for the connected peer,
+// queries which are not dht ones are passed to the query handler which was set before.
+func (s *Server) HandleConnection(client adnl.Peer) error {
+	previousHandler := client.GetQueryHandler()
+	client.SetQueryHandler(func(msg *adnl.MessageQuery) error {
+		res, err := s.HandleQuery(msg.Data)
+		if err != nil {
+			if previousHandler != nil {
+				return previousHandler(msg)
+			}
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err = client.Answer(ctx, msg.ID, res); err != nil {
+			return fmt.Errorf("failed to answer dht query: %w", err)
+		}
+		return nil
+	})
+	return nil
+}
+
+// HandleQuery - processes dht query and returns answer for it.
+// Query can be prefixed with dht.query, then sender node is added to the routing table.
+func (s *Server) HandleQuery(req tl.Serializable) (tl.Serializable, error) {
+	if arr, ok := req.([]tl.Serializable); ok && len(arr) == 2 {
+		if q, isQuery := arr[0].(Query); isQuery {
+			if q.Node != nil && q.Node.CheckSignature() == nil {
+				_, _ = s.client.addNode(q.Node)
+			}
+			req = arr[1]
+		}
+	}
+
+	switch q := req.(type) {
+	case Ping:
+		return Pong{ID: q.ID}, nil
+	case FindNode:
+		return NodesList{List: s.client.nearestNodes(q.Key, int(q.K))}, nil
+	case FindValue:
+		if v := s.getValue(q.Key); v != nil {
+			return ValueFoundResult{Value: *v}, nil
+		}
+		return ValueNotFoundResult{Nodes: NodesList{List: s.client.nearestNodes(q.Key, int(q.K))}}, nil
+	case Store:
+		if q.Value == nil {
+			return nil, fmt.Errorf("empty value")
+		}
+		if err := s.storeValue(q.Value); err != nil {
+			return nil, fmt.Errorf("failed to store value: %w", err)
+		}
+		return Stored{}, nil
+	case SignedAddressListQuery:
+		node, err := s.signedNode()
+		if err != nil {
+			return nil, err
+		}
+		return *node, nil
+	}
+	return nil, fmt.Errorf("unexpected dht query type %s", reflect.TypeOf(req))
+}
+
+// Values - returns number of currently stored values
+func (s *Server) Values() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return len(s.values)
+}
+
+func (s *Server) getValue(id []byte) *Value {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	v := s.values[string(id)]
+	if v == nil || time.Now().After(v.expiresAt) {
+		return nil
+	}
+	return v.value
+}
+
+func (s *Server) storeValue(value *Value) error {
+	id, err := tl.Hash(value.KeyDescription.Key)
+	if err != nil {
+		return fmt.Errorf("failed to calc key id: %w", err)
+	}
+
+	if err = checkValue(id, value); err != nil {
+		return fmt.Errorf("corrupted value: %w", err)
+	}
+
+	if len(value.Data) > _MaxValueSize {
+		return fmt.Errorf("too big value")
+	}
+
+	now := time.Now()
+	expiresAt := time.Unix(int64(value.TTL), 0)
+	if !expiresAt.After(now) {
+		return fmt.Errorf("value is expired")
+	}
+	if expiresAt.Sub(now) > s.MaxTTL {
+		return fmt.Errorf("too big ttl")
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	old := s.values[string(id)]
+	if old != nil && now.After(old.expiresAt) {
+		delete(s.values, string(id))
+		old = nil
+	}
+
+	if old == nil {
+		if len(s.values) >= s.MaxValues {
+			s.cleanup(now)
+			if len(s.values) >= s.MaxValues {
+				return fmt.Errorf("storage is full")
+			}
+		}
+
+		s.values[string(id)] = &storedValue{value: value, expiresAt: expiresAt}
+		return nil
+	}
+
+	switch value.KeyDescription.UpdateRule.(type) {
+	case UpdateRuleSignature:
+		// only newer value can replace, to not allow replay of the old ones
+		if value.TTL <= old.value.TTL {
+			return nil
+		}
+	case UpdateRuleOverlayNodes:
+		if value, err = mergeOverlayNodes(old.value, value); err != nil {
+			return fmt.Errorf("failed to merge overlay nodes: %w", err)
+		}
+		expiresAt = time.Unix(int64(value.TTL), 0)
+	}
+
+	s.values[string(id)] = &storedValue{value: value, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *Server) cleanup(now time.Time) {
+	for k, v := range s.values {
+		if now.After(v.expiresAt) {
+			delete(s.values, k)
+		}
+	}
+}
+
+// signedNode - returns our dht node with the current address list of the gateway
+func (s *Server) signedNode() (*Node, error) {
+	addrs := s.gate.GetAddressList()
+	node := &Node{
+		ID:       adnl.PublicKeyED25519{Key: s.key.Public().(ed25519.PublicKey)},
+		AddrList: &addrs,
+		Version:  int32(time.Now().Unix()),
+	}
+
+	var err error
+	node.Signature, err = signTL(node, s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign node: %w", err)
+	}
+	return node, nil
+}
+
+// mergeOverlayNodes - combines nodes of both values, newer version of each node is kept,
+// the oldest nodes are dropped when list is not fitting into the max value size
+func mergeOverlayNodes(old, value *Value) (*Value, error) {
+	var oldNodes, newNodes overlay.NodesList
+	if _, err := tl.Parse(&oldNodes, old.Data, true); err != nil {
+		return nil, fmt.Errorf("failed to parse stored nodes: %w", err)
+	}
+	if _, err := tl.Parse(&newNodes, value.Data, true); err != nil {
+		return nil, fmt.Errorf("failed to parse new nodes: %w", err)
+	}
+
+	var list []overlay.Node
+	index := map[string]int{}
+	for _, node := range append(newNodes.List, oldNodes.List...) {
+		id, err := tl.Hash(node.ID)
+		if err != nil {
+			continue
+		}
+
+		if i, ok := index[string(id)]; ok {
+			if list[i].Version < node.Version {
+				list[i] = node
+			}
+			continue
+		}
+		index[string(id)] = len(list)
+		list = append(list, node)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Version > list[j].Version
+	})
+
+	for len(list) > 0 {
+		data, err := tl.Serialize(overlay.NodesList{List: list}, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize nodes: %w", err)
+		}
+
+		if len(data) <= _MaxValueSize {
+			merged := *value
+			merged.Data = data
+			if old.TTL > merged.TTL {
+				merged.TTL = old.TTL
+			}
+			return &merged, nil
+		}
+		list = list[:len(list)-1]
+	}
+	return nil, fmt.Errorf("no nodes fit into value")
+}
+
+// nearestNodes - returns up to k signed nodes from the routing table, closest to the given id
+func (c *Client) nearestNodes(id []byte, k int) []*Node {
+	if k <= 0 || k > _MaxFindK {
+		k = _MaxFindK
+	}
+
+	type candidate struct {
+		node     *Node
+		affinity uint
+	}
+
+	var list []candidate
+	for _, b := range c.buckets {
+		for _, n := range b.getNodes() {
+			if n == nil || atomic.LoadInt32(&n.badScore) >= _MaxFailCount {
+				continue
+			}
+			if node := n.signedNode(); node != nil {
+				list = append(list, candidate{node: node, affinity: affinity(n.adnlId, id)})
+			}
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].affinity > list[j].affinity
+	})
+
+	res := make([]*Node, 0, k)
+	for _, cand := range list {
+		if len(res) >= k {
+			break
+		}
+		res = append(res, cand.node)
+	}
+	return res
+}
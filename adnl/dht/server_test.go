@@ -0,0 +1,238 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/adnl/address"
+	"github.com/chaindead/tonutils-go/adnl/overlay"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+type mockServerGateway struct{}
+
+func (m *mockServerGateway) GetAddressList() address.List {
+	return address.List{
+		Addresses: []*address.UDP{{IP: []byte{1, 2, 3, 4}, Port: 12345}},
+	}
+}
+
+func newSignedValue(t *testing.T, key ed25519.PrivateKey, data []byte, ttl time.Duration) *Value {
+	id := adnl.PublicKeyED25519{Key: key.Public().(ed25519.PublicKey)}
+	idKey, err := tl.Hash(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := &Value{
+		KeyDescription: KeyDescription{
+			Key: Key{
+				ID:    idKey,
+				Name:  []byte("address"),
+				Index: 0,
+			},
+			ID:         id,
+			UpdateRule: UpdateRuleSignature{},
+		},
+		Data: data,
+		TTL:  int32(time.Now().Add(ttl).Unix()),
+	}
+
+	if val.KeyDescription.Signature, err = signTL(val.KeyDescription, key); err != nil {
+		t.Fatal(err)
+	}
+	if val.Signature, err = signTL(val, key); err != nil {
+		t.Fatal(err)
+	}
+	return val
+}
+
+func newOverlayNodesValue(t *testing.T, overlayKey []byte, ttl time.Duration) *Value {
+	_, key, _ := ed25519.GenerateKey(nil)
+	node, err := overlay.NewNode(overlayKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := tl.Serialize(overlay.NodesList{List: []overlay.Node{*node}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := adnl.PublicKeyOverlay{Key: overlayKey}
+	idKey, err := tl.Hash(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Value{
+		KeyDescription: KeyDescription{
+			Key: Key{
+				ID:    idKey,
+				Name:  []byte("nodes"),
+				Index: 0,
+			},
+			ID:         id,
+			UpdateRule: UpdateRuleOverlayNodes{},
+		},
+		Data: data,
+		TTL:  int32(time.Now().Add(ttl).Unix()),
+	}
+}
+
+func TestServer_HandleQuery(t *testing.T) {
+	var nodes []*Node
+	for i := byte(1); i <= 3; i++ {
+		n, err := newCorrectNode(10, 0, 0, i, 1000+int32(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, n)
+	}
+
+	client, err := NewClient(&MockGateway{}, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	srv := NewServer(client, &mockServerGateway{}, key)
+
+	res, err := srv.HandleQuery(Ping{ID: 777})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := res.(Pong); !ok || p.ID != 777 {
+		t.Fatal("incorrect pong")
+	}
+
+	res, err = srv.HandleQuery(FindNode{Key: make([]byte, 32), K: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := res.(NodesList); !ok || len(l.List) != 2 {
+		t.Fatal("incorrect nodes list")
+	}
+
+	res, err = srv.HandleQuery(SignedAddressListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ourNode, ok := res.(Node)
+	if !ok {
+		t.Fatal("incorrect signed address list answer")
+	}
+	if err = ourNode.CheckSignature(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("signed value", func(t *testing.T) {
+		_, owner, _ := ed25519.GenerateKey(nil)
+		val := newSignedValue(t, owner, []byte{1, 2, 3}, 10*time.Minute)
+		keyId, _ := tl.Hash(val.KeyDescription.Key)
+
+		res, err = srv.HandleQuery(FindValue{Key: keyId, K: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nf, ok := res.(ValueNotFoundResult); !ok || len(nf.Nodes.List) != 3 {
+			t.Fatal("value should be not found")
+		}
+
+		if _, err = srv.HandleQuery(Store{Value: val}); err != nil {
+			t.Fatal(err)
+		}
+
+		// older value should not replace newer
+		older := newSignedValue(t, owner, []byte{4, 5, 6}, 5*time.Minute)
+		if _, err = srv.HandleQuery(Store{Value: older}); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err = srv.HandleQuery(FindValue{Key: keyId, K: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, ok := res.(ValueFoundResult)
+		if !ok || string(f.Value.Data) != string([]byte{1, 2, 3}) {
+			t.Fatal("incorrect value found")
+		}
+
+		tampered := newSignedValue(t, owner, []byte{1}, 20*time.Minute)
+		tampered.Data = []byte{2}
+		if _, err = srv.HandleQuery(Store{Value: tampered}); err == nil {
+			t.Fatal("tampered value should be rejected")
+		}
+
+		if _, err = srv.HandleQuery(Store{Value: newSignedValue(t, owner, []byte{1}, -time.Minute)}); err == nil {
+			t.Fatal("expired value should be rejected")
+		}
+
+		if _, err = srv.HandleQuery(Store{Value: newSignedValue(t, owner, []byte{1}, 24*time.Hour)}); err == nil {
+			t.Fatal("value with too big ttl should be rejected")
+		}
+	})
+
+	t.Run("overlay nodes", func(t *testing.T) {
+		overlayKey := make([]byte, 32)
+		_, _ = rand.Read(overlayKey)
+
+		v1 := newOverlayNodesValue(t, overlayKey, 10*time.Minute)
+		v2 := newOverlayNodesValue(t, overlayKey, 5*time.Minute)
+
+		if _, err = srv.HandleQuery(Store{Value: v1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = srv.HandleQuery(Store{Value: v2}); err != nil {
+			t.Fatal(err)
+		}
+
+		keyId, _ := tl.Hash(v1.KeyDescription.Key)
+		res, err = srv.HandleQuery(FindValue{Key: keyId, K: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, ok := res.(ValueFoundResult)
+		if !ok {
+			t.Fatal("value should be found")
+		}
+
+		var list overlay.NodesList
+		if _, err = tl.Parse(&list, f.Value.Data, true); err != nil {
+			t.Fatal(err)
+		}
+		if len(list.List) != 2 {
+			t.Fatal("nodes should be merged, got", len(list.List))
+		}
+		if f.Value.TTL != v1.TTL {
+			t.Fatal("max ttl should be kept")
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		srv.MaxValues = srv.Values()
+
+		_, owner, _ := ed25519.GenerateKey(nil)
+		if _, err = srv.HandleQuery(Store{Value: newSignedValue(t, owner, []byte{1}, time.Minute)}); err == nil {
+			t.Fatal("storage should be full")
+		}
+	})
+
+	t.Run("query prefix", func(t *testing.T) {
+		n, err := newCorrectNode(10, 0, 0, 100, 1100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err = srv.HandleQuery([]tl.Serializable{Query{Node: n}, FindNode{Key: make([]byte, 32), K: 10}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l, ok := res.(NodesList); !ok || len(l.List) != 4 {
+			t.Fatal("sender node should be added to routing table")
+		}
+	})
+}
@@ -11,7 +11,6 @@ import (
 	"net"
 	"net/netip"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -58,13 +57,14 @@ type srvProcessor struct {
 type Gateway struct {
 	conn net.PacketConn
 
-	dhtIP      net.IP
-	addrList   address.List
-	key        ed25519.PrivateKey
-	processors map[string]*srvProcessor
-	peers      map[string]*peerConn
+	externalIPs []net.IP
+	addrList    address.List
+	key         ed25519.PrivateKey
+	processors  map[string]*srvProcessor
+	peers       map[string]*peerConn
 
 	connHandler func(client Peer) error
+	relay       *relayServer
 
 	globalCtx       context.Context
 	globalCtxCancel func()
@@ -97,37 +97,49 @@ func (g *Gateway) GetAddressList() address.List {
 }
 
 func (g *Gateway) SetExternalIP(ip net.IP) {
-	g.dhtIP = ip
+	g.externalIPs = []net.IP{ip}
+}
+
+// SetExternalIPs - sets public ips to announce in address list, ordered by priority,
+// it can contain both IPv4 and IPv6, to be reachable from both networks listen on dual stack address, like [::]:port
+func (g *Gateway) SetExternalIPs(ips ...net.IP) {
+	g.externalIPs = ips
 }
 
 func (g *Gateway) StartServer(listenAddr string) (err error) {
-	adr := strings.Split(listenAddr, ":")
-	if len(adr) != 2 {
+	host, portStr, err := net.SplitHostPort(listenAddr)
+	if err != nil {
 		return fmt.Errorf("invalid listen address")
 	}
 
-	ip := g.dhtIP
-	if ip == nil {
-		ip = net.ParseIP(adr[0])
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid listen port")
 	}
-	ip = ip.To4()
 
-	if ip.Equal(net.IPv4zero) {
-		return fmt.Errorf("external ip cannot be 0.0.0.0, set it explicitly")
+	ips := g.externalIPs
+	if len(ips) == 0 {
+		ips = []net.IP{net.ParseIP(host)}
 	}
-	port, err := strconv.ParseUint(adr[1], 10, 16)
-	if err != nil {
-		return fmt.Errorf("invalid listen port")
+
+	addrs := make([]*address.UDP, 0, len(ips))
+	for _, ip := range ips {
+		if ip == nil || ip.IsUnspecified() {
+			return fmt.Errorf("external ip cannot be unspecified, set it explicitly")
+		}
+
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		addrs = append(addrs, &address.UDP{
+			IP:   ip,
+			Port: int32(port),
+		})
 	}
 
 	tm := int32(time.Now().Unix())
 	g.addrList = address.List{
-		Addresses: []*address.UDP{
-			{
-				IP:   ip,
-				Port: int32(port),
-			},
-		},
+		Addresses:  addrs,
 		Version:    tm,
 		ReinitDate: tm,
 		Priority:   0,
@@ -207,85 +219,7 @@ func (g *Gateway) listen(rootId []byte) {
 			continue
 		}
 
-		if n < 64 {
-			// too small packet
-			continue
-		}
-
-		buf = buf[:n]
-		id := buf[:32]
-		buf = buf[32:]
-
-		if bytes.Equal(rootId, id) {
-			if len(buf) < 32 {
-				// too small packet
-				continue
-			}
-
-			data, err := decodePacket(g.key, buf)
-			if err != nil {
-				Logger("failed to decode packet:", err)
-				continue
-			}
-
-			packet, err := parsePacket(data)
-			if err != nil {
-				Logger("failed to parse packet:", err)
-				continue
-			}
-
-			peerId := packet.FromIDShort
-			if peerId == nil {
-				if packet.From == nil {
-					// invalid packet
-					continue
-				}
-
-				peerId, err = tl.Hash(PublicKeyED25519{Key: packet.From.Key})
-				if err != nil {
-					// invalid packet
-					continue
-				}
-			}
-
-			g.mx.RLock()
-			cli := g.peers[string(peerId)]
-			g.mx.RUnlock()
-
-			if cli == nil {
-				if packet.From == nil {
-					// invalid packet
-					continue
-				}
-
-				cli, err = g.registerClient(addr, packet.From.Key, string(peerId))
-				if err != nil {
-					continue
-				}
-			} else {
-				cli.checkUpdateAddr(addr)
-			}
-
-			err = cli.client.processPacket(packet, nil)
-			if err != nil {
-				Logger("failed to process ADNL packet:", err)
-				continue
-			}
-			continue
-		}
-
-		g.mx.RLock()
-		proc := g.processors[string(id)]
-		g.mx.RUnlock()
-
-		if proc == nil {
-			Logger("no processor for ADNL packet from", addr.String(), hex.EncodeToString(id))
-			continue
-		}
-
 		now := time.Now()
-		proc.lastPacketAt = now
-
 		if now.Sub(lastListCheck) > 10*time.Second {
 			lastListCheck = now
 			var prc []*srvProcessor
@@ -308,27 +242,113 @@ func (g *Gateway) listen(rootId []byte) {
 			}
 		}
 
-		// TODO: processors pool
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					Logger("critical error while processing packet at server:", r)
-				}
-			}()
+		g.handlePacket(rootId, addr, buf[:n])
+	}
+}
+
+// handlePacket - processes raw packet received from addr, directly or through relay
+func (g *Gateway) handlePacket(rootId []byte, addr net.Addr, packet []byte) {
+	if len(packet) < 64 {
+		// too small packet
+		return
+	}
 
-			err = proc.processor(buf)
+	id := packet[:32]
+	buf := packet[32:]
+
+	if bytes.Equal(rootId, id) {
+		data, err := decodePacket(g.key, buf)
+		if err != nil {
+			Logger("failed to decode packet:", err)
+			return
+		}
+
+		packet, err := parsePacket(data)
+		if err != nil {
+			Logger("failed to parse packet:", err)
+			return
+		}
+
+		peerId := packet.FromIDShort
+		if peerId == nil {
+			if packet.From == nil {
+				// invalid packet
+				return
+			}
+
+			peerId, err = tl.Hash(PublicKeyED25519{Key: packet.From.Key})
 			if err != nil {
-				Logger("failed to process packet at server:", err)
+				// invalid packet
 				return
 			}
-		}()
+		}
+
+		g.mx.RLock()
+		cli := g.peers[string(peerId)]
+		g.mx.RUnlock()
+
+		if cli == nil {
+			if packet.From == nil {
+				// invalid packet
+				return
+			}
+
+			cli, err = g.registerClient(addr, packet.From.Key, string(peerId))
+			if err != nil {
+				return
+			}
+		} else {
+			cli.checkUpdateAddr(addr)
+		}
+
+		err = cli.client.processPacket(packet, nil)
+		if err != nil {
+			Logger("failed to process ADNL packet:", err)
+		}
+		return
+	}
+
+	g.mx.RLock()
+	proc := g.processors[string(id)]
+	relay := g.relay
+	g.mx.RUnlock()
+
+	if proc == nil {
+		if relay != nil && relay.forward(id, addr, packet) {
+			return
+		}
+
+		if _, ok := addr.(*relayedAddr); ok {
+			// relay sends channel packets to all nodes which talked to the sender, it is not for us
+			return
+		}
+
+		Logger("no processor for ADNL packet from", addr.String(), hex.EncodeToString(id))
+		return
+	}
+
+	proc.lastPacketAt = time.Now()
+
+	// TODO: processors pool
+	defer func() {
+		if r := recover(); r != nil {
+			Logger("critical error while processing packet at server:", r)
+		}
+	}()
+
+	err := proc.processor(buf)
+	if err != nil {
+		Logger("failed to process packet at server:", err)
+		return
 	}
 }
 
 func (p *peerConn) checkUpdateAddr(addr net.Addr) {
 	// we use atomic to safely swap address without lock, in case of change
 	currentAddr := *(*net.Addr)(atomic.LoadPointer(&p.addr))
-	if currentAddr.String() != addr.String() {
+	// relayed address is always replaced, because relay connection could be recreated
+	_, relayed := addr.(*relayedAddr)
+	if relayed || currentAddr.String() != addr.String() {
 		atomic.StorePointer(&p.addr, unsafe.Pointer(&addr))
 	}
 }
@@ -368,6 +388,10 @@ func (g *Gateway) registerClient(addr net.Addr, key ed25519.PublicKey, id string
 	// setup basic disconnect handler to auto-cleanup processors list
 	peer.SetDisconnectHandler(nil)
 
+	if g.relay != nil {
+		a.internalHandler = g.relay.handler(peer)
+	}
+
 	a.SetChannelReadyHandler(func(ch *Channel) {
 		oldId := peer.channelId
 
@@ -414,6 +438,95 @@ func (g *Gateway) RegisterClient(addr string, key ed25519.PublicKey) (Peer, erro
 	return g.registerClient(udpAddr, key, string(clientId))
 }
 
+// HappyEyeballsDelay - how long to wait for the answer from address before trying the next one
+var HappyEyeballsDelay = 250 * time.Millisecond
+
+// RegisterClientAny - registers peer which has multiple addresses, ordered by priority.
+// Addresses are probed with adnl ping happy-eyeballs style: the next address is tried
+// when the previous one has not answered within HappyEyeballsDelay, and the first answered address is used.
+func (g *Gateway) RegisterClientAny(ctx context.Context, addrs []string, key ed25519.PublicKey) (Peer, error) {
+	var udpAddrs []net.Addr
+	for _, addr := range addrs {
+		pAddr, err := netip.ParseAddrPort(addr)
+		if err != nil {
+			continue
+		}
+		udpAddrs = append(udpAddrs, net.UDPAddrFromAddrPort(pAddr))
+	}
+
+	if len(udpAddrs) == 0 {
+		return nil, fmt.Errorf("no valid addresses")
+	}
+
+	clientId, err := tl.Hash(PublicKeyED25519{Key: key})
+	if err != nil {
+		return nil, err
+	}
+
+	g.mx.RLock()
+	existing := g.peers[string(clientId)]
+	g.mx.RUnlock()
+
+	if existing != nil || len(udpAddrs) == 1 {
+		// nothing to choose from
+		return g.registerClient(udpAddrs[0], key, string(clientId))
+	}
+
+	peer, err := g.registerClient(udpAddrs[0], key, string(clientId))
+	if err != nil {
+		return nil, err
+	}
+
+	a, ok := peer.client.(*ADNL)
+	if !ok {
+		return peer, nil
+	}
+
+	value := int64(time.Now().UnixNano())
+	answered, stop := a.expectPong(value)
+	defer stop()
+
+	var lastErr error
+	sent := 0
+	for _, addr := range udpAddrs {
+		buf, err := a.buildRequest(nil, MessagePing{Value: value})
+		if err != nil {
+			peer.Close()
+			return nil, fmt.Errorf("failed to build ping: %w", err)
+		}
+
+		if err = g.write(time.Now().Add(HappyEyeballsDelay), addr, buf); err != nil {
+			// address is not reachable from our network, try next immediately
+			lastErr = err
+			continue
+		}
+		sent++
+
+		select {
+		case <-answered:
+			// address is already updated to the answered one, by the listener
+			return peer, nil
+		case <-ctx.Done():
+			peer.Close()
+			return nil, ctx.Err()
+		case <-time.After(HappyEyeballsDelay):
+		}
+	}
+
+	if sent == 0 {
+		peer.Close()
+		return nil, fmt.Errorf("failed to send ping to any address: %w", lastErr)
+	}
+
+	select {
+	case <-answered:
+		return peer, nil
+	case <-ctx.Done():
+		peer.Close()
+		return nil, fmt.Errorf("no address answered: %w", ctx.Err())
+	}
+}
+
 func (g *Gateway) SetConnectionHandler(handler func(client Peer) error) {
 	g.connHandler = handler
 }
@@ -432,6 +545,17 @@ func (g *Gateway) Close() error {
 }
 
 func (g *Gateway) write(deadline time.Time, addr net.Addr, buf []byte) error {
+	if ra, ok := addr.(*relayedAddr); ok {
+		// peer has reached us through relay, so we answer through it too
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+
+		if err := ra.relay.SendCustomMessage(ctx, RelayPacket{Addr: ra.addr, Data: buf}); err != nil {
+			return fmt.Errorf("failed to send packet through relay: %w", err)
+		}
+		return nil
+	}
+
 	g.mx.Lock()
 	defer g.mx.Unlock()
 
@@ -0,0 +1,277 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/tl"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var ErrNoValidatorSet = errors.New("validator set is not known yet")
+
+// Block - block received from the public overlay, with already checked hashes and signatures
+type Block struct {
+	ID    *ton.BlockIDExt
+	Block *tlb.Block
+}
+
+type validatorsInfo struct {
+	catchain tlb.CatchainConfig
+	set      tlb.ValidatorSetAny
+}
+
+// BlockClient - follows shard public overlay and receives block broadcasts directly from the p2p network
+type BlockClient struct {
+	// MaxPeers - number of overlay peers to keep connected
+	MaxPeers int
+	// DiscoveryInterval - how often to search for new peers when we have less than MaxPeers
+	DiscoveryInterval time.Duration
+	// SkipSignatureCheck - accept blocks without validator signatures verification,
+	// only hashes of the block are checked then. For shard blocks signatures are checked against
+	// validator subgroup of the block shard, computed from the set and catchain seqno.
+	SkipSignatureCheck bool
+
+	overlay *publicOverlay
+
+	validators *validatorsInfo
+	seen       map[string]time.Time
+	mx         sync.RWMutex
+
+	blocks chan *Block
+}
+
+// NewBlockClient - creates client for the public overlay of the given shard,
+// zeroStateFileHash is a file hash of the network zero state, it can be taken from the global config.
+func NewBlockClient(gate Gateway, dht DHT, key ed25519.PrivateKey, zeroStateFileHash []byte, workchain int32, shard int64) (*BlockClient, error) {
+	o, err := newPublicOverlay(gate, dht, key, zeroStateFileHash, workchain, shard)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &BlockClient{
+		MaxPeers:          8,
+		DiscoveryInterval: 10 * time.Second,
+		overlay:           o,
+		seen:              map[string]time.Time{},
+		blocks:            make(chan *Block, 32),
+	}
+	o.broadcastHandler = c.broadcastHandler
+
+	return c, nil
+}
+
+// OverlayID - returns short id of the shard public overlay
+func (c *BlockClient) OverlayID() []byte {
+	return c.overlay.overlayID
+}
+
+// SetValidatorSet - sets trusted validator set which will be used for signatures verification,
+// later it is updated automatically from received key blocks. Key blocks are broadcast only in masterchain overlay,
+// so for shard overlay it should be set again from the new config when validator set is changed.
+func (c *BlockClient) SetValidatorSet(catchainConfig tlb.CatchainConfig, validatorSet tlb.ValidatorSetAny) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.validators = &validatorsInfo{
+		catchain: catchainConfig,
+		set:      validatorSet,
+	}
+}
+
+// SetValidatorSetFromConfig - sets trusted validator set from blockchain config, it must contain params 28 and 34
+func (c *BlockClient) SetValidatorSetFromConfig(cfg *ton.BlockchainConfig) error {
+	info, err := parseValidatorsInfo(cfg.Get(28), cfg.Get(34))
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	c.validators = info
+	c.mx.Unlock()
+	return nil
+}
+
+// Blocks - returns channel with verified blocks, blocks are delivered while Run is active
+func (c *BlockClient) Blocks() <-chan *Block {
+	return c.blocks
+}
+
+// Run - connects to overlay peers and keeps enough of them connected until context is done
+func (c *BlockClient) Run(ctx context.Context) error {
+	for {
+		c.overlay.discover(ctx, c.MaxPeers)
+
+		select {
+		case <-ctx.Done():
+			c.overlay.close()
+			return ctx.Err()
+		case <-time.After(c.DiscoveryInterval):
+		}
+	}
+}
+
+func (c *BlockClient) broadcastHandler(msg tl.Serializable, trusted bool) error {
+	var bb *BlockBroadcast
+	switch t := msg.(type) {
+	case BlockBroadcast:
+		bb = &t
+	case *BlockBroadcast:
+		bb = t
+	default:
+		// other broadcasts are not interesting for us
+		return nil
+	}
+
+	blk, err := c.processBlockBroadcast(bb)
+	if err != nil {
+		return fmt.Errorf("failed to process block broadcast: %w", err)
+	}
+	if blk == nil {
+		// already seen
+		return nil
+	}
+
+	select {
+	case c.blocks <- blk:
+	default:
+		return fmt.Errorf("blocks channel is full, block %d skipped", blk.ID.SeqNo)
+	}
+	return nil
+}
+
+func (c *BlockClient) processBlockBroadcast(bb *BlockBroadcast) (*Block, error) {
+	id := &ton.BlockIDExt{
+		Workchain: bb.ID.Workchain,
+		Shard:     bb.ID.Shard,
+		SeqNo:     uint32(bb.ID.Seqno),
+		RootHash:  bb.ID.RootHash,
+		FileHash:  bb.ID.FileHash,
+	}
+
+	if id.Workchain != c.overlay.shard.Workchain {
+		return nil, fmt.Errorf("block of unexpected workchain %d", id.Workchain)
+	}
+
+	tm := time.Now()
+	c.mx.Lock()
+	if _, ok := c.seen[string(id.RootHash)]; ok {
+		c.mx.Unlock()
+		return nil, nil
+	}
+	if len(c.seen) > 512 {
+		for k, at := range c.seen {
+			if at.Add(5 * time.Minute).Before(tm) {
+				delete(c.seen, k)
+			}
+		}
+	}
+	validators := c.validators
+	c.mx.Unlock()
+
+	fileHash := sha256.Sum256(bb.Data)
+	if !bytes.Equal(fileHash[:], id.FileHash) {
+		return nil, fmt.Errorf("incorrect block file hash")
+	}
+
+	root, err := cell.FromBOC(bb.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block boc: %w", err)
+	}
+
+	if !bytes.Equal(root.Hash(), id.RootHash) {
+		return nil, fmt.Errorf("incorrect block root hash")
+	}
+
+	var block tlb.Block
+	if err = tlb.LoadFromCell(&block, root.BeginParse()); err != nil {
+		return nil, fmt.Errorf("failed to parse block: %w", err)
+	}
+
+	if block.BlockInfo.SeqNo != id.SeqNo {
+		return nil, fmt.Errorf("incorrect block seqno")
+	}
+
+	if !c.SkipSignatureCheck {
+		if validators == nil {
+			return nil, ErrNoValidatorSet
+		}
+
+		if block.BlockInfo.GenCatchainSeqno != uint32(bb.CatchainSeqno) {
+			return nil, fmt.Errorf("incorrect catchain seqno")
+		}
+
+		sigs := &ton.SignatureSet{
+			ValidatorSetHash: bb.ValidatorSetHash,
+			CatchainSeqno:    bb.CatchainSeqno,
+			Signatures:       make([]ton.Signature, len(bb.Signatures)),
+		}
+		for i, s := range bb.Signatures {
+			sigs.Signatures[i] = ton.Signature{
+				NodeIDShort: s.Who,
+				Signature:   s.Signature,
+			}
+		}
+
+		if err = ton.CheckBlockSignatures(id, sigs, validators.catchain, validators.set); err != nil {
+			return nil, fmt.Errorf("failed to verify block signatures: %w", err)
+		}
+	}
+
+	if block.BlockInfo.KeyBlock && block.Extra != nil && block.Extra.Custom != nil && block.Extra.Custom.ConfigParams != nil {
+		params := block.Extra.Custom.ConfigParams.Config.Params
+
+		var catchainCfg, validatorsCfg *cell.Cell
+		if v := params.GetByIntKey(big.NewInt(28)); v != nil {
+			catchainCfg, _ = v.PeekRef(0)
+		}
+		if v := params.GetByIntKey(big.NewInt(34)); v != nil {
+			validatorsCfg, _ = v.PeekRef(0)
+		}
+
+		// switch to the new validator set, announced in the verified key block
+		if info, err := parseValidatorsInfo(catchainCfg, validatorsCfg); err == nil {
+			c.mx.Lock()
+			c.validators = info
+			c.mx.Unlock()
+		}
+	}
+
+	c.mx.Lock()
+	if _, ok := c.seen[string(id.RootHash)]; ok {
+		// concurrently processed by another peer's broadcast
+		c.mx.Unlock()
+		return nil, nil
+	}
+	c.seen[string(id.RootHash)] = tm
+	c.mx.Unlock()
+
+	return &Block{
+		ID:    id,
+		Block: &block,
+	}, nil
+}
+
+func parseValidatorsInfo(catchainCfg, validatorsCfg *cell.Cell) (*validatorsInfo, error) {
+	if catchainCfg == nil || validatorsCfg == nil {
+		return nil, fmt.Errorf("config params 28 and 34 are required")
+	}
+
+	var info validatorsInfo
+	if err := tlb.LoadFromCell(&info.catchain, catchainCfg.BeginParse()); err != nil {
+		return nil, fmt.Errorf("failed to parse catchain config: %w", err)
+	}
+	if err := tlb.LoadFromCell(&info.set, validatorsCfg.BeginParse()); err != nil {
+		return nil, fmt.Errorf("failed to parse validators config: %w", err)
+	}
+	return &info, nil
+}
@@ -0,0 +1,110 @@
+package node
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/tl"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+func TestShardPublicOverlayID(t *testing.T) {
+	zero := make([]byte, 32)
+	id := ShardPublicOverlayID{Workchain: -1, Shard: -0x8000000000000000, ZeroStateFileHash: zero}
+
+	key, err := id.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := tl.Hash(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, full) {
+		t.Fatal("overlay key should be a hash of shard overlay id")
+	}
+
+	short, err := id.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := tl.Hash(adnl.PublicKeyOverlay{Key: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(short, expected) {
+		t.Fatal("incorrect overlay short id")
+	}
+}
+
+func TestBlockClient_BlockBroadcast(t *testing.T) {
+	boc, _ := hex.DecodeString("b5ee9c72e1021c0100040b00001c00c400de0170020402a0033c036a037c0387039e03b6041c048204ce04ea0536055405a005ec060406200700077007bc080908100817041011ef55aaffffff110102030402a09bc7a98700000000840101c745200000000100000000000000000000000000634e94ec00001d367caaae4000001d367caaae419bbc68ac00058fb00173ed920173bfbec400000003000000000000002e05060211b8e48dfb43b9aca00407080a8a04250ec78adc9d082383679c3289edc662b628be0e34e51a8f7c412e98d24c8a5fb59960f376a6ad4dce93f406ce904add5a2aea140c99b877d02f67f1cd1e5f51021902190c0d03894a33f6fdb1c342502d7261843b4a3bfdbfb766c45705b7c4410af03c358431620ff05a79b1be0d76ede085c08726e04bad3c5779d949364eb56540f06c2c49b98d514111401a1b1b009800001d367c9b6c040173ed92b57df82537164b18661e22f620e1a7a15826a73d7402eef9433d55c030232370a7caa150ac8f2f4c74cb5c77e6671edb6f8accd65c683faf6e48a88720b2c72d009800001d367c9b6c0101c7451f78d2820caf6a5f100a444450ddab2f7754bbce7c6027dce5349269227866124a33b3efd318a7ec75c8f26844fd4dce5f581927f670a0087d7fec56658b487d720225826b977bb75290e16c135cbbddba94870b40080909000d0010ee6b2800080201200a0b0013be000003bc91627aea900013bfffffffbc8b96fc9c50235b9023afe2ffffff110000000000000000000000000001c7451f00000001634e94e900001d367c9b6c010173ed91200e0f10235b9023afe2ffffff110000000000000000000000000001c7452000000001634e94ec00001d367caaae410173ed9220141516284801017e49cb3c190a5033a93c907c6631d4459cf4bf71f57f041dd14270fb919423dc000122138209ae5deedd4a4385b011192848010125e39d851243cee82c062dd588cfa4587461b7869f68023bad26988d33bf8a24000223130104d72ef76ea521c2d81213192848010105a0d0f5cf8e9d2d98f032e935e8de2208463332de6c74af0b9d5cfc2bc2802102162848010157c418ac5021e527850e982354ed5a21fd7a0b0ac719e443fcd3c80f496dc4db003401110000000000000000501722138209ae5deedd4a4385b0181921d90000000000000000ffffffffffffffff826b977bb75290e16bb5f5e54ddd448c900001d367c9b6c040173ed92b57df82537164b18661e22f620e1a7a15826a73d7402eef9433d55c030232370a7caa150ac8f2f4c74cb5c77e6671edb6f8accd65c683faf6e48a88720b2c72d819006bb0400000000000000000b9f6c900000e9b3e4db601ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffc0284801012aa19c773967de4112363f58e8331a68fb2b3fcb1d55daf352b93c497a019ce4021728480101b3e9649d10ccb379368e81a3a7e8e49c8eb53f6acc69b0ba2ffa80082f70ee39000100030020000102b1e6b8f1")
+	root, err := cell.FromBOC(boc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileHash := sha256.Sum256(boc)
+
+	var block tlb.Block
+	if err = tlb.LoadFromCell(&block, root.BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	c, err := NewBlockClient(nil, nil, key, make([]byte, 32), 0, -0x8000000000000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb := BlockBroadcast{
+		ID: BlockIDExt{
+			Workchain: 0,
+			Shard:     -0x8000000000000000,
+			Seqno:     int32(block.BlockInfo.SeqNo),
+			RootHash:  root.Hash(),
+			FileHash:  fileHash[:],
+		},
+		Data: boc,
+	}
+
+	if err = c.broadcastHandler(bb, true); !errors.Is(err, ErrNoValidatorSet) {
+		t.Fatal("block should not be accepted without validator set", err)
+	}
+
+	c.SkipSignatureCheck = true
+
+	bad := bb
+	bad.ID.FileHash = make([]byte, 32)
+	if err = c.broadcastHandler(bad, true); err == nil {
+		t.Fatal("block with incorrect file hash should not be accepted")
+	}
+
+	bad = bb
+	bad.ID.Seqno++
+	if _, err = c.processBlockBroadcast(&bad); err == nil {
+		t.Fatal("block with incorrect seqno should not be accepted")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = c.broadcastHandler(bb, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(c.Blocks()) != 1 {
+		t.Fatal("block should be delivered once")
+	}
+
+	blk := <-c.Blocks()
+	if !bytes.Equal(blk.ID.RootHash, root.Hash()) || blk.Block == nil {
+		t.Fatal("incorrect block")
+	}
+}
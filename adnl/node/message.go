@@ -1,6 +1,17 @@
 package node
 
-import "github.com/chaindead/tonutils-go/tl"
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/adnl/overlay"
+	"github.com/chaindead/tonutils-go/tl"
+	"github.com/chaindead/tonutils-go/tlb"
+)
 
 func init() {
 	tl.Register(ExternalMessage{}, "tonNode.externalMessage data:bytes = tonNode.ExternalMessage")
@@ -14,3 +25,104 @@ type ExternalMessage struct {
 type NewExternalMessageBroadcast struct {
 	Message ExternalMessage `tl:"struct"`
 }
+
+// MessageBroadcaster - sends external messages directly to validators through
+// public overlays of masterchain and basechain, without liteservers
+type MessageBroadcaster struct {
+	// PeersPerMessage - number of overlay peers which will receive each message
+	PeersPerMessage int
+
+	key      ed25519.PrivateKey
+	overlays map[int32]*publicOverlay
+	mx       sync.Mutex
+}
+
+// NewMessageBroadcaster - creates broadcaster, overlays are joined lazily, on the first message to the workchain.
+// zeroStateFileHash is a file hash of the network zero state, it can be taken from the global config.
+func NewMessageBroadcaster(gate Gateway, dht DHT, key ed25519.PrivateKey, zeroStateFileHash []byte) (*MessageBroadcaster, error) {
+	b := &MessageBroadcaster{
+		PeersPerMessage: 5,
+		key:             key,
+		overlays:        map[int32]*publicOverlay{},
+	}
+
+	for _, wc := range []int32{address.MasterchainID, 0} {
+		o, err := newPublicOverlay(gate, dht, key, zeroStateFileHash, wc, -0x8000000000000000)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init overlay of workchain %d: %w", wc, err)
+		}
+		b.overlays[wc] = o
+	}
+	return b, nil
+}
+
+// SendExternalMessage - same as BroadcastExternalMessage, can be used as wallet.MessageSender
+func (b *MessageBroadcaster) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	return b.BroadcastExternalMessage(ctx, msg)
+}
+
+// BroadcastExternalMessage - sends message as overlay broadcast to several peers of the destination workchain overlay,
+// returns error only when none of the peers accepted it.
+func (b *MessageBroadcaster) BroadcastExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	o := b.overlays[msg.DstAddr.Workchain()]
+	if o == nil {
+		return fmt.Errorf("workchain %d is not supported", msg.DstAddr.Workchain())
+	}
+
+	c, err := tlb.ToCell(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize external message: %w", err)
+	}
+
+	data, err := tl.Serialize(NewExternalMessageBroadcast{
+		Message: ExternalMessage{Data: c.ToBOCWithFlags(false)},
+	}, true)
+	if err != nil {
+		return fmt.Errorf("failed to serialize message broadcast: %w", err)
+	}
+
+	broadcast, err := overlay.NewBroadcast(b.key, data, nil)
+	if err != nil {
+		return fmt.Errorf("failed to sign broadcast: %w", err)
+	}
+
+	b.mx.Lock()
+	o.discover(ctx, b.PeersPerMessage)
+	b.mx.Unlock()
+
+	peers := o.connectedPeers()
+	if len(peers) == 0 {
+		return fmt.Errorf("no overlay peers found")
+	}
+	if len(peers) > b.PeersPerMessage {
+		peers = peers[:b.PeersPerMessage]
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(peers))
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p *overlay.ADNLOverlayWrapper) {
+			defer wg.Done()
+
+			ctxSend, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			errs[i] = p.SendCustomMessage(ctxSend, *broadcast)
+		}(i, p)
+	}
+	wg.Wait()
+
+	for _, e := range errs {
+		if e == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to send broadcast to all peers, last err: %w", errs[len(errs)-1])
+}
+
+// Close - disconnects from all overlay peers
+func (b *MessageBroadcaster) Close() {
+	for _, o := range b.overlays {
+		o.close()
+	}
+}
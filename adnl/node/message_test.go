@@ -0,0 +1,66 @@
+package node
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/adnl/overlay"
+	"github.com/chaindead/tonutils-go/tl"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+func TestMessageBroadcast(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+
+	data, err := tl.Serialize(NewExternalMessageBroadcast{Message: ExternalMessage{Data: []byte{1, 2, 3}}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := overlay.NewBroadcast(key, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := tl.Serialize(*b, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed overlay.Broadcast
+	if _, err = tl.Parse(&parsed, raw, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = parsed.CheckSignature(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg NewExternalMessageBroadcast
+	if _, err = tl.Parse(&msg, parsed.Data, true); err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Message.Data) != string([]byte{1, 2, 3}) {
+		t.Fatal("incorrect message data")
+	}
+
+	parsed.Data = []byte{1}
+	if err = parsed.CheckSignature(); err == nil {
+		t.Fatal("modified broadcast should not pass signature check")
+	}
+
+	mb, err := NewMessageBroadcaster(nil, nil, key, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mb.BroadcastExternalMessage(context.Background(), &tlb.ExternalMessage{
+		DstAddr: address.NewAddress(0, 7, make([]byte, 32)),
+		Body:    cell.BeginCell().EndCell(),
+	})
+	if err == nil {
+		t.Fatal("unsupported workchain should not be accepted")
+	}
+}
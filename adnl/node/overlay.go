@@ -0,0 +1,144 @@
+package node
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/adnl/address"
+	"github.com/chaindead/tonutils-go/adnl/dht"
+	"github.com/chaindead/tonutils-go/adnl/overlay"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+const _MaxBroadcastSize = 16 << 20
+
+type DHT interface {
+	FindOverlayNodes(ctx context.Context, overlayKey []byte, continuation ...*dht.Continuation) (*overlay.NodesList, *dht.Continuation, error)
+	FindAddresses(ctx context.Context, key []byte) (*address.List, ed25519.PublicKey, error)
+}
+
+type Gateway interface {
+	RegisterClientAny(ctx context.Context, addrs []string, key ed25519.PublicKey) (adnl.Peer, error)
+}
+
+// publicOverlay - keeps connections with peers of the shard public overlay
+type publicOverlay struct {
+	gate Gateway
+	dht  DHT
+
+	shard      ShardPublicOverlayID
+	overlayKey []byte
+	overlayID  []byte
+
+	peers *overlay.PeerManager
+
+	broadcastHandler func(msg tl.Serializable, trusted bool) error
+}
+
+func newPublicOverlay(gate Gateway, dht DHT, key ed25519.PrivateKey, zeroStateFileHash []byte, workchain int32, shard int64) (*publicOverlay, error) {
+	id := ShardPublicOverlayID{
+		Workchain:         workchain,
+		Shard:             shard,
+		ZeroStateFileHash: zeroStateFileHash,
+	}
+
+	overlayKey, err := id.Key()
+	if err != nil {
+		return nil, fmt.Errorf("failed to calc overlay key: %w", err)
+	}
+
+	overlayID, err := id.ID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to calc overlay id: %w", err)
+	}
+
+	o := &publicOverlay{
+		gate:       gate,
+		dht:        dht,
+		shard:      id,
+		overlayKey: overlayKey,
+		overlayID:  overlayID,
+	}
+
+	o.peers, err = overlay.NewPeerManager(overlayKey, key, &dhtNodes{dht: dht}, o.connect)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init peer manager: %w", err)
+	}
+	// we are only a client of the public overlay, no need to announce ourselves in dht
+	o.peers.StoreInterval = 0
+
+	return o, nil
+}
+
+func (o *publicOverlay) connectedPeers() []*overlay.ADNLOverlayWrapper {
+	return o.peers.Neighbours()
+}
+
+// discover - checks connected peers and connects to new ones till we have maxPeers
+func (o *publicOverlay) discover(ctx context.Context, maxPeers int) {
+	o.peers.TargetNeighbours = maxPeers
+	o.peers.Refresh(ctx)
+}
+
+func (o *publicOverlay) connect(ctx context.Context, node overlay.Node) (*overlay.ADNLOverlayWrapper, error) {
+	id, err := tl.Hash(node.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calc node id: %w", err)
+	}
+
+	addrs, pub, err := o.dht.FindAddresses(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find node address in dht: %w", err)
+	}
+
+	list := make([]string, 0, len(addrs.Addresses))
+	for _, a := range addrs.Addresses {
+		list = append(list, a.String())
+	}
+
+	peer, err := o.gate.RegisterClientAny(ctx, list, pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to node %s: %w", hex.EncodeToString(id), err)
+	}
+
+	ov := overlay.CreateExtendedADNL(peer).CreateOverlayWithSettings(o.overlayID, _MaxBroadcastSize, true, true)
+	if h := o.broadcastHandler; h != nil {
+		ov.SetBroadcastHandler(h)
+	}
+	return ov, nil
+}
+
+func (o *publicOverlay) close() {
+	o.peers.Close()
+}
+
+// dhtNodes - adapts DHT to the overlay peer manager
+type dhtNodes struct {
+	dht          DHT
+	continuation *dht.Continuation
+	mx           sync.Mutex
+}
+
+func (d *dhtNodes) FindOverlayNodes(ctx context.Context, overlayKey []byte) ([]overlay.Node, error) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	nodes, cont, err := d.dht.FindOverlayNodes(ctx, overlayKey, d.continuation)
+	if err != nil {
+		if errors.Is(err, dht.ErrDHTValueIsNotFound) {
+			d.continuation = nil
+		}
+		return nil, err
+	}
+	d.continuation = cont
+	return nodes.List, nil
+}
+
+func (d *dhtNodes) StoreOverlayNodes(ctx context.Context, overlayKey []byte, nodes []overlay.Node) error {
+	return fmt.Errorf("not supported")
+}
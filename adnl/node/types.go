@@ -1,6 +1,9 @@
 package node
 
-import "github.com/chaindead/tonutils-go/tl"
+import (
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/tl"
+)
 
 func init() {
 	tl.Register(ShardPublicOverlayID{}, "tonNode.shardPublicOverlayId workchain:int shard:long zero_state_file_hash:int256 = tonNode.ShardPublicOverlayId")
@@ -11,3 +14,18 @@ type ShardPublicOverlayID struct {
 	Shard             int64  `tl:"long"`
 	ZeroStateFileHash []byte `tl:"int256"`
 }
+
+// Key - returns overlay key (full id), it is a hash of the shard overlay description,
+// used to find overlay nodes in DHT and to sign overlay nodes
+func (s ShardPublicOverlayID) Key() ([]byte, error) {
+	return tl.Hash(s)
+}
+
+// ID - returns short id of the overlay, used in overlay queries and messages
+func (s ShardPublicOverlayID) ID() ([]byte, error) {
+	key, err := s.Key()
+	if err != nil {
+		return nil, err
+	}
+	return tl.Hash(adnl.PublicKeyOverlay{Key: key})
+}
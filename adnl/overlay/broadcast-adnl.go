@@ -0,0 +1,405 @@
+package overlay
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/adnl/rldp"
+	"github.com/chaindead/tonutils-go/adnl/rldp/raptorq"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+const _MaxSimpleBroadcastSize = 768
+const _BroadcastSymbolSize = 768
+const _BroadcastMaxAge = 60 * time.Second
+
+// _BroadcastNeighbourTTL - neighbour connected for longer is replaced by a fresh one, to rotate fan out peers
+const _BroadcastNeighbourTTL = 10 * time.Minute
+
+type broadcastNeighbour struct {
+	peer        *ADNLOverlayWrapper
+	connectedAt time.Time
+}
+
+// PeerConnector - connects to overlay node, it is used to reach neighbours when broadcasting
+type PeerConnector func(ctx context.Context, node Node) (*ADNLOverlayWrapper, error)
+
+// SetBroadcastKey - sets key which will be used to sign our broadcasts, certificate is optional,
+// it can be issued by overlay owner to allow bigger or trusted broadcasts in private overlays
+func (a *ADNLOverlayWrapper) SetBroadcastKey(key ed25519.PrivateKey, certificate any) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if certificate == nil {
+		certificate = CertificateEmpty{}
+	}
+	a.broadcastKey = key
+	a.broadcastCert = certificate
+}
+
+// SetBroadcastNeighbours - enables fan out of our broadcasts to at most limit peers,
+// obtained from GetRandomPeers of this overlay peer and connected using connector
+func (a *ADNLOverlayWrapper) SetBroadcastNeighbours(connector PeerConnector, limit int) {
+	a.neighboursMx.Lock()
+	a.peerConnector = connector
+	a.maxNeighbours = limit
+
+	var evicted []broadcastNeighbour
+	if limit < 0 {
+		limit = 0
+	}
+	if len(a.neighbours) > limit {
+		evicted = append(evicted, a.neighbours[limit:]...)
+		a.neighbours = a.neighbours[:limit]
+	}
+	a.neighboursMx.Unlock()
+
+	for _, n := range evicted {
+		closeNeighbour(n.peer)
+	}
+}
+
+// Broadcast - sends simple signed broadcast to the peer and its neighbours,
+// serialized message should be not bigger than 768 bytes, use BroadcastFEC for bigger data
+func (a *ADNLOverlayWrapper) Broadcast(ctx context.Context, msg tl.Serializable) error {
+	key, cert := a.getBroadcastKey()
+	if key == nil {
+		return fmt.Errorf("broadcast key is not set")
+	}
+
+	data, err := tl.Serialize(msg, true)
+	if err != nil {
+		return fmt.Errorf("failed to serialize broadcast message: %w", err)
+	}
+
+	if len(data) > _MaxSimpleBroadcastSize {
+		return fmt.Errorf("too big message for simple broadcast (%d bytes), use fec broadcast", len(data))
+	}
+
+	b, err := NewBroadcast(key, data, cert)
+	if err != nil {
+		return fmt.Errorf("failed to create broadcast: %w", err)
+	}
+
+	id, err := b.CalcID()
+	if err != nil {
+		return fmt.Errorf("failed to calc broadcast id: %w", err)
+	}
+	// it may come back to us from the neighbours
+	a.markSeen(id)
+
+	return a.fanOut(ctx, func(ctx context.Context, peer *ADNLOverlayWrapper) error {
+		return peer.SendCustomMessage(ctx, *b)
+	})
+}
+
+// BroadcastFEC - splits data into raptorq symbols and sends them as signed fec broadcast parts to the peer and its neighbours
+func (a *ADNLOverlayWrapper) BroadcastFEC(ctx context.Context, data []byte) error {
+	key, cert := a.getBroadcastKey()
+	if key == nil {
+		return fmt.Errorf("broadcast key is not set")
+	}
+
+	if len(data) == 0 {
+		return fmt.Errorf("empty data")
+	}
+
+	enc, err := raptorq.NewRaptorQ(_BroadcastSymbolSize).CreateEncoder(data)
+	if err != nil {
+		return fmt.Errorf("failed to create raptorq object encoder: %w", err)
+	}
+
+	dataHash := sha256.Sum256(data)
+	tmpl := BroadcastFEC{
+		Source:      adnl.PublicKeyED25519{Key: key.Public().(ed25519.PublicKey)},
+		Certificate: cert,
+		DataHash:    dataHash[:],
+		DataSize:    int32(len(data)),
+		Flags:       0,
+		FEC: rldp.FECRaptorQ{
+			DataSize:     int32(len(data)),
+			SymbolSize:   _BroadcastSymbolSize,
+			SymbolsCount: int32(enc.BaseSymbolsNum()),
+		},
+		Date: int32(time.Now().Unix()),
+	}
+
+	broadcastHash, err := tmpl.CalcID()
+	if err != nil {
+		return fmt.Errorf("failed to calc broadcast id: %w", err)
+	}
+
+	// 120% of symbols, to be decodable even with some losses
+	num := enc.BaseSymbolsNum() + enc.BaseSymbolsNum()/5 + 1
+	parts := make([]BroadcastFEC, num)
+	for i := uint32(0); i < num; i++ {
+		part := tmpl
+		part.Seqno = int32(i)
+		part.Data = enc.GenSymbol(i)
+		if err = part.sign(key, broadcastHash); err != nil {
+			return fmt.Errorf("failed to sign part %d: %w", i, err)
+		}
+		parts[i] = part
+	}
+
+	return a.fanOut(ctx, func(ctx context.Context, peer *ADNLOverlayWrapper) error {
+		for _, part := range parts {
+			if err := peer.SendCustomMessage(ctx, part); err != nil {
+				return fmt.Errorf("failed to send part %d: %w", part.Seqno, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (t *BroadcastFEC) sign(key ed25519.PrivateKey, broadcastHash []byte) error {
+	partDataHash := sha256.Sum256(t.Data)
+
+	partHash, err := tl.Hash(&BroadcastFECPartID{
+		BroadcastHash: broadcastHash,
+		DataHash:      partDataHash[:],
+		Seqno:         t.Seqno,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compute hash id of the part: %w", err)
+	}
+
+	toSign, err := tl.Serialize(&BroadcastToSign{
+		Hash: partHash,
+		Date: t.Date,
+	}, true)
+	if err != nil {
+		return fmt.Errorf("failed to serialize broadcast for sign: %w", err)
+	}
+
+	t.Signature = ed25519.Sign(key, toSign)
+	return nil
+}
+
+func (a *ADNLOverlayWrapper) getBroadcastKey() (ed25519.PrivateKey, any) {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+	return a.broadcastKey, a.broadcastCert
+}
+
+// fanOut - sends to this peer and its neighbours in parallel, succeeds if at least one peer received it
+func (a *ADNLOverlayWrapper) fanOut(ctx context.Context, send func(ctx context.Context, peer *ADNLOverlayWrapper) error) error {
+	peers := append([]*ADNLOverlayWrapper{a}, a.getNeighbours(ctx)...)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(peers))
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p *ADNLOverlayWrapper) {
+			defer wg.Done()
+			if errs[i] = send(ctx, p); errs[i] != nil && p != a {
+				a.removeNeighbour(p)
+			}
+		}(i, p)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to send broadcast to any peer: %w", errs[0])
+}
+
+// getNeighbours - returns connected neighbours, connects new ones when there are not enough,
+// discovery and connection are done without lock, it is taken only to merge results
+func (a *ADNLOverlayWrapper) getNeighbours(ctx context.Context) []*ADNLOverlayWrapper {
+	a.neighboursMx.Lock()
+	connector, limit := a.peerConnector, a.maxNeighbours
+	if connector == nil || limit <= 0 {
+		a.neighboursMx.Unlock()
+		return nil
+	}
+
+	// replace the oldest neighbour when it is connected for too long
+	var stale *ADNLOverlayWrapper
+	if len(a.neighbours) > 0 && len(a.neighbours) >= limit && time.Since(a.neighbours[0].connectedAt) > _BroadcastNeighbourTTL {
+		stale = a.neighbours[0].peer
+		a.neighbours = a.neighbours[1:]
+	}
+
+	need := limit - len(a.neighbours)
+	known := map[string]bool{}
+	for _, n := range a.neighbours {
+		known[string(n.peer.GetID())] = true
+	}
+	a.neighboursMx.Unlock()
+
+	if stale != nil {
+		closeNeighbour(stale)
+	}
+
+	var connected []*ADNLOverlayWrapper
+	if need > 0 {
+		nodes, err := a.GetRandomPeers(ctx)
+		if err == nil {
+			for _, node := range nodes {
+				if len(connected) >= need {
+					break
+				}
+
+				if !bytes.Equal(node.Overlay, a.overlayId) || node.CheckSignature() != nil {
+					continue
+				}
+
+				id, err := tl.Hash(node.ID)
+				if err != nil || bytes.Equal(id, a.GetID()) || known[string(id)] {
+					continue
+				}
+
+				w, err := connector(ctx, node)
+				if err != nil {
+					continue
+				}
+				known[string(id)] = true
+				connected = append(connected, w)
+			}
+		}
+	}
+
+	var surplus []*ADNLOverlayWrapper
+
+	a.neighboursMx.Lock()
+	for _, w := range connected {
+		// list could be changed concurrently, while we were connecting
+		if len(a.neighbours) >= a.maxNeighbours || a.hasNeighbour(w.GetID()) {
+			surplus = append(surplus, w)
+			continue
+		}
+		a.neighbours = append(a.neighbours, broadcastNeighbour{
+			peer:        w,
+			connectedAt: time.Now(),
+		})
+	}
+
+	list := make([]*ADNLOverlayWrapper, 0, len(a.neighbours))
+	for _, n := range a.neighbours {
+		list = append(list, n.peer)
+	}
+	a.neighboursMx.Unlock()
+
+	for _, w := range surplus {
+		closeNeighbour(w)
+	}
+	return list
+}
+
+func (a *ADNLOverlayWrapper) hasNeighbour(id []byte) bool {
+	for _, n := range a.neighbours {
+		if bytes.Equal(n.peer.GetID(), id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *ADNLOverlayWrapper) removeNeighbour(w *ADNLOverlayWrapper) {
+	a.neighboursMx.Lock()
+	for i, n := range a.neighbours {
+		if n.peer == w {
+			a.neighbours = append(a.neighbours[:i], a.neighbours[i+1:]...)
+			a.neighboursMx.Unlock()
+
+			closeNeighbour(w)
+			return
+		}
+	}
+	a.neighboursMx.Unlock()
+}
+
+// closeNeighbours - disconnects all neighbours, used when overlay is closed
+func (a *ADNLOverlayWrapper) closeNeighbours() {
+	a.neighboursMx.Lock()
+	list := a.neighbours
+	a.neighbours = nil
+	a.neighboursMx.Unlock()
+
+	for _, n := range list {
+		closeNeighbour(n.peer)
+	}
+}
+
+func closeNeighbour(w *ADNLOverlayWrapper) {
+	w.Close()
+	w.ADNLWrapper.Close()
+}
+
+// markSeen - remembers broadcast, returns false if it was already seen
+func (a *ADNLOverlayWrapper) markSeen(id []byte) bool {
+	tm := time.Now()
+
+	a.streamsMx.Lock()
+	defer a.streamsMx.Unlock()
+
+	if _, ok := a.seenBroadcasts[string(id)]; ok {
+		return false
+	}
+
+	if len(a.seenBroadcasts) > 100 {
+		for k, at := range a.seenBroadcasts {
+			if at.Add(2 * _BroadcastMaxAge).Before(tm) {
+				delete(a.seenBroadcasts, k)
+			}
+		}
+	}
+	a.seenBroadcasts[string(id)] = tm
+	return true
+}
+
+func (a *ADNLOverlayWrapper) processBroadcast(t *Broadcast) error {
+	if time.Unix(int64(t.Date), 0).Add(_BroadcastMaxAge).Before(time.Now()) {
+		return fmt.Errorf("too old broadcast")
+	}
+
+	srcId, err := tl.Hash(t.Source)
+	if err != nil {
+		return fmt.Errorf("source key id serialize failed: %w", err)
+	}
+
+	checkRes, err := a.checkSource(srcId, t.Certificate, int32(len(t.Data)), false)
+	if err != nil {
+		return err
+	}
+
+	if checkRes == CertCheckResultForbidden {
+		return fmt.Errorf("not allowed")
+	}
+
+	if err = t.CheckSignature(); err != nil {
+		return err
+	}
+
+	id, err := t.CalcID()
+	if err != nil {
+		return fmt.Errorf("failed to calc broadcast hash: %w", err)
+	}
+
+	if !a.markSeen(id) {
+		// duplicate
+		return nil
+	}
+
+	var res any
+	if _, err = tl.Parse(&res, t.Data, true); err != nil {
+		return fmt.Errorf("failed to parse broadcast message: %w", err)
+	}
+
+	if bHandler := a.broadcastHandler; bHandler != nil {
+		if err = bHandler(res, checkRes == CertCheckResultTrusted); err != nil {
+			return fmt.Errorf("failed to process broadcast message: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package overlay
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+type mockADNL struct {
+	id       []byte
+	sent     []tl.Serializable
+	answers  []tl.Serializable
+	queryErr error
+	mx       sync.Mutex
+
+	customHandler func(msg *adnl.MessageCustom) error
+	queryHandler  func(msg *adnl.MessageQuery) error
+}
+
+func (m *mockADNL) SetCustomMessageHandler(handler func(msg *adnl.MessageCustom) error) {
+	m.customHandler = handler
+}
+func (m *mockADNL) SetQueryHandler(handler func(msg *adnl.MessageQuery) error) {
+	m.queryHandler = handler
+}
+func (m *mockADNL) SetDisconnectHandler(handler func(addr string, key ed25519.PublicKey)) {}
+func (m *mockADNL) GetDisconnectHandler() func(addr string, key ed25519.PublicKey)        { return nil }
+func (m *mockADNL) Query(ctx context.Context, req, result tl.Serializable) error {
+	return m.queryErr
+}
+func (m *mockADNL) Answer(ctx context.Context, queryID []byte, result tl.Serializable) error {
+	m.answers = append(m.answers, result)
+	return nil
+}
+func (m *mockADNL) RemoteAddr() string { return "" }
+func (m *mockADNL) GetID() []byte      { return m.id }
+func (m *mockADNL) Close()             {}
+
+func (m *mockADNL) SendCustomMessage(ctx context.Context, req tl.Serializable) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.sent = append(m.sent, req)
+	return nil
+}
+
+func TestADNLOverlayWrapper_Broadcast(t *testing.T) {
+	overlayId := make([]byte, 32)
+	_, _ = rand.Read(overlayId)
+
+	_, key, _ := ed25519.GenerateKey(nil)
+
+	senderPeer := &mockADNL{}
+	sender := CreateExtendedADNL(senderPeer).WithOverlay(overlayId)
+
+	if err := sender.Broadcast(context.Background(), GetRandomPeers{}); err == nil {
+		t.Fatal("broadcast without key should fail")
+	}
+	sender.SetBroadcastKey(key, nil)
+
+	receiverPeer := &mockADNL{}
+	receiver := CreateExtendedADNL(receiverPeer).CreateOverlayWithSettings(overlayId, 1<<20, true, true)
+
+	var received []tl.Serializable
+	receiver.SetBroadcastHandler(func(msg tl.Serializable, trusted bool) error {
+		if !trusted {
+			t.Fatal("should be trusted")
+		}
+		received = append(received, msg)
+		return nil
+	})
+
+	deliver := func() {
+		for _, m := range senderPeer.sent {
+			if err := receiverPeer.customHandler(&adnl.MessageCustom{Data: m}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		senderPeer.sent = nil
+	}
+
+	msg := GetRandomPeers{List: NodesList{List: []Node{}}}
+	if err := sender.Broadcast(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(senderPeer.sent) != 1 {
+		t.Fatal("broadcast should be sent once")
+	}
+
+	dup := senderPeer.sent[0]
+	deliver()
+	senderPeer.sent = []tl.Serializable{dup}
+	deliver()
+
+	if len(received) != 1 {
+		t.Fatal("broadcast should be delivered once, got", len(received))
+	}
+	if _, ok := received[0].(GetRandomPeers); !ok {
+		t.Fatal("incorrect broadcast message")
+	}
+
+	data := make([]byte, 10000)
+	_, _ = rand.Read(data)
+
+	prefix, err := tl.Serialize(FECCompleted{Hash: make([]byte, 32)}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(prefix, data...)
+
+	if err = sender.BroadcastFEC(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	if len(senderPeer.sent) <= 10000/_BroadcastSymbolSize {
+		t.Fatal("not enough fec parts sent")
+	}
+
+	received = nil
+	for _, m := range senderPeer.sent {
+		// parts of the finished stream are answered with completion
+		if err = receiverPeer.customHandler(&adnl.MessageCustom{Data: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(received) != 1 {
+		t.Fatal("fec broadcast should be decoded once, got", len(received))
+	}
+	if c, ok := received[0].(FECCompleted); !ok || !bytes.Equal(c.Hash, make([]byte, 32)) {
+		t.Fatal("incorrect fec broadcast message")
+	}
+
+	// tampered broadcast
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	b, err := NewBroadcast(otherKey, []byte{1, 2, 3, 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Data = []byte{4, 3, 2, 1}
+	if err = receiverPeer.customHandler(&adnl.MessageCustom{Data: WrapMessage(overlayId, *b)}); err == nil {
+		t.Fatal("tampered broadcast should not be accepted")
+	}
+}
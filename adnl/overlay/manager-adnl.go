@@ -139,6 +139,10 @@ func (a *ADNLWrapper) customHandler(msg *adnl.MessageCustom) error {
 
 		switch t := obj.(type) {
 		case Broadcast:
+			if err := o.processBroadcast(&t); err != nil {
+				return fmt.Errorf("failed to process broadcast: %w", err)
+			}
+			return nil
 		case BroadcastFECShort:
 		case BroadcastFEC:
 			if err := o.processFECBroadcast(&t); err != nil {
@@ -49,6 +49,15 @@ type ADNLOverlayWrapper struct {
 
 	broadcastHandler func(msg tl.Serializable, trusted bool) error
 
+	broadcastKey  ed25519.PrivateKey
+	broadcastCert any
+
+	peerConnector  PeerConnector
+	maxNeighbours  int
+	neighbours     []broadcastNeighbour
+	neighboursMx   sync.Mutex
+	seenBroadcasts map[string]time.Time
+
 	*ADNLWrapper
 }
 
@@ -72,6 +81,7 @@ func (a *ADNLWrapper) CreateOverlayWithSettings(id []byte, maxUnauthBroadcastSiz
 		overlayId:         id,
 		ADNLWrapper:       a,
 		broadcastStreams:  map[string]*fecBroadcastStream{},
+		seenBroadcasts:    map[string]time.Time{},
 		allowFEC:          allowBroadcastFEC,
 		maxUnauthSize:     maxUnauthBroadcastSize,
 		trustUnauthorized: trustUnauthorizedBroadcast,
@@ -100,7 +110,7 @@ func (a *ADNLWrapper) UnregisterOverlay(id []byte) {
 }
 
 func (a *ADNLOverlayWrapper) SendCustomMessage(ctx context.Context, req tl.Serializable) error {
-	return a.ADNLWrapper.SendCustomMessage(ctx, []tl.Serializable{Query{Overlay: a.overlayId}, req})
+	return a.ADNLWrapper.SendCustomMessage(ctx, WrapMessage(a.overlayId, req))
 }
 
 func (a *ADNLOverlayWrapper) Query(ctx context.Context, req, result tl.Serializable) error {
@@ -134,6 +144,7 @@ func (a *ADNLOverlayWrapper) SetBroadcastHandler(handler func(msg tl.Serializabl
 
 func (a *ADNLOverlayWrapper) Close() {
 	a.ADNLWrapper.UnregisterOverlay(a.overlayId)
+	a.closeNeighbours()
 }
 
 func (a *ADNLOverlayWrapper) checkRules(keyId string, dataSize int32, isFEC bool) CertCheckResult {
@@ -163,6 +174,59 @@ func (a *ADNLOverlayWrapper) checkRules(keyId string, dataSize int32, isFEC bool
 	return CertCheckResultNeedCheck
 }
 
+// checkSource - checks rules for the broadcast source and its certificate, returns maximal allowed trust level
+func (a *ADNLOverlayWrapper) checkSource(srcId []byte, certificate any, dataSize int32, isFEC bool) (CertCheckResult, error) {
+	checkRes := a.checkRules(string(srcId), dataSize, isFEC)
+	if checkRes == CertCheckResultTrusted || certificate == nil {
+		return checkRes, nil
+	}
+
+	var err error
+	var issuerId []byte
+
+	var certRes CertCheckResult
+	switch crt := certificate.(type) {
+	case CheckableCert:
+		certRes, err = crt.Check(srcId, a.overlayId, dataSize, isFEC)
+		if err != nil {
+			return CertCheckResultForbidden, fmt.Errorf("cert check failed: %w", err)
+		}
+		if certRes == CertCheckResultForbidden {
+			break
+		}
+
+		var issuedBy any
+		switch cert := crt.(type) {
+		case Certificate:
+			issuedBy = cert.IssuedBy
+		case CertificateV2:
+			issuedBy = cert.IssuedBy
+		}
+
+		issuerId, err = tl.Hash(issuedBy)
+		if err != nil {
+			return CertCheckResultForbidden, fmt.Errorf("issuer key id serialize failed: %w", err)
+		}
+	case CertificateEmpty:
+	default:
+		return CertCheckResultForbidden, fmt.Errorf("not supported cert type %s", reflect.TypeOf(certificate).String())
+	}
+
+	if issuerId != nil {
+		issuerRes := a.checkRules(string(issuerId), dataSize, isFEC)
+		if issuerRes > certRes {
+			// we consider minimal of these 2
+			issuerRes = certRes
+		}
+
+		if issuerRes > checkRes {
+			// we consider maximal of these 2
+			checkRes = issuerRes
+		}
+	}
+	return checkRes, nil
+}
+
 func (a *ADNLOverlayWrapper) processFECBroadcast(t *BroadcastFEC) error {
 	broadcastHash, err := t.CalcID()
 	if err != nil {
@@ -219,50 +283,9 @@ func (a *ADNLOverlayWrapper) processFECBroadcast(t *BroadcastFEC) error {
 			return fmt.Errorf("source key id serialize failed: %w", err)
 		}
 
-		checkRes := a.checkRules(string(srcId), t.DataSize, true)
-		if checkRes != CertCheckResultTrusted && t.Certificate != nil {
-			var issuerId []byte
-
-			var certRes CertCheckResult
-			switch crt := t.Certificate.(type) {
-			case CheckableCert:
-				certRes, err = crt.Check(srcId, a.overlayId, t.DataSize, true)
-				if err != nil {
-					return fmt.Errorf("cert check failed: %w", err)
-				}
-				if certRes == CertCheckResultForbidden {
-					break
-				}
-
-				var issuedBy any
-				switch cert := crt.(type) {
-				case Certificate:
-					issuedBy = cert.IssuedBy
-				case CertificateV2:
-					issuedBy = cert.IssuedBy
-				}
-
-				issuerId, err = tl.Hash(issuedBy)
-				if err != nil {
-					return fmt.Errorf("issuer key id serialize failed: %w", err)
-				}
-			case CertificateEmpty:
-			default:
-				return fmt.Errorf("not supported cert type %s", reflect.TypeOf(t.Certificate).String())
-			}
-
-			if issuerId != nil {
-				issuerRes := a.checkRules(string(issuerId), t.DataSize, true)
-				if issuerRes > certRes {
-					// we consider minimal of these 2
-					issuerRes = certRes
-				}
-
-				if issuerRes > checkRes {
-					// we consider maximal of these 2
-					checkRes = issuerRes
-				}
-			}
+		checkRes, err := a.checkSource(srcId, t.Certificate, t.DataSize, true)
+		if err != nil {
+			return err
 		}
 
 		if checkRes == CertCheckResultForbidden {
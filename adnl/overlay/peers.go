@@ -0,0 +1,407 @@
+package overlay
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+// NodesDHT - source of overlay nodes, dht.Client.OverlayNodes() can be used
+type NodesDHT interface {
+	FindOverlayNodes(ctx context.Context, overlayKey []byte) ([]Node, error)
+	StoreOverlayNodes(ctx context.Context, overlayKey []byte, nodes []Node) error
+}
+
+type managedPeer struct {
+	id      string
+	overlay *ADNLOverlayWrapper
+	fails   int
+}
+
+// PeerManager - maintains neighbours of the overlay: discovers nodes in DHT and through peers exchange,
+// keeps target number of connected neighbours, evicts dead ones and answers random peers queries
+type PeerManager struct {
+	// TargetNeighbours - number of connected neighbours to keep
+	TargetNeighbours int
+	// MaxKnownNodes - limit of not connected nodes to remember
+	MaxKnownNodes int
+	// NodeTTL - nodes signed earlier are considered stale
+	NodeTTL time.Duration
+	// RefreshInterval - how often neighbours are checked and new ones are searched
+	RefreshInterval time.Duration
+	// StoreInterval - how often our node is announced in DHT, 0 to not announce
+	StoreInterval time.Duration
+	// MaxFails - number of failed checks in a row, after which neighbour is evicted
+	MaxFails int
+
+	overlayKey []byte
+	overlayId  []byte
+	key        ed25519.PrivateKey
+	dht        NodesDHT
+	connector  PeerConnector
+
+	ourNode     *Node
+	lastStoreAt time.Time
+
+	neighbours map[string]*managedPeer
+	known      map[string]Node
+	mx         sync.RWMutex
+}
+
+// NewPeerManager - creates manager of the overlay with the given key (full id), our node is signed using key.
+// Connector is used to connect to the discovered nodes, dht is optional.
+func NewPeerManager(overlayKey []byte, key ed25519.PrivateKey, dht NodesDHT, connector PeerConnector) (*PeerManager, error) {
+	overlayId, err := tl.Hash(adnl.PublicKeyOverlay{Key: overlayKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to calc overlay id: %w", err)
+	}
+
+	return &PeerManager{
+		TargetNeighbours: 8,
+		MaxKnownNodes:    256,
+		NodeTTL:          30 * time.Minute,
+		RefreshInterval:  10 * time.Second,
+		StoreInterval:    5 * time.Minute,
+		MaxFails:         3,
+		overlayKey:       overlayKey,
+		overlayId:        overlayId,
+		key:              key,
+		dht:              dht,
+		connector:        connector,
+		neighbours:       map[string]*managedPeer{},
+		known:            map[string]Node{},
+	}, nil
+}
+
+// OverlayID - returns short id of the overlay
+func (m *PeerManager) OverlayID() []byte {
+	return m.overlayId
+}
+
+// Run - maintains neighbours until context is done, all neighbours are closed after
+func (m *PeerManager) Run(ctx context.Context) {
+	for {
+		m.Refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			m.Close()
+			return
+		case <-time.After(m.RefreshInterval):
+		}
+	}
+}
+
+// Refresh - checks neighbours and exchanges peers with them, evicts dead ones,
+// connects new neighbours if we have less than target, and announces our node in DHT
+func (m *PeerManager) Refresh(ctx context.Context) {
+	m.checkNeighbours(ctx)
+	m.pruneKnown()
+
+	need := m.TargetNeighbours - len(m.Neighbours())
+	if need > 0 {
+		if m.dht != nil && len(m.candidates()) < need {
+			if nodes, err := m.dht.FindOverlayNodes(ctx, m.overlayKey); err == nil {
+				m.AddNodes(nodes)
+			}
+		}
+
+		for _, node := range m.candidates() {
+			if need <= 0 {
+				break
+			}
+
+			ctxConn, cancel := context.WithTimeout(ctx, 7*time.Second)
+			err := m.connect(ctxConn, node)
+			cancel()
+			if err != nil {
+				m.removeKnown(node)
+				continue
+			}
+			need--
+		}
+	}
+
+	if m.dht != nil && m.StoreInterval > 0 && time.Since(m.lastStoreAt) > m.StoreInterval {
+		node, err := m.OurNode()
+		if err == nil {
+			if err = m.dht.StoreOverlayNodes(ctx, m.overlayKey, []Node{*node}); err == nil {
+				m.lastStoreAt = time.Now()
+			}
+		}
+	}
+}
+
+// Neighbours - returns currently connected neighbours
+func (m *PeerManager) Neighbours() []*ADNLOverlayWrapper {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	list := make([]*ADNLOverlayWrapper, 0, len(m.neighbours))
+	for _, p := range m.neighbours {
+		list = append(list, p.overlay)
+	}
+	return list
+}
+
+// AddNeighbour - adds already connected peer, for example incoming one, and starts answering its random peers queries
+func (m *PeerManager) AddNeighbour(w *ADNLOverlayWrapper) {
+	p := &managedPeer{
+		id:      string(w.GetID()),
+		overlay: w,
+	}
+
+	prevQuery := w.queryHandler
+	w.SetQueryHandler(func(msg *adnl.MessageQuery) error {
+		if q, ok := msg.Data.(GetRandomPeers); ok {
+			return m.answerRandomPeers(w, msg.ID, q)
+		}
+		if prevQuery != nil {
+			return prevQuery(msg)
+		}
+		return nil
+	})
+
+	prevDisconnect := w.disconnectHandler
+	w.SetDisconnectHandler(func(addr string, key ed25519.PublicKey) {
+		m.removeNeighbour(p)
+		if prevDisconnect != nil {
+			prevDisconnect(addr, key)
+		}
+	})
+
+	m.mx.Lock()
+	m.neighbours[p.id] = p
+	m.mx.Unlock()
+}
+
+// AddNodes - validates nodes and remembers them as candidates for neighbours
+func (m *PeerManager) AddNodes(nodes []Node) {
+	for _, node := range nodes {
+		if err := m.checkNode(&node); err != nil {
+			continue
+		}
+
+		id, err := tl.Hash(node.ID)
+		if err != nil {
+			continue
+		}
+
+		m.mx.Lock()
+		old, ok := m.known[string(id)]
+		if ok && old.Version < node.Version {
+			m.known[string(id)] = node
+		} else if !ok && len(m.known) < m.MaxKnownNodes {
+			m.known[string(id)] = node
+		}
+		m.mx.Unlock()
+	}
+}
+
+// RandomPeers - returns our node and up to num random known nodes, except the given one
+func (m *PeerManager) RandomPeers(num int, except []byte) ([]Node, error) {
+	ourNode, err := m.OurNode()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mx.RLock()
+	list := make([]Node, 0, len(m.known))
+	for id, node := range m.known {
+		if id != string(except) {
+			list = append(list, node)
+		}
+	}
+	m.mx.RUnlock()
+
+	rand.Shuffle(len(list), func(i, j int) {
+		list[i], list[j] = list[j], list[i]
+	})
+	if len(list) > num {
+		list = list[:num]
+	}
+	return append([]Node{*ourNode}, list...), nil
+}
+
+// OurNode - returns our signed overlay node, it is re-signed when half of ttl passed
+func (m *PeerManager) OurNode() (*Node, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if m.ourNode == nil || time.Since(time.Unix(int64(m.ourNode.Version), 0)) > m.NodeTTL/2 {
+		node, err := NewNode(m.overlayKey, m.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign our node: %w", err)
+		}
+		m.ourNode = node
+	}
+	return m.ourNode, nil
+}
+
+// Close - disconnects all neighbours
+func (m *PeerManager) Close() {
+	m.mx.Lock()
+	peers := m.neighbours
+	m.neighbours = map[string]*managedPeer{}
+	m.mx.Unlock()
+
+	for _, p := range peers {
+		p.overlay.Close()
+		p.overlay.ADNLWrapper.Close()
+	}
+}
+
+func (m *PeerManager) checkNode(node *Node) error {
+	if !bytes.Equal(node.Overlay, m.overlayId) {
+		return fmt.Errorf("node of another overlay")
+	}
+
+	signedAt := time.Unix(int64(node.Version), 0)
+	if time.Since(signedAt) > m.NodeTTL {
+		return fmt.Errorf("stale node")
+	}
+	if time.Until(signedAt) > time.Minute {
+		return fmt.Errorf("node from the future")
+	}
+
+	if pub, ok := node.ID.(adnl.PublicKeyED25519); ok && bytes.Equal(pub.Key, m.key.Public().(ed25519.PublicKey)) {
+		return fmt.Errorf("our node")
+	}
+	return node.CheckSignature()
+}
+
+// checkNeighbours - exchanges peers with all neighbours, it also checks that they are alive
+func (m *PeerManager) checkNeighbours(ctx context.Context) {
+	m.mx.RLock()
+	peers := make([]*managedPeer, 0, len(m.neighbours))
+	for _, p := range m.neighbours {
+		peers = append(peers, p)
+	}
+	m.mx.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p *managedPeer) {
+			defer wg.Done()
+
+			ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+
+			if err := m.exchangePeers(ctxQuery, p.overlay); err != nil {
+				m.mx.Lock()
+				p.fails++
+				dead := p.fails >= m.MaxFails
+				m.mx.Unlock()
+
+				if dead {
+					m.removeNeighbour(p)
+					p.overlay.Close()
+					p.overlay.ADNLWrapper.Close()
+				}
+				return
+			}
+
+			m.mx.Lock()
+			p.fails = 0
+			m.mx.Unlock()
+		}(p)
+	}
+	wg.Wait()
+}
+
+func (m *PeerManager) exchangePeers(ctx context.Context, w *ADNLOverlayWrapper) error {
+	ours, err := m.RandomPeers(4, w.GetID())
+	if err != nil {
+		return err
+	}
+
+	var res NodesList
+	if err = w.Query(ctx, GetRandomPeers{List: NodesList{List: ours}}, &res); err != nil {
+		return fmt.Errorf("failed to get random peers: %w", err)
+	}
+	m.AddNodes(res.List)
+	return nil
+}
+
+func (m *PeerManager) answerRandomPeers(w *ADNLOverlayWrapper, queryId []byte, q GetRandomPeers) error {
+	m.AddNodes(q.List.List)
+
+	list, err := m.RandomPeers(8, w.GetID())
+	if err != nil {
+		return err
+	}
+
+	if err = w.Answer(context.Background(), queryId, NodesList{List: list}); err != nil {
+		return fmt.Errorf("failed to answer random peers query: %w", err)
+	}
+	return nil
+}
+
+func (m *PeerManager) connect(ctx context.Context, node Node) error {
+	w, err := m.connector(ctx, node)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	// announce ourselves, so peer will know about us
+	if err = m.exchangePeers(ctx, w); err != nil {
+		w.Close()
+		w.ADNLWrapper.Close()
+		return err
+	}
+
+	m.AddNeighbour(w)
+	return nil
+}
+
+func (m *PeerManager) candidates() []Node {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	var list []Node
+	for id, node := range m.known {
+		if m.neighbours[id] == nil {
+			list = append(list, node)
+		}
+	}
+	return list
+}
+
+func (m *PeerManager) pruneKnown() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	for id, node := range m.known {
+		if time.Since(time.Unix(int64(node.Version), 0)) > m.NodeTTL {
+			delete(m.known, id)
+		}
+	}
+}
+
+func (m *PeerManager) removeKnown(node Node) {
+	id, err := tl.Hash(node.ID)
+	if err != nil {
+		return
+	}
+
+	m.mx.Lock()
+	delete(m.known, string(id))
+	m.mx.Unlock()
+}
+
+func (m *PeerManager) removeNeighbour(p *managedPeer) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if m.neighbours[p.id] == p {
+		delete(m.neighbours, p.id)
+	}
+}
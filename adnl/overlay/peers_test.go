@@ -0,0 +1,137 @@
+package overlay
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+type mockNodesDHT struct {
+	nodes  []Node
+	stored []Node
+}
+
+func (m *mockNodesDHT) FindOverlayNodes(ctx context.Context, overlayKey []byte) ([]Node, error) {
+	return m.nodes, nil
+}
+
+func (m *mockNodesDHT) StoreOverlayNodes(ctx context.Context, overlayKey []byte, nodes []Node) error {
+	m.stored = append(m.stored, nodes...)
+	return nil
+}
+
+func TestPeerManager(t *testing.T) {
+	overlayKey := make([]byte, 32)
+	_, _ = rand.Read(overlayKey)
+
+	overlayId, err := tl.Hash(adnl.PublicKeyOverlay{Key: overlayKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+
+	var nodes []Node
+	for i := 0; i < 5; i++ {
+		_, k, _ := ed25519.GenerateKey(nil)
+		n, err := NewNode(overlayKey, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, *n)
+	}
+
+	// stale node
+	_, staleKey, _ := ed25519.GenerateKey(nil)
+	stale, _ := NewNode(overlayKey, staleKey)
+	stale.Version = int32(time.Now().Add(-time.Hour).Unix())
+	if err = stale.Sign(staleKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// node of other overlay
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	other, _ := NewNode(make([]byte, 32), otherKey)
+
+	// bad signature
+	bad := nodes[0]
+	bad.Version++
+
+	dht := &mockNodesDHT{nodes: append([]Node{*stale, *other, bad}, nodes[1:]...)}
+
+	peers := map[string]*mockADNL{}
+	m, err := NewPeerManager(overlayKey, key, dht, func(ctx context.Context, node Node) (*ADNLOverlayWrapper, error) {
+		id, err := tl.Hash(node.ID)
+		if err != nil {
+			return nil, err
+		}
+		p := &mockADNL{id: id}
+		peers[string(id)] = p
+		return CreateExtendedADNL(p).WithOverlay(overlayId), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.TargetNeighbours = 3
+
+	m.Refresh(context.Background())
+
+	if len(m.Neighbours()) != 3 {
+		t.Fatal("incorrect neighbours num", len(m.Neighbours()))
+	}
+	if len(m.candidates()) != 1 {
+		t.Fatal("only valid nodes should be known", len(m.candidates()))
+	}
+	if len(dht.stored) != 1 || dht.stored[0].CheckSignature() != nil {
+		t.Fatal("our node should be stored in dht")
+	}
+
+	n := m.Neighbours()[0]
+	p := peers[string(n.GetID())]
+
+	// answer random peers query and learn about requester's peers
+	_, newKey, _ := ed25519.GenerateKey(nil)
+	newNode, _ := NewNode(overlayKey, newKey)
+
+	err = p.queryHandler(&adnl.MessageQuery{ID: make([]byte, 32), Data: WrapQuery(m.OverlayID(), GetRandomPeers{List: NodesList{List: []Node{*newNode}}})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.answers) != 1 {
+		t.Fatal("query should be answered")
+	}
+	list := p.answers[0].(NodesList).List
+	if len(list) == 0 || list[0].ID.(adnl.PublicKeyED25519).Key.Equal(key.Public()) == false {
+		t.Fatal("our node should be in answer")
+	}
+	for _, node := range list {
+		if id, _ := tl.Hash(node.ID); string(id) == string(n.GetID()) {
+			t.Fatal("requester should not be in answer")
+		}
+	}
+	if len(m.candidates()) != 2 {
+		t.Fatal("requester's node should be learned")
+	}
+
+	// dead peer eviction
+	p.queryErr = errors.New("timeout")
+	dht.nodes = nil
+	for i := 0; i < m.MaxFails; i++ {
+		m.Refresh(context.Background())
+	}
+
+	for _, w := range m.Neighbours() {
+		if w == n {
+			t.Fatal("dead neighbour should be evicted")
+		}
+	}
+	if len(m.Neighbours()) != 3 {
+		t.Fatal("dead neighbour should be replaced", len(m.Neighbours()))
+	}
+}
@@ -2,6 +2,7 @@ package overlay
 
 import (
 	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"github.com/chaindead/tonutils-go/adnl"
@@ -26,6 +27,7 @@ func init() {
 	tl.Register(BroadcastFEC{}, "overlay.broadcastFec src:PublicKey certificate:overlay.Certificate data_hash:int256 data_size:int flags:int data:bytes seqno:int fec:fec.Type date:int signature:bytes = overlay.Broadcast")
 	tl.Register(BroadcastFECShort{}, "overlay.broadcastFecShort src:PublicKey certificate:overlay.Certificate broadcast_hash:int256 part_data_hash:int256 seqno:int signature:bytes = overlay.Broadcast")
 	tl.Register(BroadcastFECID{}, "overlay.broadcastFec.id src:int256 type:int256 data_hash:int256 size:int flags:int = overlay.broadcastFec.Id")
+	tl.Register(BroadcastID{}, "overlay.broadcast.id src:int256 data_hash:int256 flags:int = overlay.broadcast.Id")
 	tl.Register(BroadcastFECPartID{}, "overlay.broadcastFec.partId broadcast_hash:int256 data_hash:int256 seqno:int = overlay.broadcastFec.PartId")
 	tl.Register(BroadcastToSign{}, "overlay.broadcast.toSign hash:int256 date:int = overlay.broadcast.ToSign")
 	tl.Register(FECReceived{}, "overlay.fec.received hash:int256 = overlay.Broadcast")
@@ -142,6 +144,93 @@ type Broadcast struct {
 	Signature   []byte `tl:"bytes"`
 }
 
+type BroadcastID struct {
+	Source   []byte `tl:"int256"`
+	DataHash []byte `tl:"int256"`
+	Flags    int32  `tl:"int"`
+}
+
+// NewBroadcast - creates simple broadcast of the serialized message, signed by the given key
+func NewBroadcast(key ed25519.PrivateKey, data []byte, certificate any) (*Broadcast, error) {
+	if certificate == nil {
+		certificate = CertificateEmpty{}
+	}
+
+	b := &Broadcast{
+		Source:      adnl.PublicKeyED25519{Key: key.Public().(ed25519.PublicKey)},
+		Certificate: certificate,
+		Flags:       0,
+		Data:        data,
+		Date:        int32(time.Now().Unix()),
+	}
+
+	toSign, err := b.toSign()
+	if err != nil {
+		return nil, err
+	}
+	b.Signature = ed25519.Sign(key, toSign)
+
+	return b, nil
+}
+
+// CalcID - computes broadcast hash, it is used to sign broadcast and to detect duplicates
+func (t *Broadcast) CalcID() ([]byte, error) {
+	var src = make([]byte, 32)
+	if t.Flags&_BroadcastFlagAnySender == 0 {
+		var err error
+		src, err = tl.Hash(t.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute source key id: %w", err)
+		}
+	}
+
+	dataHash := sha256.Sum256(t.Data)
+
+	broadcastHash, err := tl.Hash(&BroadcastID{
+		Source:   src,
+		DataHash: dataHash[:],
+		Flags:    t.Flags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute hash id of the broadcast: %w", err)
+	}
+	return broadcastHash, nil
+}
+
+// CheckSignature - verifies broadcast signature with source key
+func (t *Broadcast) CheckSignature() error {
+	sourceKey, ok := t.Source.(adnl.PublicKeyED25519)
+	if !ok {
+		return fmt.Errorf("invalid signer key format")
+	}
+
+	toSign, err := t.toSign()
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(sourceKey.Key, toSign, t.Signature) {
+		return fmt.Errorf("invalid broadcast signature")
+	}
+	return nil
+}
+
+func (t *Broadcast) toSign() ([]byte, error) {
+	broadcastHash, err := t.CalcID()
+	if err != nil {
+		return nil, err
+	}
+
+	toSign, err := tl.Serialize(&BroadcastToSign{
+		Hash: broadcastHash,
+		Date: t.Date,
+	}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize broadcast for sign: %w", err)
+	}
+	return toSign, nil
+}
+
 type BroadcastFEC struct {
 	Source      any    `tl:"struct boxed [pub.ed25519]"`
 	Certificate any    `tl:"struct boxed [overlay.emptyCertificate,overlay.certificate,overlay.certificateV2]"`
@@ -0,0 +1,388 @@
+package adnl
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl/address"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+func init() {
+	tl.Register(RelayRegister{}, "adnl.relay.register = adnl.relay.Registered")
+	tl.Register(RelayRegistered{}, "adnl.relay.registered ttl:int = adnl.relay.Registered")
+	tl.Register(RelayPacket{}, "adnl.relay.packet addr:string data:bytes = adnl.relay.Packet")
+}
+
+// RelayRegister - query to relay, asks it to forward packets addressed to our adnl id
+type RelayRegister struct{}
+
+// RelayRegistered - answer of relay, registration should be renewed before ttl in seconds is passed
+type RelayRegistered struct {
+	TTL int32 `tl:"int"`
+}
+
+// RelayPacket - raw adnl packet forwarded between relay and the node behind it,
+// addr is the address of the remote side which sent the packet, or which should receive it
+type RelayPacket struct {
+	Addr string `tl:"string"`
+	Data []byte `tl:"bytes"`
+}
+
+// RelayRegistrationTTL - how long relay keeps registration of the node
+var RelayRegistrationTTL = 60 * time.Second
+
+// RelaySessionTTL - how long relay remembers remote addresses which talked to the node behind it,
+// it is required to forward channel packets, which are not addressed to node id
+var RelaySessionTTL = 10 * time.Minute
+
+const _MaxRelaySessions = 1 << 16
+
+// _RelayQueueSize - packets waiting to be forwarded to the registered node, new ones are dropped when it is full
+const _RelayQueueSize = 128
+
+type relayClient struct {
+	peer      *peerConn
+	expiresAt time.Time
+
+	queue chan RelayPacket
+	done  chan struct{}
+}
+
+func newRelayClient(ctx context.Context, peer *peerConn) *relayClient {
+	cl := &relayClient{
+		peer:  peer,
+		queue: make(chan RelayPacket, _RelayQueueSize),
+		done:  make(chan struct{}),
+	}
+	go cl.worker(ctx)
+	return cl
+}
+
+// worker - forwards queued packets to the node, so slow node is not blocking gateway listener
+func (c *relayClient) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			return
+		case p := <-c.queue:
+			// wrapped packet is usually bigger than mtu, so it is split to parts by adnl
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			err := c.peer.SendCustomMessage(ctx, p)
+			cancel()
+			if err != nil {
+				Logger("failed to forward packet to relayed node", c.peer.RemoteAddr(), ":", err)
+			}
+		}
+	}
+}
+
+// enqueue - returns false if packet was dropped because queue is full
+func (c *relayClient) enqueue(p RelayPacket) bool {
+	select {
+	case c.queue <- p:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *relayClient) stop() {
+	close(c.done)
+}
+
+type relaySession struct {
+	clients map[string]*relayClient
+	lastAt  time.Time
+}
+
+type relayServer struct {
+	gate       *Gateway
+	maxClients int
+
+	clients     map[string]*relayClient
+	sessions    map[string]*relaySession
+	lastCleanup time.Time
+
+	mx sync.Mutex
+}
+
+// relayedAddr - address of remote peer, which is reachable only through our relay
+type relayedAddr struct {
+	relay Peer
+	addr  string
+}
+
+func (r *relayedAddr) Network() string {
+	return "udp"
+}
+
+func (r *relayedAddr) String() string {
+	return r.addr
+}
+
+// EnableRelay - allows nodes behind NAT to register at us and receive packets through our address,
+// should be called before server is started. Up to maxClients nodes can be registered at the same time.
+func (g *Gateway) EnableRelay(maxClients int) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	g.relay = &relayServer{
+		gate:       g,
+		maxClients: maxClients,
+		clients:    map[string]*relayClient{},
+		sessions:   map[string]*relaySession{},
+	}
+}
+
+// ConnectRelay - registers us at the relay with the given address and key, and announces relay address
+// in our address list, so nodes which have found us in dht will reach us through it.
+// Gateway should be started in client mode before. Registration is renewed in background until gateway is closed.
+func (g *Gateway) ConnectRelay(ctx context.Context, addr string, key ed25519.PublicKey) error {
+	pAddr, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid relay address: %w", err)
+	}
+
+	ttl, err := g.registerAtRelay(ctx, addr, key)
+	if err != nil {
+		return err
+	}
+
+	tm := int32(time.Now().Unix())
+	g.mx.Lock()
+	g.addrList = address.List{
+		Addresses: []*address.UDP{{
+			IP:   pAddr.Addr().Unmap().AsSlice(),
+			Port: int32(pAddr.Port()),
+		}},
+		Version:    tm,
+		ReinitDate: tm,
+		Priority:   0,
+		ExpireAt:   0,
+	}
+	g.mx.Unlock()
+
+	go func() {
+		for {
+			wait := ttl / 3
+			select {
+			case <-g.globalCtx.Done():
+				return
+			case <-time.After(wait):
+			}
+
+			regCtx, cancel := context.WithTimeout(g.globalCtx, 10*time.Second)
+			newTTL, err := g.registerAtRelay(regCtx, addr, key)
+			cancel()
+			if err != nil {
+				Logger("failed to renew relay registration at", addr, ":", err)
+				// retry sooner
+				ttl = 15 * time.Second
+				continue
+			}
+			ttl = newTTL
+		}
+	}()
+
+	return nil
+}
+
+func (g *Gateway) registerAtRelay(ctx context.Context, addr string, key ed25519.PublicKey) (time.Duration, error) {
+	peer, err := g.RegisterClient(addr, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to relay: %w", err)
+	}
+
+	a, ok := peer.(*peerConn).client.(*ADNL)
+	if !ok {
+		return 0, fmt.Errorf("unsupported relay connection")
+	}
+
+	rootId := g.GetID()
+	a.internalHandler = func(msg any) (bool, error) {
+		m, ok := msg.(MessageCustom)
+		if !ok {
+			return false, nil
+		}
+
+		p, ok := m.Data.(RelayPacket)
+		if !ok {
+			return false, nil
+		}
+
+		g.handlePacket(rootId, &relayedAddr{relay: peer, addr: p.Addr}, p.Data)
+		return true, nil
+	}
+
+	var res RelayRegistered
+	if err = peer.Query(ctx, RelayRegister{}, &res); err != nil {
+		return 0, fmt.Errorf("failed to register at relay: %w", err)
+	}
+
+	if res.TTL <= 0 {
+		return 0, fmt.Errorf("incorrect relay registration ttl %d", res.TTL)
+	}
+	return time.Duration(res.TTL) * time.Second, nil
+}
+
+// handler - processes relay service messages of the peer
+func (r *relayServer) handler(peer *peerConn) func(msg any) (bool, error) {
+	return func(msg any) (bool, error) {
+		switch m := msg.(type) {
+		case MessageQuery:
+			if _, ok := m.Data.(RelayRegister); !ok {
+				return false, nil
+			}
+
+			if err := r.register(peer); err != nil {
+				return true, err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := peer.Answer(ctx, m.ID, RelayRegistered{TTL: int32(RelayRegistrationTTL / time.Second)}); err != nil {
+				return true, fmt.Errorf("failed to answer relay registration: %w", err)
+			}
+			return true, nil
+		case MessageCustom:
+			p, ok := m.Data.(RelayPacket)
+			if !ok {
+				return false, nil
+			}
+			return true, r.send(peer, p)
+		}
+		return false, nil
+	}
+}
+
+func (r *relayServer) register(peer *peerConn) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	cl := r.clients[peer.clientId]
+	if cl == nil || cl.peer != peer {
+		if len(r.clients) >= r.maxClients {
+			r.cleanup(now)
+			if len(r.clients) >= r.maxClients {
+				return fmt.Errorf("relay is full")
+			}
+		}
+
+		if cl != nil {
+			// node reconnected, old connection will not be used anymore
+			cl.stop()
+		}
+
+		cl = newRelayClient(r.gate.globalCtx, peer)
+		r.clients[peer.clientId] = cl
+	}
+	cl.expiresAt = now.Add(RelayRegistrationTTL)
+	return nil
+}
+
+// send - writes packet of the registered node to the remote address,
+// only addresses which have sent packets to this node are allowed, to not be used for traffic reflection
+func (r *relayServer) send(peer *peerConn, p RelayPacket) error {
+	now := time.Now()
+
+	r.mx.Lock()
+	cl := r.clients[peer.clientId]
+	sess := r.sessions[p.Addr]
+	allowed := cl != nil && cl.peer == peer && now.Before(cl.expiresAt) &&
+		sess != nil && sess.clients[peer.clientId] == cl
+	r.mx.Unlock()
+
+	if !allowed {
+		return fmt.Errorf("relay packet to not allowed address %s", p.Addr)
+	}
+
+	pAddr, err := netip.ParseAddrPort(p.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid relay packet address: %w", err)
+	}
+
+	if err = r.gate.write(time.Now().Add(5*time.Second), net.UDPAddrFromAddrPort(pAddr), p.Data); err != nil {
+		return fmt.Errorf("failed to write relayed packet: %w", err)
+	}
+	return nil
+}
+
+// forward - sends packet to registered nodes it is addressed to, returns false if there are no such nodes
+func (r *relayServer) forward(id []byte, addr net.Addr, packet []byte) bool {
+	now := time.Now()
+	from := addr.String()
+
+	r.mx.Lock()
+	if now.Sub(r.lastCleanup) > 10*time.Second {
+		r.cleanup(now)
+	}
+
+	var targets []*relayClient
+	if cl := r.clients[string(id)]; cl != nil && now.Before(cl.expiresAt) {
+		sess := r.sessions[from]
+		if sess == nil && len(r.sessions) < _MaxRelaySessions {
+			sess = &relaySession{clients: map[string]*relayClient{}}
+			r.sessions[from] = sess
+		}
+
+		if sess != nil {
+			sess.clients[string(id)] = cl
+			sess.lastAt = now
+		}
+		targets = append(targets, cl)
+	} else if sess := r.sessions[from]; sess != nil {
+		// channel packet, we cannot know which node it is for, so we send it to all nodes
+		// which talked with this address, others will just drop it
+		sess.lastAt = now
+		for _, c := range sess.clients {
+			if now.Before(c.expiresAt) {
+				targets = append(targets, c)
+			}
+		}
+	}
+	r.mx.Unlock()
+
+	if len(targets) == 0 {
+		return false
+	}
+
+	p := RelayPacket{Addr: from, Data: packet}
+	for _, cl := range targets {
+		if !cl.enqueue(p) {
+			Logger("relay queue is full, packet to", cl.peer.RemoteAddr(), "dropped")
+		}
+	}
+	return true
+}
+
+func (r *relayServer) cleanup(now time.Time) {
+	r.lastCleanup = now
+	for k, cl := range r.clients {
+		if now.After(cl.expiresAt) {
+			delete(r.clients, k)
+			cl.stop()
+		}
+	}
+
+	for k, sess := range r.sessions {
+		for id := range sess.clients {
+			if r.clients[id] != sess.clients[id] {
+				delete(sess.clients, id)
+			}
+		}
+
+		if len(sess.clients) == 0 || now.Sub(sess.lastAt) > RelaySessionTTL {
+			delete(r.sessions, k)
+		}
+	}
+}
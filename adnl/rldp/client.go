@@ -1,6 +1,7 @@
 package rldp
 
 import (
+	"bytes"
 	"context"
 	"crypto/ed25519"
 	"crypto/rand"
@@ -8,7 +9,7 @@ import (
 	"github.com/chaindead/tonutils-go/adnl"
 	"github.com/chaindead/tonutils-go/adnl/rldp/raptorq"
 	"github.com/chaindead/tonutils-go/tl"
-	"log"
+	"io"
 	"reflect"
 	"sync"
 	"time"
@@ -34,6 +35,9 @@ type RLDP struct {
 	activeTransfers map[string]chan bool
 
 	recvStreams map[string]*decoderStream
+	sendStreams map[string]*encoderStream
+
+	congestionControl func() CongestionControl
 
 	onQuery      func(transferId []byte, query *Query) error
 	onDisconnect func()
@@ -52,10 +56,47 @@ type decoderStream struct {
 	receivedNum          int32
 	receivedNumConfirmed int32
 
+	// part - number of currently receiving part, each part has its own decoder
+	part      int32
+	totalSize int64
+	// offset - size of already received parts
+	offset int64
+	// data - received parts of the message, when transfer is not received with ReceiveTransfer
+	data []byte
+	// completedParts - number of parts which completion was sent to the sender
+	completedParts int32
+
+	// parts - decoded parts of the stream transfer, they are written by ReceiveTransfer outside the handler
+	parts   chan streamPart
+	maxSize int64
+	result  chan error
+	aborted bool
+
 	mx sync.Mutex
 }
 
+// streamPart - decoded part of the stream transfer, complete is sent to the sender after the part is written
+type streamPart struct {
+	num      int32
+	data     []byte
+	complete tl.Serializable
+}
+
 const _MTU = 1 << 37
+
+// _MaxBufferedSize - max size of the message kept in memory, when transfer is not received with ReceiveTransfer
+const _MaxBufferedSize = 64 << 20
+
+// _MaxQueuedParts - decoded parts waiting to be written by ReceiveTransfer, part is completed only after it is written,
+// so sender which waits for completions never exceeds it, transfer is aborted otherwise
+const _MaxQueuedParts = 4
+
+// _PartSize - max size of the outgoing transfer part, each part has its own fec encoder,
+// so memory usage does not depend on transfer size
+const _PartSize = 2 << 20
+
+// _MaxPartSize - max size of the incoming transfer part
+const _MaxPartSize = 16 << 20
 const _SymbolSize = 768
 const _PacketWaitTime = 5 * time.Millisecond
 
@@ -65,6 +106,7 @@ func NewClient(a ADNL) *RLDP {
 		activeRequests:  map[string]chan any{},
 		activeTransfers: map[string]chan bool{},
 		recvStreams:     map[string]*decoderStream{},
+		sendStreams:     map[string]*encoderStream{},
 	}
 
 	a.SetCustomMessageHandler(r.handleMessage)
@@ -82,6 +124,12 @@ func (r *RLDP) GetADNL() ADNL {
 	return r.adnl
 }
 
+// SetCongestionControl - sets constructor of congestion control for outgoing transfers,
+// DefaultCongestionControl is used when it is nil
+func (r *RLDP) SetCongestionControl(factory func() CongestionControl) {
+	r.congestionControl = factory
+}
+
 func (r *RLDP) SetOnQuery(handler func(transferId []byte, query *Query) error) {
 	r.onQuery = handler
 }
@@ -100,13 +148,14 @@ func (r *RLDP) Close() {
 
 func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 	isV2 := true
+	receivedCount := int32(-1)
 	switch m := msg.Data.(type) {
 	case MessagePartV2:
 		msg.Data = MessagePart(m)
 	case CompleteV2:
 		msg.Data = Complete(m)
 	case ConfirmV2:
-		// TODO: use other params too
+		receivedCount = m.ReceivedCount
 		msg.Data = Confirm{
 			TransferID: m.TransferID,
 			Part:       m.Part,
@@ -133,12 +182,13 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 			if m.TotalSize > _MTU || m.TotalSize <= 0 {
 				return fmt.Errorf("bad rldp packet total size")
 			}
-			dec, err := raptorq.NewRaptorQ(uint32(fec.SymbolSize)).CreateDecoder(uint32(fec.DataSize))
-			if err != nil {
-				return fmt.Errorf("failed to init raptorq decoder: %w", err)
+
+			if m.Part != 0 {
+				// beginning of the transfer was already received and dropped, or transfer was canceled
+				return nil
 			}
+
 			stream = &decoderStream{
-				decoder:       dec,
 				lastMessageAt: time.Now(),
 			}
 
@@ -155,7 +205,12 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 		stream.mx.Lock()
 		defer stream.mx.Unlock()
 
-		if stream.finishedAt != nil {
+		if stream.finishedAt != nil || m.Part < stream.part {
+			if m.Part >= stream.completedParts {
+				// part is decoded, but still not written by ReceiveTransfer
+				return nil
+			}
+
 			if stream.lastCompleteAt.Add(5 * time.Millisecond).Before(time.Now()) { // we not send completions too often, to not get socket buffer overflow
 
 				var complete tl.Serializable = Complete{
@@ -167,19 +222,45 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 					complete = CompleteV2(complete.(Complete))
 				}
 
-				// got packet for a finished stream, let them know that it is completed, again
+				// got packet for a finished stream or part, let them know that it is completed, again
 				err := r.adnl.SendCustomMessage(context.Background(), complete)
 				if err != nil {
 					return fmt.Errorf("failed to send rldp complete message: %w", err)
 				}
 
-				r.mx.Lock()
-				r.recvStreams[id].lastCompleteAt = time.Now()
-				r.mx.Unlock()
+				stream.lastCompleteAt = time.Now()
 			}
 			return nil
 		}
 
+		if m.Part > stream.part || stream.aborted {
+			// we are still waiting for the previous part
+			return nil
+		}
+
+		if stream.decoder == nil {
+			// first symbol of the part
+			if stream.totalSize == 0 {
+				if stream.maxSize > 0 && m.TotalSize > stream.maxSize {
+					stream.abort(fmt.Errorf("too big transfer, %d bytes", m.TotalSize))
+					return nil
+				}
+				stream.totalSize = m.TotalSize
+			} else if stream.totalSize != m.TotalSize {
+				return fmt.Errorf("rldp transfer total size has changed")
+			}
+
+			if fec.DataSize <= 0 || fec.DataSize > _MaxPartSize || stream.offset+int64(fec.DataSize) > stream.totalSize {
+				return fmt.Errorf("bad rldp part data size")
+			}
+
+			dec, err := raptorq.NewRaptorQ(uint32(fec.SymbolSize)).CreateDecoder(uint32(fec.DataSize))
+			if err != nil {
+				return fmt.Errorf("failed to init raptorq decoder: %w", err)
+			}
+			stream.decoder = dec
+		}
+
 		canTryDecode, err := stream.decoder.AddSymbol(uint32(m.Seqno), m.Data)
 		if err != nil {
 			return fmt.Errorf("failed to add raptorq symbol %d: %w", m.Seqno, err)
@@ -198,8 +279,52 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 
 			// it may not be decoded due to unsolvable math system, it means we need more symbols
 			if decoded {
-				stream.finishedAt = &tm
 				stream.decoder = nil
+				stream.offset += int64(len(data))
+
+				var complete tl.Serializable = Complete{
+					TransferID: m.TransferID,
+					Part:       m.Part,
+				}
+
+				if isV2 {
+					complete = CompleteV2(complete.(Complete))
+				}
+
+				if stream.parts != nil {
+					// completion is sent by ReceiveTransfer when part is written, so sender waits for slow writer
+					select {
+					case stream.parts <- streamPart{num: m.Part, data: data, complete: complete}:
+					default:
+						stream.abort(fmt.Errorf("transfer data is not written in time"))
+						return nil
+					}
+				} else {
+					if int64(len(stream.data)+len(data)) > _MaxBufferedSize {
+						stream.abort(fmt.Errorf("too big transfer to keep in memory, %d bytes", stream.totalSize))
+						return nil
+					}
+					stream.data = append(stream.data, data...)
+				}
+
+				if stream.offset < stream.totalSize {
+					// wait for the next part
+					stream.part++
+					stream.maxSeqno = -1
+					stream.receivedNum = 0
+					stream.receivedNumConfirmed = 0
+
+					if stream.parts == nil {
+						stream.completedParts = stream.part
+						err = r.adnl.SendCustomMessage(context.Background(), complete)
+						if err != nil {
+							return fmt.Errorf("failed to send rldp part complete message: %w", err)
+						}
+					}
+					return nil
+				}
+
+				stream.finishedAt = &tm
 
 				r.mx.Lock()
 				if len(r.recvStreams) > 100 {
@@ -213,21 +338,22 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 				}
 				r.mx.Unlock()
 
-				var res any
-				_, err = tl.Parse(&res, data, true)
-				if err != nil {
-					return fmt.Errorf("failed to parse custom message: %w", err)
+				if stream.parts != nil {
+					close(stream.parts)
+					return nil
 				}
 
-				var complete tl.Serializable = Complete{
-					TransferID: m.TransferID,
-					Part:       m.Part,
-				}
+				// transfer is not received with ReceiveTransfer, so it is a message and its data is not kept
+				msgData := stream.data
+				stream.data = nil
 
-				if isV2 {
-					complete = CompleteV2(complete.(Complete))
+				var res any
+				_, err = tl.Parse(&res, msgData, true)
+				if err != nil {
+					return fmt.Errorf("failed to parse custom message: %w", err)
 				}
 
+				stream.completedParts = stream.part + 1
 				err = r.adnl.SendCustomMessage(context.Background(), complete)
 				if err != nil {
 					return fmt.Errorf("failed to send rldp complete message: %w", err)
@@ -260,7 +386,7 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 						req <- rVal.Data
 					}
 				default:
-					log.Println("skipping unwanted rldp message of type", reflect.TypeOf(res).String())
+					Logger("skipping unwanted rldp message of type", reflect.TypeOf(res).String())
 				}
 				completed = true
 			}
@@ -296,10 +422,16 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 				}
 			}
 		}
-	case Complete: // receiver has fully received transfer, close our stream
+	case Complete: // receiver has fully received transfer part, close our stream
 		id := string(m.TransferID)
 
 		r.mx.Lock()
+		if ss := r.sendStreams[id]; ss != nil && ss.part != m.Part {
+			// repeated completion of the previous part
+			r.mx.Unlock()
+			return nil
+		}
+
 		t := r.activeTransfers[id]
 		if t != nil {
 			delete(r.activeTransfers, id)
@@ -310,11 +442,18 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 			close(t)
 		}
 	case Confirm: // receiver has received some parts
-		// TODO: use confirmed seqno to limit sends
-		// id := string(m.TransferID)
-		// r.mx.RLock()
-		// t := r.activeTransfers[id]
-		// r.mx.RUnlock()
+		id := string(m.TransferID)
+		r.mx.RLock()
+		stream := r.sendStreams[id]
+		r.mx.RUnlock()
+
+		if stream != nil && stream.part == m.Part {
+			if receivedCount < 0 {
+				// v1 confirm has no received count, so we cannot estimate loss
+				receivedCount = m.Seqno + 1
+			}
+			stream.onConfirm(m.Seqno, receivedCount, time.Now())
+		}
 	default:
 		return fmt.Errorf("unexpected message type %s", reflect.TypeOf(m).String())
 	}
@@ -322,22 +461,186 @@ func (r *RLDP) handleMessage(msg *adnl.MessageCustom) error {
 	return nil
 }
 
+// abort - stops receiving of the stream transfer, it is kept in the list until cleanup, to ignore the rest symbols
+func (s *decoderStream) abort(err error) {
+	s.aborted = true
+	s.decoder = nil
+	s.data = nil
+
+	if s.result != nil {
+		s.result <- err
+	}
+}
+
 func (r *RLDP) sendMessageParts(ctx context.Context, transferId, data []byte) error {
+	if len(data) <= _PartSize {
+		return r.sendTransferParts(ctx, transferId, int64(len(data)), func(part int32) ([]byte, error) {
+			return data, nil
+		})
+	}
+	return r.SendTransfer(ctx, transferId, int64(len(data)), bytes.NewReader(data))
+}
+
+// SendTransfer - sends size bytes from the reader to the peer, as rldp transfer with the given id.
+// Data is read and sent part by part, so transfer of any size can be sent without keeping it in memory.
+// Returns when receiver has confirmed the whole transfer.
+func (r *RLDP) SendTransfer(ctx context.Context, transferId []byte, size int64, data io.Reader) error {
+	if size <= 0 {
+		return fmt.Errorf("transfer size should be positive")
+	}
+
+	return r.sendTransferParts(ctx, transferId, size, func(part int32) ([]byte, error) {
+		sz := size - int64(part)*_PartSize
+		if sz > _PartSize {
+			sz = _PartSize
+		}
+
+		buf := make([]byte, sz)
+		if _, err := io.ReadFull(data, buf); err != nil {
+			return nil, fmt.Errorf("failed to read part %d: %w", part, err)
+		}
+		return buf, nil
+	})
+}
+
+// ReceiveTransfer - receives transfer with the given id from the peer and writes its data to w part by part,
+// so transfer of any size can be received without keeping it in memory.
+// Should be called before sender has started the transfer, transfer which was completed before the call is dropped.
+// Each part is confirmed to the sender only after it is written, so slow writer slows down the sender.
+// Blocks until transfer is completed, returns its size.
+func (r *RLDP) ReceiveTransfer(ctx context.Context, transferId []byte, maxSize int64, w io.Writer) (int64, error) {
+	id := string(transferId)
+
+	r.mx.Lock()
+	stream := r.recvStreams[id]
+	if stream == nil {
+		stream = &decoderStream{
+			lastMessageAt: time.Now(),
+		}
+		r.recvStreams[id] = stream
+	}
+	r.mx.Unlock()
+
+	stream.mx.Lock()
+	if stream.aborted {
+		stream.mx.Unlock()
+		return 0, fmt.Errorf("transfer was aborted")
+	}
+
+	if stream.parts != nil {
+		stream.mx.Unlock()
+		return 0, fmt.Errorf("transfer is already being received")
+	}
+
+	if stream.finishedAt != nil {
+		stream.mx.Unlock()
+		return 0, fmt.Errorf("transfer was completed before receive")
+	}
+
+	if stream.totalSize > 0 && maxSize > 0 && stream.totalSize > maxSize {
+		stream.abort(nil)
+		stream.mx.Unlock()
+		return 0, fmt.Errorf("too big transfer, %d bytes", stream.totalSize)
+	}
+
+	// parts which were received before the call
+	data := stream.data
+	stream.data = nil
+
+	stream.parts = make(chan streamPart, _MaxQueuedParts)
+	stream.maxSize = maxSize
+	stream.result = make(chan error, 1)
+	parts, result := stream.parts, stream.result
+	stream.mx.Unlock()
+
+	fail := func(err error) (int64, error) {
+		stream.mx.Lock()
+		if stream.finishedAt == nil && !stream.aborted {
+			stream.abort(nil)
+		}
+		stream.mx.Unlock()
+		return 0, err
+	}
+
+	written := int64(len(data))
+	if len(data) > 0 {
+		if _, err := w.Write(data); err != nil {
+			return fail(fmt.Errorf("failed to write transfer data: %w", err))
+		}
+	}
+
+	for {
+		select {
+		case part, ok := <-parts:
+			if !ok {
+				// all parts are received and written
+				return written, nil
+			}
+
+			if _, err := w.Write(part.data); err != nil {
+				return fail(fmt.Errorf("failed to write transfer data: %w", err))
+			}
+			written += int64(len(part.data))
+
+			stream.mx.Lock()
+			stream.completedParts = part.num + 1
+			stream.mx.Unlock()
+
+			// part is written, so sender can continue with the next one
+			if err := r.adnl.SendCustomMessage(ctx, part.complete); err != nil {
+				return fail(fmt.Errorf("failed to send rldp part complete message: %w", err))
+			}
+		case err := <-result:
+			return 0, err
+		case <-ctx.Done():
+			return fail(ctx.Err())
+		}
+	}
+}
+
+// sendTransferParts - sends transfer of the given size, data of each part is taken from getPart
+func (r *RLDP) sendTransferParts(ctx context.Context, transferId []byte, size int64, getPart func(part int32) ([]byte, error)) error {
+	newCC := r.congestionControl
+	if newCC == nil {
+		newCC = DefaultCongestionControl
+	}
+	// window is kept between parts, to not start from the beginning
+	cc := newCC()
+
+	var sent int64
+	for part := int32(0); part == 0 || sent < size; part++ {
+		data, err := getPart(part)
+		if err != nil {
+			return err
+		}
+
+		if err = r.sendPart(ctx, transferId, cc, part, size, data); err != nil {
+			return err
+		}
+		sent += int64(len(data))
+	}
+	return nil
+}
+
+func (r *RLDP) sendPart(ctx context.Context, transferId []byte, cc CongestionControl, part int32, totalSize int64, data []byte) error {
 	enc, err := raptorq.NewRaptorQ(_SymbolSize).CreateEncoder(data)
 	if err != nil {
 		return fmt.Errorf("failed to create raptorq object encoder: %w", err)
 	}
 
 	id := string(transferId)
+	stream := newEncoderStream(cc, part)
 
 	ch := make(chan bool, 1)
 	r.mx.Lock()
 	r.activeTransfers[id] = ch
+	r.sendStreams[id] = stream
 	r.mx.Unlock()
 
 	defer func() {
 		r.mx.Lock()
 		delete(r.activeTransfers, id)
+		delete(r.sendStreams, id)
 		r.mx.Unlock()
 	}()
 
@@ -353,24 +656,24 @@ func (r *RLDP) sendMessageParts(ctx context.Context, transferId, data []byte) er
 		default:
 		}
 
-		fastSymbols := enc.BaseSymbolsNum() + enc.BaseSymbolsNum()/5 // send 120% packets fast
-
-		if symbolsSent > fastSymbols {
-			x := (symbolsSent - fastSymbols) / 2
-			if x > 70 { // 7 ms max delay
-				x = 70
-			}
-
+		// we send additional FEC recovery parts until complete, as fast as congestion control allows
+		if wait := stream.waitTime(time.Now()); wait > 0 {
+			timer := time.NewTimer(wait)
 			select {
 			case <-ctx.Done():
+				timer.Stop()
 				// too slow receiver, finish sending
 				return ctx.Err()
 			case <-ch:
+				timer.Stop()
 				// we got complete from receiver, finish sending
 				return nil
-			case <-time.After(time.Duration(x) * (time.Millisecond / 10)):
-				// send additional FEC recovery parts until complete
+			case <-stream.wake:
+				// got confirmation, window may be moved
+				timer.Stop()
+			case <-timer.C:
 			}
+			continue
 		}
 
 		p := MessagePart{
@@ -380,8 +683,8 @@ func (r *RLDP) sendMessageParts(ctx context.Context, transferId, data []byte) er
 				SymbolSize:   _SymbolSize,
 				SymbolsCount: int32(enc.BaseSymbolsNum()),
 			},
-			Part:      int32(0),
-			TotalSize: int64(len(data)),
+			Part:      part,
+			TotalSize: totalSize,
 			Seqno:     int32(symbolsSent),
 			Data:      enc.GenSymbol(symbolsSent),
 		}
@@ -391,6 +694,9 @@ func (r *RLDP) sendMessageParts(ctx context.Context, transferId, data []byte) er
 			msgPart = MessagePartV2(p)
 		}
 
+		// marked before sending, because confirmation may come faster than send returns
+		stream.onSent(time.Now())
+
 		err = r.adnl.SendCustomMessage(ctx, msgPart)
 		if err != nil {
 			return fmt.Errorf("failed to send message part %d: %w", symbolsSent, err)
@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -637,3 +638,157 @@ func TestRLDP_SendAnswer(t *testing.T) {
 		}
 	})
 }
+
+func TestRLDP_Transfer(t *testing.T) {
+	var sender, receiver *RLDP
+	sender = NewClientV2(MockADNL{
+		sendCustomMessage: func(ctx context.Context, req tl.Serializable) error {
+			return receiver.handleMessage(&adnl.MessageCustom{Data: req})
+		},
+	})
+	receiver = NewClientV2(MockADNL{
+		sendCustomMessage: func(ctx context.Context, req tl.Serializable) error {
+			return sender.handleMessage(&adnl.MessageCustom{Data: req})
+		},
+	})
+
+	data := make([]byte, 2*_PartSize+1000)
+	_, _ = rand.Read(data)
+
+	transferId := make([]byte, 32)
+	_, _ = rand.Read(transferId)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	received := make(chan error, 1)
+	go func() {
+		n, err := receiver.ReceiveTransfer(ctx, transferId, int64(len(data)), &buf)
+		if err == nil && n != int64(len(data)) {
+			err = errors.New("incorrect transfer size")
+		}
+		received <- err
+	}()
+
+	// wait for receiver registration
+	for {
+		receiver.mx.RLock()
+		s := receiver.recvStreams[string(transferId)]
+		receiver.mx.RUnlock()
+		if s != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := sender.SendTransfer(ctx, transferId, int64(len(data)), bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-received; err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("incorrect data received")
+	}
+
+	t.Run("completed before receive", func(t *testing.T) {
+		_, _ = rand.Read(transferId)
+
+		small := data[:3*_SymbolSize+10]
+		sCtx, sCancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer sCancel()
+		if err := sender.SendTransfer(sCtx, transferId, int64(len(small)), bytes.NewReader(small)); err == nil {
+			t.Fatal("raw transfer should not be completed without receiver")
+		}
+
+		receiver.mx.RLock()
+		s := receiver.recvStreams[string(transferId)]
+		receiver.mx.RUnlock()
+		if s == nil || s.finishedAt == nil || s.data != nil {
+			t.Fatal("transfer data should be dropped")
+		}
+
+		if _, err := receiver.ReceiveTransfer(ctx, transferId, int64(len(small)), &bytes.Buffer{}); err == nil {
+			t.Fatal("dropped transfer should not be received")
+		}
+	})
+
+	t.Run("slow writer", func(t *testing.T) {
+		_, _ = rand.Read(transferId)
+
+		w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+		received := make(chan error, 1)
+		go func() {
+			_, err := receiver.ReceiveTransfer(ctx, transferId, int64(len(data)), w)
+			received <- err
+		}()
+
+		for {
+			receiver.mx.RLock()
+			s := receiver.recvStreams[string(transferId)]
+			receiver.mx.RUnlock()
+			if s != nil {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		sent := make(chan error, 1)
+		go func() {
+			sent <- sender.SendTransfer(ctx, transferId, int64(len(data)), bytes.NewReader(data))
+		}()
+
+		// first part is decoded and being written, sender should not get its completion
+		<-w.started
+		time.Sleep(200 * time.Millisecond)
+
+		sender.mx.RLock()
+		ss := sender.sendStreams[string(transferId)]
+		sender.mx.RUnlock()
+		if ss == nil || ss.part != 0 {
+			t.Fatal("sender should wait until the first part is written")
+		}
+
+		close(w.release)
+		if err := <-sent; err != nil {
+			t.Fatal(err)
+		}
+		if err := <-received; err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.buf.Bytes(), data) {
+			t.Fatal("incorrect data received")
+		}
+	})
+
+	t.Run("too big", func(t *testing.T) {
+		_, _ = rand.Read(transferId)
+
+		go func() {
+			sCtx, sCancel := context.WithTimeout(ctx, 2*time.Second)
+			defer sCancel()
+			_ = sender.SendTransfer(sCtx, transferId, 2000, bytes.NewReader(make([]byte, 2000)))
+		}()
+
+		_, err := receiver.ReceiveTransfer(ctx, transferId, 1000, &bytes.Buffer{})
+		if err == nil {
+			t.Fatal("too big transfer should be rejected")
+		}
+	})
+}
+
+type blockingWriter struct {
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	return w.buf.Write(p)
+}
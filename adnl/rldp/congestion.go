@@ -0,0 +1,273 @@
+package rldp
+
+import (
+	"sync"
+	"time"
+)
+
+// CongestionControl - decides how fast transfer parts are sent, based on feedback from receiver.
+// Separate instance is created for each transfer, methods are called under transfer lock.
+type CongestionControl interface {
+	// OnConfirm - called when receiver confirms newly received symbols,
+	// acked is number of newly confirmed symbols, loss is estimated loss rate from 0 to 1, rtt is smoothed round trip time
+	OnConfirm(acked int, loss float64, rtt time.Duration)
+	// OnTimeout - called when window is full and there was no feedback from receiver for too long
+	OnTimeout()
+	// Window - max number of sent but not confirmed symbols
+	Window() int
+	// PacingInterval - min delay between sending symbols
+	PacingInterval() time.Duration
+}
+
+// DefaultCongestionControl - used for transfers when RLDP has no congestion control set
+var DefaultCongestionControl = func() CongestionControl {
+	return NewAIMD()
+}
+
+// AIMD - additive increase, multiplicative decrease congestion control with slow start.
+// Window grows by one symbol per round trip, or twice per round trip during slow start,
+// and it is decreased when loss rate is above the threshold. Symbols are paced evenly across the round trip.
+type AIMD struct {
+	InitialWindow int
+	MinWindow     int
+	MaxWindow     int
+	// LossThreshold - loss rate which is considered as congestion, lower loss is covered by FEC
+	LossThreshold float64
+	// DecreaseFactor - window is multiplied by it on congestion
+	DecreaseFactor float64
+
+	window         float64
+	slowStartLimit float64
+	rtt            time.Duration
+	lastDecreaseAt time.Time
+}
+
+func NewAIMD() *AIMD {
+	return &AIMD{
+		InitialWindow:  64,
+		MinWindow:      8,
+		MaxWindow:      8192,
+		LossThreshold:  0.1,
+		DecreaseFactor: 0.5,
+	}
+}
+
+func (a *AIMD) OnConfirm(acked int, loss float64, rtt time.Duration) {
+	a.init()
+	a.rtt = rtt
+
+	if loss > a.LossThreshold {
+		// decrease only once per round trip, confirms of the same congestion come during it
+		if time.Since(a.lastDecreaseAt) > rtt {
+			a.lastDecreaseAt = time.Now()
+			a.decrease()
+		}
+		return
+	}
+
+	if a.window < a.slowStartLimit {
+		a.window += float64(acked)
+	} else {
+		a.window += float64(acked) / a.window
+	}
+	a.clamp()
+}
+
+func (a *AIMD) OnTimeout() {
+	a.init()
+	a.decrease()
+	a.window = float64(a.MinWindow)
+}
+
+func (a *AIMD) Window() int {
+	a.init()
+	return int(a.window)
+}
+
+func (a *AIMD) PacingInterval() time.Duration {
+	a.init()
+	if a.rtt == 0 {
+		return 0
+	}
+	return time.Duration(float64(a.rtt) / a.window)
+}
+
+func (a *AIMD) init() {
+	if a.window == 0 {
+		a.window = float64(a.InitialWindow)
+		a.slowStartLimit = float64(a.MaxWindow)
+		a.clamp()
+	}
+}
+
+func (a *AIMD) decrease() {
+	a.window *= a.DecreaseFactor
+	a.clamp()
+	a.slowStartLimit = a.window
+}
+
+func (a *AIMD) clamp() {
+	if a.window < float64(a.MinWindow) {
+		a.window = float64(a.MinWindow)
+	}
+	if a.window > float64(a.MaxWindow) {
+		a.window = float64(a.MaxWindow)
+	}
+}
+
+const _MinRTO = 50 * time.Millisecond
+const _MaxRTO = 3 * time.Second
+const _InitialRTO = 300 * time.Millisecond
+
+// _LossWindow - number of sent symbols to estimate loss rate on
+const _LossWindow = 16
+
+// encoderStream - state of the outgoing transfer, estimates rtt and loss from receiver confirmations
+type encoderStream struct {
+	cc   CongestionControl
+	part int32
+
+	startedAt      time.Time
+	sentAt         []time.Duration
+	lastSentAt     time.Time
+	lastFeedbackAt time.Time
+
+	// frontier - symbols before it are confirmed or considered lost
+	frontier int32
+	maxSeqno int32
+	received int32
+
+	lossSent int32
+	lossRecv int32
+	loss     float64
+
+	srtt   time.Duration
+	rttVar time.Duration
+
+	wake chan bool
+	mx   sync.Mutex
+}
+
+func newEncoderStream(cc CongestionControl, part int32) *encoderStream {
+	now := time.Now()
+	return &encoderStream{
+		cc:             cc,
+		part:           part,
+		startedAt:      now,
+		lastFeedbackAt: now,
+		maxSeqno:       -1,
+		wake:           make(chan bool, 1),
+	}
+}
+
+// waitTime - returns how long to wait before the next symbol can be sent, 0 if it can be sent now
+func (s *encoderStream) waitTime(now time.Time) time.Duration {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if int(int32(len(s.sentAt))-s.frontier) >= s.cc.Window() {
+		rto := s.rto()
+		if since := now.Sub(s.lastFeedbackAt); since < rto {
+			return rto - since
+		}
+
+		// no feedback for too long, consider all sent symbols as lost
+		s.cc.OnTimeout()
+		s.frontier = int32(len(s.sentAt))
+		s.lastFeedbackAt = now
+	}
+
+	if next := s.lastSentAt.Add(s.cc.PacingInterval()); next.After(now) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+func (s *encoderStream) onSent(now time.Time) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.sentAt = append(s.sentAt, now.Sub(s.startedAt))
+	s.lastSentAt = now
+}
+
+// onConfirm - processes receiver feedback, received is the total number of symbols received by the other side
+func (s *encoderStream) onConfirm(maxSeqno, received int32, now time.Time) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if maxSeqno <= s.maxSeqno || int(maxSeqno) >= len(s.sentAt) {
+		// old or invalid confirmation
+		return
+	}
+
+	if received > maxSeqno+1 {
+		received = maxSeqno + 1
+	}
+
+	s.updateRTT(now.Sub(s.startedAt.Add(s.sentAt[maxSeqno])))
+
+	acked := 0
+	if maxSeqno+1 > s.frontier {
+		acked = int(maxSeqno + 1 - s.frontier)
+		s.frontier = maxSeqno + 1
+	}
+
+	s.lossSent += maxSeqno - s.maxSeqno
+	if received > s.received {
+		s.lossRecv += received - s.received
+		s.received = received
+	}
+	s.maxSeqno = maxSeqno
+
+	if s.lossSent >= _LossWindow {
+		s.loss = 1 - float64(s.lossRecv)/float64(s.lossSent)
+		if s.loss < 0 {
+			s.loss = 0
+		}
+		s.lossSent, s.lossRecv = 0, 0
+	}
+
+	s.lastFeedbackAt = now
+	s.cc.OnConfirm(acked, s.loss, s.srtt)
+
+	select {
+	case s.wake <- true:
+	default:
+	}
+}
+
+// updateRTT - calculates smoothed rtt and its variation, like in RFC 6298
+func (s *encoderStream) updateRTT(sample time.Duration) {
+	if sample <= 0 {
+		return
+	}
+
+	if s.srtt == 0 {
+		s.srtt = sample
+		s.rttVar = sample / 2
+		return
+	}
+
+	diff := s.srtt - sample
+	if diff < 0 {
+		diff = -diff
+	}
+	s.rttVar = (3*s.rttVar + diff) / 4
+	s.srtt = (7*s.srtt + sample) / 8
+}
+
+func (s *encoderStream) rto() time.Duration {
+	if s.srtt == 0 {
+		return _InitialRTO
+	}
+
+	rto := s.srtt + 4*s.rttVar
+	if rto < _MinRTO {
+		rto = _MinRTO
+	}
+	if rto > _MaxRTO {
+		rto = _MaxRTO
+	}
+	return rto
+}
@@ -0,0 +1,166 @@
+package rldp
+
+import (
+	"context"
+	"crypto/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/tl"
+)
+
+func TestAIMD(t *testing.T) {
+	a := NewAIMD()
+	if a.Window() != 64 || a.PacingInterval() != 0 {
+		t.Fatal("incorrect initial state")
+	}
+
+	// slow start
+	a.OnConfirm(64, 0, 100*time.Millisecond)
+	if a.Window() != 128 {
+		t.Fatal("window should be doubled in slow start, got", a.Window())
+	}
+	if a.PacingInterval() != 100*time.Millisecond/128 {
+		t.Fatal("incorrect pacing", a.PacingInterval())
+	}
+
+	a.OnConfirm(1, 0.5, 100*time.Millisecond)
+	if a.Window() != 64 {
+		t.Fatal("window should be decreased on loss, got", a.Window())
+	}
+
+	// second loss report in the same round trip
+	a.OnConfirm(1, 0.5, 100*time.Millisecond)
+	if a.Window() != 64 {
+		t.Fatal("window should be decreased once per rtt, got", a.Window())
+	}
+
+	// congestion avoidance
+	a.OnConfirm(64, 0, 100*time.Millisecond)
+	if a.Window() != 65 {
+		t.Fatal("window should grow by one per rtt, got", a.Window())
+	}
+
+	a.OnTimeout()
+	if a.Window() != a.MinWindow {
+		t.Fatal("window should be minimal after timeout, got", a.Window())
+	}
+}
+
+func TestEncoderStream(t *testing.T) {
+	s := newEncoderStream(NewAIMD(), 0)
+	start := s.startedAt
+
+	for i := 0; i < 64; i++ {
+		if w := s.waitTime(start); w != 0 {
+			t.Fatal("should send without waiting, symbol", i, w)
+		}
+		s.onSent(start)
+	}
+
+	if w := s.waitTime(start); w != _InitialRTO {
+		t.Fatal("window should be full", w)
+	}
+
+	// 16 sent, 12 received
+	s.onConfirm(15, 12, start.Add(40*time.Millisecond))
+	if s.srtt != 40*time.Millisecond || s.frontier != 16 {
+		t.Fatal("incorrect rtt or frontier", s.srtt, s.frontier)
+	}
+	if s.loss != 0.25 {
+		t.Fatal("incorrect loss", s.loss)
+	}
+
+	// old confirmation is ignored
+	s.onConfirm(10, 10, start.Add(50*time.Millisecond))
+	if s.maxSeqno != 15 {
+		t.Fatal("old confirm should be ignored")
+	}
+
+	// confirmation of not sent symbol is ignored
+	s.onConfirm(1000, 1000, start.Add(50*time.Millisecond))
+	if s.maxSeqno != 15 {
+		t.Fatal("invalid confirm should be ignored")
+	}
+
+	for int(int32(len(s.sentAt))-s.frontier) < s.cc.Window() {
+		s.onSent(start)
+	}
+
+	// no feedback, all in flight symbols are considered lost
+	if w := s.waitTime(start.Add(time.Hour)); w != 0 {
+		t.Fatal("should send after timeout", w)
+	}
+	if s.frontier != int32(len(s.sentAt)) || s.cc.Window() != 8 {
+		t.Fatal("timeout is not processed")
+	}
+}
+
+func TestRLDP_sendMessagePartsCongestion(t *testing.T) {
+	data := make([]byte, 300*_SymbolSize)
+	_, _ = rand.Read(data)
+
+	transferId := make([]byte, 32)
+	_, _ = rand.Read(transferId)
+
+	var cli *RLDP
+	var stream *encoderStream
+	var sent, confirmed int32
+	cli = NewClientV2(MockADNL{
+		sendCustomMessage: func(ctx context.Context, req tl.Serializable) error {
+			p, ok := req.(MessagePartV2)
+			if !ok {
+				return nil
+			}
+			num := atomic.AddInt32(&sent, 1)
+			if num == 1 {
+				cli.mx.RLock()
+				stream = cli.sendStreams[string(p.TransferID)]
+				cli.mx.RUnlock()
+			}
+
+			// every 4th symbol is lost
+			if p.Seqno%4 != 3 {
+				recv := atomic.AddInt32(&confirmed, 1)
+				go func() {
+					time.Sleep(5 * time.Millisecond)
+					_ = cli.handleMessage(&adnl.MessageCustom{Data: ConfirmV2{
+						TransferID:    p.TransferID,
+						Part:          p.Part,
+						MaxSeqno:      p.Seqno,
+						ReceivedCount: recv,
+					}})
+				}()
+			}
+
+			if num == 500 {
+				go func() {
+					_ = cli.handleMessage(&adnl.MessageCustom{Data: CompleteV2{TransferID: p.TransferID}})
+				}()
+			}
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := cli.sendMessageParts(ctx, transferId, data); err != nil {
+		t.Fatal(err)
+	}
+
+	stream.mx.Lock()
+	defer stream.mx.Unlock()
+
+	if stream.srtt < 5*time.Millisecond || stream.srtt > time.Second {
+		t.Fatal("incorrect rtt estimation", stream.srtt)
+	}
+	if stream.loss < 0.15 || stream.loss > 0.35 {
+		t.Fatal("incorrect loss estimation", stream.loss)
+	}
+	if w := stream.cc.Window(); w >= 8192 || w < 8 {
+		t.Fatal("window should be limited by loss", w)
+	}
+}
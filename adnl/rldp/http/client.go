@@ -480,7 +480,7 @@ func (t *Transport) resolveRLDP(ctx context.Context, info *rldpInfo, host string
 
 	var triedAddresses []string
 	for _, v := range addresses.Addresses {
-		addr := fmt.Sprintf("%s:%d", v.IP.String(), v.Port)
+		addr := v.String()
 
 		var client RLDP
 		// find working rldp node addr
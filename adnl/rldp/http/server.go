@@ -31,6 +31,12 @@ type ADNLGateway interface {
 	StartServer(listenAddr string) error
 }
 
+// RelayGateway - gateway which can be reached through relay, when there is no public ip
+type RelayGateway interface {
+	StartClient() error
+	ConnectRelay(ctx context.Context, addr string, key ed25519.PublicKey) error
+}
+
 type Server struct {
 	dht DHT
 
@@ -41,6 +47,8 @@ type Server struct {
 	activeRequests map[string]*payloadStream
 	adnlServer     ADNLGateway
 	externalIp     net.IP
+	relayAddr      string
+	relayKey       ed25519.PublicKey
 
 	closer chan bool
 	closed bool
@@ -99,6 +107,14 @@ func (s *Server) SetExternalIP(ip net.IP) {
 	s.adnlServer.SetExternalIP(ip)
 }
 
+// SetRelay - server will be reachable through the relay node with the given address and key,
+// instead of listening on public address. Useful for servers behind NAT.
+func (s *Server) SetRelay(addr string, key ed25519.PublicKey) {
+	s.relayAddr = addr
+	s.relayKey = key
+}
+
+// ListenAndServe - starts server, listenAddr is ignored when relay is set
 func (s *Server) ListenAndServe(listenAddr string) error {
 	go func() {
 		for {
@@ -175,8 +191,7 @@ func (s *Server) ListenAndServe(listenAddr string) error {
 		return nil
 	})
 
-	err := s.adnlServer.StartServer(listenAddr)
-	if err != nil {
+	if err := s.start(listenAddr); err != nil {
 		_ = s.Stop()
 		return err
 	}
@@ -185,6 +200,29 @@ func (s *Server) ListenAndServe(listenAddr string) error {
 	return nil
 }
 
+func (s *Server) start(listenAddr string) error {
+	if s.relayAddr == "" {
+		return s.adnlServer.StartServer(listenAddr)
+	}
+
+	gate, ok := s.adnlServer.(RelayGateway)
+	if !ok {
+		return fmt.Errorf("adnl gateway does not support relays")
+	}
+
+	if err := gate.StartClient(); err != nil {
+		return fmt.Errorf("failed to start adnl client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := gate.ConnectRelay(ctx, s.relayAddr, s.relayKey); err != nil {
+		return fmt.Errorf("failed to connect to relay: %w", err)
+	}
+	return nil
+}
+
 func (s *Server) Address() []byte {
 	return s.id
 }
@@ -1,5 +1,10 @@
 package liteclient
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type GlobalConfig struct {
 	Type        string             `json:"@type"`
 	DHT         DHTConfig          `json:"dht"`
@@ -42,10 +47,62 @@ type DHTAddressList struct {
 	ExpireAt   int          `json:"expire_at"`
 }
 
+// DHTAddress - for adnl.address.udp ip is an int, for adnl.address.udp6 it is base64 of 16 bytes and stored in IP6
 type DHTAddress struct {
 	Type string `json:"@type"`
 	IP   int    `json:"ip"`
 	Port int    `json:"port"`
+	IP6  []byte `json:"-"`
+}
+
+type dhtAddressUDP6 struct {
+	Type string `json:"@type"`
+	IP   []byte `json:"ip"`
+	Port int    `json:"port"`
+}
+
+func (a *DHTAddress) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type string          `json:"@type"`
+		IP   json.RawMessage `json:"ip"`
+		Port int             `json:"port"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*a = DHTAddress{
+		Type: raw.Type,
+		Port: raw.Port,
+	}
+
+	if len(raw.IP) == 0 {
+		return nil
+	}
+
+	if raw.Type == "adnl.address.udp6" {
+		if err := json.Unmarshal(raw.IP, &a.IP6); err != nil {
+			return fmt.Errorf("failed to parse ipv6: %w", err)
+		}
+		if len(a.IP6) != 16 {
+			return fmt.Errorf("incorrect ipv6 len %d", len(a.IP6))
+		}
+		return nil
+	}
+	return json.Unmarshal(raw.IP, &a.IP)
+}
+
+func (a DHTAddress) MarshalJSON() ([]byte, error) {
+	if a.Type == "adnl.address.udp6" {
+		return json.Marshal(dhtAddressUDP6{
+			Type: a.Type,
+			IP:   a.IP6,
+			Port: a.Port,
+		})
+	}
+
+	type plain DHTAddress
+	return json.Marshal(plain(a))
 }
 
 type ServerID struct {
@@ -26,6 +26,8 @@ var _SchemaIDByTypeName = map[string]uint32{}
 var _SchemaIDByName = map[string]uint32{}
 var _SchemaByID = map[uint32]reflect.Type{}
 
+var tlType = reflect.TypeOf((*TL)(nil)).Elem()
+
 var BoolTrue = CRC("boolTrue = Bool")
 var BoolFalse = CRC("boolFalse = Bool")
 
@@ -66,7 +68,15 @@ func Serialize(v Serializable, boxed bool) ([]byte, error) {
 	}
 
 	// if we have custom method, we use it
-	if t, ok := v.(TL); ok {
+	t, ok := v.(TL)
+	if !ok && rv.Kind() == reflect.Struct && reflect.PointerTo(rv.Type()).Implements(tlType) {
+		// methods are defined on pointer, but value was passed
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		t, ok = ptr.Interface().(TL)
+	}
+
+	if ok {
 		data, err := t.Serialize()
 		if err != nil {
 			return nil, fmt.Errorf("failed to serialize %s using manual method: %w", rv.Type().String(), err)
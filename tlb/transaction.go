@@ -235,6 +235,21 @@ type Transaction struct {
 	Hash []byte `tlb:"-"`
 }
 
+// IsSuccess - true when ordinary transaction is not aborted, its compute phase was executed successfully,
+// and action phase, if it is present, is also succeeded, so outgoing messages were sent
+func (t *Transaction) IsSuccess() bool {
+	desc, ok := t.Description.Description.(TransactionDescriptionOrdinary)
+	if !ok || desc.Aborted {
+		return false
+	}
+
+	// skipped compute phase means that message was not processed by contract
+	if vm, ok := desc.ComputePhase.Phase.(ComputePhaseVM); !ok || !vm.Success {
+		return false
+	}
+	return desc.ActionPhase == nil || desc.ActionPhase.Success
+}
+
 func (t *Transaction) Dump() string {
 	var in string
 	if t.IO.In != nil {
@@ -22,6 +22,10 @@ func TestTransaction_String(t *testing.T) {
 	if tx.String() != "LT: 35290576000004, In: 2.1 TON, From EQANrdEh_lJ10saEhhft5-qjQrOAwSArR244rXwrGwI8_V1l, Out: 2.084509999 TON, To [EQD3cvYfoK3Vd2WhOm1cpnYt6ouh9_fjDtC4-NQiutpgen0U]" {
 		t.Errorf("another string expected")
 	}
+
+	if !tx.IsSuccess() {
+		t.Errorf("transaction should be successful")
+	}
 }
 
 func TestTransaction_Dump(t *testing.T) {
@@ -0,0 +1,448 @@
+package dns
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/ton/nft"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var ErrInvalidDomainName = errors.New("invalid domain name")
+
+// TONZoneCollection - mainnet collection of .ton domains
+var TONZoneCollection = address.MustParseAddr("EQC3dNlesgVD8YbAazcauIrXBPfiVhMMr5YYk2in0Mtsz0Bz")
+
+const (
+	// DomainRenewPeriod - domain expires when it was not renewed by owner during this period
+	DomainRenewPeriod = 365 * 24 * time.Hour
+	// AuctionMinStepPercent - each next bid should be bigger than the previous at least by this percent
+	AuctionMinStepPercent = 5
+
+	_OpChangeDNSRecord = 0x4eb1f0f9
+)
+
+var timeNow = time.Now
+
+type DomainStatus int
+
+const (
+	// DomainFree - domain was never bought, auction can be started through collection
+	DomainFree DomainStatus = iota
+	// DomainAuction - auction is in progress, bids are sent to the domain item
+	DomainAuction
+	// DomainOwned - domain has an owner and is not expired
+	DomainOwned
+	// DomainExpired - domain was not renewed in time, new auction can be started by bid to the domain item
+	DomainExpired
+)
+
+func (s DomainStatus) String() string {
+	switch s {
+	case DomainFree:
+		return "free"
+	case DomainAuction:
+		return "auction"
+	case DomainOwned:
+		return "owned"
+	case DomainExpired:
+		return "expired"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+type AuctionInfo struct {
+	MaxBidAddress *address.Address
+	MaxBid        tlb.Coins
+	EndTime       uint32
+}
+
+// NextMinBid - minimal amount of the next bid
+func (a *AuctionInfo) NextMinBid() tlb.Coins {
+	amt := new(big.Int).Mul(a.MaxBid.Nano(), big.NewInt(100+AuctionMinStepPercent))
+	return tlb.FromNanoTON(amt.Div(amt, big.NewInt(100)))
+}
+
+type DomainState struct {
+	Name    string
+	Address *address.Address
+	Status  DomainStatus
+	// Owner - current owner, for finished but not claimed auction it is the winner
+	Owner          *address.Address
+	Auction        *AuctionInfo
+	LastFillUpTime time.Time
+	ExpiresAt      time.Time
+}
+
+// Bid - message which should be sent to bid on domain or to start its auction
+type Bid struct {
+	To        *address.Address
+	MinAmount tlb.Coins
+	Body      *cell.Cell
+}
+
+// Manager - manages domains of the zone collection, like .ton
+type Manager struct {
+	api            TonApi
+	collectionAddr *address.Address
+	collection     *nft.CollectionClient
+}
+
+func NewManager(api TonApi, zoneCollection *address.Address) *Manager {
+	return &Manager{
+		api:            api,
+		collectionAddr: zoneCollection,
+		collection:     nft.NewCollectionClient(api, zoneCollection),
+	}
+}
+
+// GetDomainAddress - calculates address of the domain item, name should be without zone, for example "alice" for alice.ton
+func (m *Manager) GetDomainAddress(ctx context.Context, name string) (*address.Address, error) {
+	if err := ValidateDomainName(name); err != nil {
+		return nil, err
+	}
+
+	h := sha256.Sum256([]byte(name))
+	return m.collection.GetNFTAddressByIndex(ctx, new(big.Int).SetBytes(h[:]))
+}
+
+func (m *Manager) GetDomainState(ctx context.Context, name string) (*DomainState, error) {
+	b, err := m.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+	return m.GetDomainStateAtBlock(ctx, name, b)
+}
+
+func (m *Manager) GetDomainStateAtBlock(ctx context.Context, name string, b *ton.BlockIDExt) (*DomainState, error) {
+	if err := ValidateDomainName(name); err != nil {
+		return nil, err
+	}
+
+	h := sha256.Sum256([]byte(name))
+	addr, err := m.collection.GetNFTAddressByIndexAtBlock(ctx, new(big.Int).SetBytes(h[:]), b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain address: %w", err)
+	}
+
+	state := &DomainState{
+		Name:    name,
+		Address: addr,
+	}
+
+	dom := &Domain{
+		ItemEditableClient: nft.NewItemEditableClient(m.api, addr),
+		api:                m.api,
+	}
+
+	data, err := dom.GetNFTDataAtBlock(ctx, b)
+	if err != nil {
+		if errors.Is(err, ton.ContractExecError{Code: ton.ErrCodeContractNotInitialized}) {
+			state.Status = DomainFree
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to get domain data: %w", err)
+	}
+	state.Owner = data.OwnerAddress
+
+	if state.Auction, err = dom.GetAuctionInfoAtBlock(ctx, b); err != nil {
+		return nil, err
+	}
+
+	if state.LastFillUpTime, err = dom.GetLastFillUpTimeAtBlock(ctx, b); err != nil {
+		return nil, err
+	}
+	state.ExpiresAt = state.LastFillUpTime.Add(DomainRenewPeriod)
+
+	now := timeNow()
+	switch {
+	case state.Auction != nil && int64(state.Auction.EndTime) > now.Unix():
+		state.Status = DomainAuction
+	case now.After(state.ExpiresAt):
+		state.Status = DomainExpired
+	default:
+		state.Status = DomainOwned
+	}
+	return state, nil
+}
+
+// PrepareBid - returns message which should be sent to bid on domain, for free domain it starts new auction.
+// Amount of the message should be at least MinAmount.
+func (m *Manager) PrepareBid(ctx context.Context, name string) (*Bid, error) {
+	state, err := m.GetDomainState(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch state.Status {
+	case DomainFree:
+		return &Bid{
+			To:        m.collectionAddr,
+			MinAmount: MinDomainPrice(name),
+			Body:      BuildStartAuctionPayload(name),
+		}, nil
+	case DomainAuction:
+		return &Bid{
+			To:        state.Address,
+			MinAmount: state.Auction.NextMinBid(),
+			Body:      cell.BeginCell().EndCell(),
+		}, nil
+	case DomainExpired:
+		return &Bid{
+			To:        state.Address,
+			MinAmount: MinDomainPrice(name),
+			Body:      cell.BeginCell().EndCell(),
+		}, nil
+	}
+	return nil, fmt.Errorf("domain is owned until %s", state.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// BuildStartAuctionPayload - body for the collection which deploys domain item and makes the first bid,
+// it is a text comment with the domain name
+func BuildStartAuctionPayload(name string) *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake(name).EndCell()
+}
+
+// ValidateDomainName - checks name of the .ton domain without zone,
+// it should be 4-126 chars long, and contain only a-z, 0-9 and '-' not at the edges
+func ValidateDomainName(name string) error {
+	if len(name) < 4 || len(name) > 126 {
+		return fmt.Errorf("%w: length should be from 4 to 126", ErrInvalidDomainName)
+	}
+
+	if name[0] == '-' || name[len(name)-1] == '-' {
+		return fmt.Errorf("%w: should not start or end with '-'", ErrInvalidDomainName)
+	}
+
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' {
+			return fmt.Errorf("%w: unexpected char '%c'", ErrInvalidDomainName, c)
+		}
+	}
+	return nil
+}
+
+// auctionsStartTime - launch time of .ton domain auctions, min price decreases from it
+const auctionsStartTime = 1659171600
+
+const priceDecayPeriod = 30 * 24 * 60 * 60
+
+// priceDecayMaxPeriods - after this number of periods min price is fixed at the end price
+const priceDecayMaxPeriods = 21
+
+// MinDomainPrice - minimal first bid of the auction at the current time, see MinDomainPriceAt
+func MinDomainPrice(name string) tlb.Coins {
+	return MinDomainPriceAt(name, timeNow())
+}
+
+// MinDomainPriceAt - minimal first bid of the auction at the time, same as collection contract calculates it.
+// It depends on the name length, starts from 10x of the end price at auctions launch,
+// and decreases by 10% every 30 days, after 21 months it is equal to the end price.
+func MinDomainPriceAt(name string, at time.Time) tlb.Coins {
+	var start, end int64
+	switch n := len(name); {
+	case n <= 4:
+		start, end = 1000, 100
+	case n == 5:
+		start, end = 500, 50
+	case n == 6:
+		start, end = 400, 40
+	case n == 7:
+		start, end = 300, 30
+	case n == 8:
+		start, end = 200, 20
+	case n == 9:
+		start, end = 100, 10
+	case n == 10:
+		start, end = 50, 5
+	default:
+		start, end = 10, 1
+	}
+
+	periods := (at.Unix() - auctionsStartTime) / priceDecayPeriod
+	if periods > priceDecayMaxPeriods {
+		return tlb.MustFromTON(fmt.Sprint(end))
+	}
+
+	price := tlb.MustFromTON(fmt.Sprint(start)).Nano()
+	for i := int64(0); i < periods; i++ {
+		price.Div(price.Mul(price, big.NewInt(90)), big.NewInt(100))
+	}
+	return tlb.FromNanoTON(price)
+}
+
+func (d *Domain) GetDomainName(ctx context.Context) (string, error) {
+	b, err := d.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+	return d.GetDomainNameAtBlock(ctx, b)
+}
+
+func (d *Domain) GetDomainNameAtBlock(ctx context.Context, b *ton.BlockIDExt) (string, error) {
+	res, err := d.api.WaitForBlock(b.SeqNo).RunGetMethod(ctx, b, d.GetNFTAddress(), "get_domain")
+	if err != nil {
+		return "", fmt.Errorf("failed to run get_domain method: %w", err)
+	}
+
+	s, err := res.Slice(0)
+	if err != nil {
+		return "", fmt.Errorf("result is not slice, err: %w", err)
+	}
+
+	name, err := s.LoadStringSnake()
+	if err != nil {
+		return "", fmt.Errorf("failed to load domain name: %w", err)
+	}
+	return name, nil
+}
+
+// GetAuctionInfo - returns auction details, nil if domain never had an auction
+func (d *Domain) GetAuctionInfo(ctx context.Context) (*AuctionInfo, error) {
+	b, err := d.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+	return d.GetAuctionInfoAtBlock(ctx, b)
+}
+
+func (d *Domain) GetAuctionInfoAtBlock(ctx context.Context, b *ton.BlockIDExt) (*AuctionInfo, error) {
+	res, err := d.api.WaitForBlock(b.SeqNo).RunGetMethod(ctx, b, d.GetNFTAddress(), "get_auction_info")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run get_auction_info method: %w", err)
+	}
+
+	s, err := res.Slice(0)
+	if err != nil {
+		return nil, fmt.Errorf("max bid address get err: %w", err)
+	}
+
+	addr, err := s.LoadAddr()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load max bid address: %w", err)
+	}
+
+	amount, err := res.Int(1)
+	if err != nil {
+		return nil, fmt.Errorf("max bid amount get err: %w", err)
+	}
+
+	endTime, err := res.Int(2)
+	if err != nil {
+		return nil, fmt.Errorf("auction end time get err: %w", err)
+	}
+
+	if addr.Type() == address.NoneAddress && endTime.Sign() == 0 {
+		return nil, nil
+	}
+
+	return &AuctionInfo{
+		MaxBidAddress: addr,
+		MaxBid:        tlb.FromNanoTON(amount),
+		EndTime:       uint32(endTime.Uint64()),
+	}, nil
+}
+
+func (d *Domain) GetLastFillUpTime(ctx context.Context) (time.Time, error) {
+	b, err := d.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+	return d.GetLastFillUpTimeAtBlock(ctx, b)
+}
+
+func (d *Domain) GetLastFillUpTimeAtBlock(ctx context.Context, b *ton.BlockIDExt) (time.Time, error) {
+	res, err := d.api.WaitForBlock(b.SeqNo).RunGetMethod(ctx, b, d.GetNFTAddress(), "get_last_fill_up_time")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to run get_last_fill_up_time method: %w", err)
+	}
+
+	tm, err := res.Int(0)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("result is not int, err: %w", err)
+	}
+	return time.Unix(tm.Int64(), 0), nil
+}
+
+// GetExpiration - returns time when domain expires if it will not be renewed
+func (d *Domain) GetExpiration(ctx context.Context) (time.Time, error) {
+	tm, err := d.GetLastFillUpTime(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return tm.Add(DomainRenewPeriod), nil
+}
+
+// BuildRenewPayload - prolongs domain for one more period, should be sent by owner.
+// It is a change of the record with zero key, which has no effect on records.
+func (d *Domain) BuildRenewPayload() *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(_OpChangeDNSRecord, 32).
+		MustStoreUInt(randomizer(), 64).
+		MustStoreUInt(0, 256).EndCell()
+}
+
+// BuildDeleteRecordPayload - removes record, it is a change without value
+func (d *Domain) BuildDeleteRecordPayload(name string) *cell.Cell {
+	h := sha256.Sum256([]byte(name))
+
+	return cell.BeginCell().MustStoreUInt(_OpChangeDNSRecord, 32).
+		MustStoreUInt(randomizer(), 64).
+		MustStoreSlice(h[:], 256).EndCell()
+}
+
+// BuildSetNextResolverPayload - delegates resolution of subdomains to the resolver contract,
+// it can be any contract which implements dnsresolve get method
+func (d *Domain) BuildSetNextResolverPayload(resolver *address.Address) *cell.Cell {
+	record := cell.BeginCell().MustStoreUInt(_CategoryNextResolver, 16).MustStoreAddr(resolver).EndCell()
+	return d.BuildSetRecordPayload("dns_next_resolver", cell.BeginCell().MustStoreRef(record).EndCell())
+}
+
+func (d *Domain) GetNextResolverRecord() *address.Address {
+	rec := d.GetRecord("dns_next_resolver")
+	if rec == nil {
+		return nil
+	}
+
+	p, err := rec.BeginParse().LoadRef()
+	if err != nil {
+		return nil
+	}
+
+	category, err := p.LoadUInt(16)
+	if err != nil || category != _CategoryNextResolver {
+		return nil
+	}
+
+	addr, err := p.LoadAddr()
+	if err != nil {
+		return nil
+	}
+	return addr
+}
+
+// FindVerifiedDomain - returns first of candidate domains which wallet record points to addr.
+// It is not a reverse lookup: there is no standard on-chain reverse record, so candidates should be known in advance,
+// for example domains owned by the address, and each of them is only verified by forward resolution.
+func (c *Client) FindVerifiedDomain(ctx context.Context, addr *address.Address, candidates ...string) (string, error) {
+	for _, name := range candidates {
+		d, err := c.Resolve(ctx, name)
+		if err != nil {
+			if errors.Is(err, ErrNoSuchRecord) {
+				continue
+			}
+			return "", fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+
+		if w := d.GetWalletRecord(); w != nil && w.Equals(addr) {
+			return name, nil
+		}
+	}
+	return "", ErrNoSuchRecord
+}
@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+type getMethodsMock struct {
+	ton.APIClientWrapped
+	results map[string][]any
+}
+
+func (m *getMethodsMock) CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error) {
+	return &ton.BlockIDExt{}, nil
+}
+
+func (m *getMethodsMock) WaitForBlock(seqno uint32) ton.APIClientWrapped {
+	return m
+}
+
+func (m *getMethodsMock) RunGetMethod(ctx context.Context, blockInfo *ton.BlockIDExt, addr *address.Address, method string, params ...any) (*ton.ExecutionResult, error) {
+	res, ok := m.results[method]
+	if !ok {
+		return nil, ton.ContractExecError{Code: ton.ErrCodeContractNotInitialized}
+	}
+
+	// slices are read by callers, so each call gets own copy
+	cp := make([]any, len(res))
+	for i, v := range res {
+		if sl, ok := v.(*cell.Slice); ok {
+			v = sl.Copy()
+		}
+		cp[i] = v
+	}
+	return ton.NewExecutionResult(cp), nil
+}
+
+func addrSlice(a *address.Address) *cell.Slice {
+	return cell.BeginCell().MustStoreAddr(a).EndCell().BeginParse()
+}
+
+func TestManager_GetDomainState(t *testing.T) {
+	item := address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju")
+	bidder := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	api := &getMethodsMock{results: map[string][]any{
+		"get_nft_address_by_index": {addrSlice(item)},
+	}}
+	m := NewManager(api, TONZoneCollection)
+
+	bid, err := m.PrepareBid(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bid.To.Equals(TONZoneCollection) || bid.MinAmount.String() != "102.945566046" || bid.Body.BeginParse().MustLoadUInt(32) != 0 {
+		t.Fatal("incorrect start auction bid")
+	}
+
+	api.results["get_nft_data"] = []any{big.NewInt(-1), big.NewInt(1), addrSlice(TONZoneCollection), addrSlice(bidder), cell.BeginCell().EndCell()}
+	api.results["get_auction_info"] = []any{addrSlice(bidder), big.NewInt(100_000_000_000), big.NewInt(now.Unix() + 3600)}
+	api.results["get_last_fill_up_time"] = []any{big.NewInt(now.Unix() - 100)}
+
+	state, err := m.GetDomainState(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != DomainAuction || !state.Auction.MaxBidAddress.Equals(bidder) || state.Auction.NextMinBid().String() != "105" {
+		t.Fatal("incorrect auction state", state.Status)
+	}
+
+	api.results["get_auction_info"] = []any{addrSlice(address.NewAddressNone()), big.NewInt(0), big.NewInt(0)}
+	state, err = m.GetDomainState(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != DomainOwned || state.Auction != nil || !state.ExpiresAt.Equal(now.Add(DomainRenewPeriod-100*time.Second)) {
+		t.Fatal("incorrect owned state", state.Status)
+	}
+
+	if _, err = m.PrepareBid(context.Background(), "alice"); err == nil {
+		t.Fatal("should not bid on owned domain")
+	}
+
+	api.results["get_last_fill_up_time"] = []any{big.NewInt(now.Add(-DomainRenewPeriod).Unix() - 1)}
+	bid, err = m.PrepareBid(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bid.To.Equals(item) || bid.MinAmount.String() != "102.945566046" {
+		t.Fatal("incorrect expired domain bid")
+	}
+}
+
+func TestValidateDomainName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"alice":    true,
+		"my-site1": true,
+		"abc":      false,
+		"-abcd":    false,
+		"abcd-":    false,
+		"Alice":    false,
+		"al.ce":    false,
+	} {
+		if err := ValidateDomainName(name); (err == nil) != valid {
+			t.Fatal("incorrect validation of", name, err)
+		} else if err != nil && !errors.Is(err, ErrInvalidDomainName) {
+			t.Fatal("incorrect error type", err)
+		}
+	}
+
+	if MinDomainPrice("abcd").String() != "100" || MinDomainPrice("abcdefghijk").String() != "1" {
+		t.Fatal("incorrect min price")
+	}
+
+	launch := time.Unix(1659171600, 0)
+	for at, price := range map[time.Time]string{
+		launch:                               "1000",
+		launch.Add(30*24*time.Hour - 1):      "1000",
+		launch.Add(30 * 24 * time.Hour):      "900",
+		launch.Add(2 * 30 * 24 * time.Hour):  "810",
+		launch.Add(21 * 30 * 24 * time.Hour): "109.418989128",
+		launch.Add(22 * 30 * 24 * time.Hour): "100",
+	} {
+		if got := MinDomainPriceAt("abcd", at).String(); got != price {
+			t.Fatal("incorrect min price at", at.Unix()-launch.Unix(), got)
+		}
+	}
+}
+
+func TestDomain_Builders(t *testing.T) {
+	randomizer = func() uint64 {
+		return 777
+	}
+
+	resolver := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+
+	d := Domain{Records: cell.NewDict(256)}
+	renew := d.BuildRenewPayload().BeginParse()
+	if renew.MustLoadUInt(32) != 0x4eb1f0f9 || renew.MustLoadUInt(64) != 777 || renew.MustLoadBigUInt(256).Sign() != 0 || renew.RefsNum() != 0 {
+		t.Fatal("incorrect renew payload")
+	}
+
+	s := d.BuildSetNextResolverPayload(resolver).BeginParse()
+	s.MustLoadUInt(32 + 64)
+	key := s.MustLoadSlice(256)
+
+	if err := d.Records.Set(cell.BeginCell().MustStoreSlice(key, 256).EndCell(), cell.BeginCell().MustStoreBuilder(s.MustToCell().ToBuilder()).EndCell()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.GetNextResolverRecord().Equals(resolver) {
+		t.Fatal("incorrect next resolver record")
+	}
+}
@@ -31,6 +31,8 @@ type TonApi interface {
 type Domain struct {
 	Records *cell.Dictionary
 	*nft.ItemEditableClient
+
+	api TonApi
 }
 
 type Client struct {
@@ -130,6 +132,7 @@ func (c *Client) resolve(ctx context.Context, contractAddr *address.Address, cha
 			return &Domain{
 				Records:            cell.NewDict(256),
 				ItemEditableClient: nft.NewItemEditableClient(c.api, contractAddr),
+				api:                c.api,
 			}, nil
 		}
 		return nil, fmt.Errorf("data get err: %w", err)
@@ -164,6 +167,7 @@ func (c *Client) resolve(ctx context.Context, contractAddr *address.Address, cha
 	return &Domain{
 		Records:            records,
 		ItemEditableClient: nft.NewItemEditableClient(c.api, contractAddr),
+		api:                c.api,
 	}, nil
 }
 
@@ -0,0 +1,354 @@
+package jetton
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+type EventType string
+
+const (
+	EventTransfer             EventType = "TRANSFER"
+	EventInternalTransfer     EventType = "INTERNAL_TRANSFER"
+	EventTransferNotification EventType = "TRANSFER_NOTIFICATION"
+	EventExcesses             EventType = "EXCESSES"
+	EventBurn                 EventType = "BURN"
+	EventBurnNotification     EventType = "BURN_NOTIFICATION"
+)
+
+const (
+	OpTransfer             = 0x0f8a7ea5
+	OpInternalTransfer     = 0x178d4519
+	OpTransferNotification = 0x7362d09c
+	OpExcesses             = 0xd53276db
+	OpBurn                 = 0x595f07bc
+	OpBurnNotification     = 0x7bdd97de
+
+	_BouncePrefix = 0xffffffff
+)
+
+type BurnNotificationPayload struct {
+	_                   tlb.Magic        `tlb:"#7bdd97de"`
+	QueryID             uint64           `tlb:"## 64"`
+	Amount              tlb.Coins        `tlb:"."`
+	Sender              *address.Address `tlb:"addr"`
+	ResponseDestination *address.Address `tlb:"addr"`
+}
+
+// Event - jetton operation found in the transaction message
+type Event struct {
+	Type    EventType
+	QueryID uint64
+	// Amount - amount of jettons, zero for excesses
+	Amount tlb.Coins
+	// Sender - owner of jettons which are sent or burned
+	Sender *address.Address
+	// Receiver - owner which receives jettons, it is nil when not known from the message,
+	// for example for internal transfer it is known only from the next transfer notification
+	Receiver            *address.Address
+	ResponseDestination *address.Address
+	ForwardTONAmount    tlb.Coins
+	ForwardPayload      *cell.Cell
+	CustomPayload       *cell.Cell
+
+	// Bounced - message is returned back, only op, query id and amount are known for such events
+	Bounced bool
+	// ParseError - message has jetton op, but its body is malformed, so only type and message details are known
+	ParseError error
+	// Incoming - event is from the incoming message of the transaction, otherwise from the outgoing
+	Incoming bool
+
+	// Message details
+	Src       *address.Address
+	Dst       *address.Address
+	TON       tlb.Coins
+	CreatedLT uint64
+
+	// Transaction details, for outgoing messages it is the transaction which created the message
+	TxHash    []byte
+	TxLT      uint64
+	TxNow     uint32
+	TxSuccess bool
+
+	// key of the incoming message of the transaction, used to link outgoing messages with their cause
+	parentKey string
+}
+
+// Flow - chain of events caused by one initial message, for example
+// transfer -> internal_transfer -> transfer_notification + excesses
+type Flow struct {
+	Events []*Event
+}
+
+// DecodeTransaction - decodes jetton events from the incoming and outgoing messages of transaction,
+// messages which are not jetton operations are skipped, malformed jetton messages are returned with ParseError.
+func DecodeTransaction(tx *tlb.Transaction) ([]*Event, error) {
+	success := tx.IsSuccess()
+
+	var events []*Event
+	var parentKey string
+	if tx.IO.In != nil && tx.IO.In.MsgType == tlb.MsgTypeInternal {
+		in := tx.IO.In.AsInternal()
+		parentKey = messageKey(in)
+
+		if ev := decodeMessage(in); ev != nil {
+			ev.Incoming = true
+			fillTx(ev, tx, success)
+			events = append(events, ev)
+		}
+	}
+
+	if tx.IO.Out != nil {
+		outs, err := tx.IO.Out.ToSlice()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load outgoing messages: %w", err)
+		}
+
+		for _, out := range outs {
+			if out.MsgType != tlb.MsgTypeInternal {
+				continue
+			}
+
+			if ev := decodeMessage(out.AsInternal()); ev != nil {
+				ev.parentKey = parentKey
+				fillTx(ev, tx, success)
+				events = append(events, ev)
+			}
+		}
+	}
+	return events, nil
+}
+
+// DecodeTransactions - decodes jetton events from the list of transactions, usually received from ListTransactions
+func DecodeTransactions(txs []*tlb.Transaction) ([]*Event, error) {
+	var events []*Event
+	for _, tx := range txs {
+		evs, err := DecodeTransaction(tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode transaction %d: %w", tx.LT, err)
+		}
+		events = append(events, evs...)
+	}
+	return events, nil
+}
+
+// Correlate - groups events into flows, events are linked when they represent the same message
+// seen from both sides, or when outgoing message was caused by the incoming one.
+// Transactions of all participants (owner, jetton wallets) should be decoded to get the full chain.
+func Correlate(events []*Event) []*Flow {
+	parent := make([]int, len(events))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	union := func(a, b int) {
+		parent[find(a)] = find(b)
+	}
+
+	byMsg := map[string]int{}
+	for i, ev := range events {
+		key := ev.key()
+		if j, ok := byMsg[key]; ok {
+			union(i, j)
+		} else {
+			byMsg[key] = i
+		}
+	}
+
+	for i, ev := range events {
+		if ev.parentKey == "" {
+			continue
+		}
+		if j, ok := byMsg[ev.parentKey]; ok {
+			union(i, j)
+		}
+	}
+
+	groups := map[int]*Flow{}
+	var flows []*Flow
+	for i, ev := range events {
+		root := find(i)
+		f, ok := groups[root]
+		if !ok {
+			f = &Flow{}
+			groups[root] = f
+			flows = append(flows, f)
+		}
+		f.Events = append(f.Events, ev)
+	}
+
+	for _, f := range flows {
+		sort.SliceStable(f.Events, func(i, j int) bool {
+			return f.Events[i].CreatedLT < f.Events[j].CreatedLT
+		})
+	}
+	sort.SliceStable(flows, func(i, j int) bool {
+		return flows[i].Events[0].CreatedLT < flows[j].Events[0].CreatedLT
+	})
+	return flows
+}
+
+// Find - returns first event of the type in flow, or nil
+func (f *Flow) Find(typ EventType) *Event {
+	for _, ev := range f.Events {
+		if ev.Type == typ {
+			return ev
+		}
+	}
+	return nil
+}
+
+func (e *Event) key() string {
+	return fmt.Sprintf("%s:%d", e.Src.String(), e.CreatedLT)
+}
+
+func messageKey(msg *tlb.InternalMessage) string {
+	return fmt.Sprintf("%s:%d", msg.SrcAddr.String(), msg.CreatedLT)
+}
+
+func decodeMessage(msg *tlb.InternalMessage) *Event {
+	if msg.Body == nil {
+		return nil
+	}
+
+	s := msg.Body.BeginParse()
+	op, err := s.LoadUInt(32)
+	if err != nil {
+		// not a jetton message
+		return nil
+	}
+
+	ev := &Event{
+		Src:       msg.SrcAddr,
+		Dst:       msg.DstAddr,
+		TON:       msg.Amount,
+		CreatedLT: msg.CreatedLT,
+		Bounced:   msg.Bounced,
+	}
+
+	if msg.Bounced {
+		if op != _BouncePrefix {
+			return nil
+		}
+		return decodeBounced(ev, s)
+	}
+
+	switch op {
+	case OpTransfer:
+		ev.Type = EventTransfer
+		var p TransferPayload
+		if err = tlb.LoadFromCell(&p, msg.Body.BeginParse()); err != nil {
+			ev.ParseError = fmt.Errorf("failed to parse transfer: %w", err)
+			return ev
+		}
+		ev.QueryID, ev.Amount = p.QueryID, p.Amount
+		ev.Sender, ev.Receiver = msg.SrcAddr, p.Destination
+		ev.ResponseDestination, ev.CustomPayload = p.ResponseDestination, p.CustomPayload
+		ev.ForwardTONAmount, ev.ForwardPayload = p.ForwardTONAmount, p.ForwardPayload
+	case OpInternalTransfer:
+		ev.Type = EventInternalTransfer
+		p, err := ParseInternalTransfer(msg.Body)
+		if err != nil {
+			ev.ParseError = err
+			return ev
+		}
+		ev.QueryID, ev.Amount = p.QueryID, p.Amount
+		ev.Sender = p.From
+		ev.ResponseDestination = p.ResponseAddress
+		ev.ForwardTONAmount, ev.ForwardPayload = p.ForwardTONAmount, p.ForwardPayload
+	case OpTransferNotification:
+		ev.Type = EventTransferNotification
+		p, err := ParseTransferNotification(msg.Body)
+		if err != nil {
+			ev.ParseError = err
+			return ev
+		}
+		ev.QueryID, ev.Amount = p.QueryID, p.Amount
+		ev.Sender, ev.Receiver = p.Sender, msg.DstAddr
+		ev.ForwardPayload = p.ForwardPayload
+	case OpExcesses:
+		ev.Type = EventExcesses
+		ev.Amount = tlb.ZeroCoins
+		ev.Receiver = msg.DstAddr
+		p, err := ParseExcesses(msg.Body)
+		if err != nil {
+			ev.ParseError = err
+			return ev
+		}
+		ev.QueryID = p.QueryID
+	case OpBurn:
+		ev.Type = EventBurn
+		var p BurnPayload
+		if err = tlb.LoadFromCell(&p, msg.Body.BeginParse()); err != nil {
+			ev.ParseError = fmt.Errorf("failed to parse burn: %w", err)
+			return ev
+		}
+		ev.QueryID, ev.Amount = p.QueryID, p.Amount
+		ev.Sender = msg.SrcAddr
+		ev.ResponseDestination, ev.CustomPayload = p.ResponseDestination, p.CustomPayload
+	case OpBurnNotification:
+		ev.Type = EventBurnNotification
+		var p BurnNotificationPayload
+		if err = tlb.LoadFromCell(&p, msg.Body.BeginParse()); err != nil {
+			ev.ParseError = fmt.Errorf("failed to parse burn notification: %w", err)
+			return ev
+		}
+		ev.QueryID, ev.Amount = p.QueryID, p.Amount
+		ev.Sender = p.Sender
+		ev.ResponseDestination = p.ResponseDestination
+	default:
+		return nil
+	}
+	return ev
+}
+
+// decodeBounced - bounced body can be truncated, so only op, query id and amount are parsed
+func decodeBounced(ev *Event, s *cell.Slice) *Event {
+	op, err := s.LoadUInt(32)
+	if err != nil {
+		return nil
+	}
+
+	switch op {
+	case OpInternalTransfer:
+		ev.Type = EventInternalTransfer
+	case OpBurnNotification:
+		ev.Type = EventBurnNotification
+	case OpTransferNotification:
+		ev.Type = EventTransferNotification
+	default:
+		return nil
+	}
+
+	if ev.QueryID, err = s.LoadUInt(64); err != nil {
+		ev.ParseError = fmt.Errorf("failed to load bounced query id: %w", err)
+		return ev
+	}
+
+	amt, err := s.LoadBigCoins()
+	if err != nil {
+		ev.ParseError = fmt.Errorf("failed to load bounced amount: %w", err)
+		return ev
+	}
+	ev.Amount = tlb.FromNanoTON(amt)
+	return ev
+}
+
+func fillTx(ev *Event, tx *tlb.Transaction, success bool) {
+	ev.TxHash = tx.Hash
+	ev.TxLT = tx.LT
+	ev.TxNow = tx.Now
+	ev.TxSuccess = success
+}
@@ -0,0 +1,171 @@
+package jetton
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+func makeTx(lt uint64, in *tlb.InternalMessage, outs ...*tlb.InternalMessage) *tlb.Transaction {
+	tx := &tlb.Transaction{
+		LT:   lt,
+		Hash: big.NewInt(int64(lt)).FillBytes(make([]byte, 32)),
+	}
+	tx.Description.Description = tlb.TransactionDescriptionOrdinary{
+		ComputePhase: tlb.ComputePhase{Phase: tlb.ComputePhaseVM{Success: true}},
+	}
+
+	if in != nil {
+		tx.IO.In = &tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: in}
+	}
+
+	if len(outs) > 0 {
+		dict := cell.NewDict(15)
+		for i, out := range outs {
+			out.CreatedLT = lt + uint64(i) + 1
+			c, err := tlb.ToCell(out)
+			if err != nil {
+				panic(err)
+			}
+			if err = dict.SetIntKey(big.NewInt(int64(i)), cell.BeginCell().MustStoreRef(c).EndCell()); err != nil {
+				panic(err)
+			}
+		}
+		tx.IO.Out = &tlb.MessagesList{List: dict}
+	}
+	return tx
+}
+
+func mustCell(v any) *cell.Cell {
+	c, err := tlb.ToCell(v)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestDecodeTransactions(t *testing.T) {
+	ownerA := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	ownerB := address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju")
+	walletA := address.MustParseAddr("EQAbMQzuuGiCne0R7QEj9nrXsjM7gNjeVmrlBZouyC-SCLlO")
+	walletB := address.MustParseAddr("EQD4vUD2PYRLQd0mSwjmnnWSpeulTjZoFypJVUJAyJoUbrRu")
+
+	comment := cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake("deposit 42").EndCell()
+
+	transfer := &tlb.InternalMessage{
+		Bounce: true, SrcAddr: ownerA, DstAddr: walletA, Amount: tlb.MustFromTON("0.1"),
+		Body: mustCell(TransferPayload{
+			QueryID: 5, Amount: tlb.MustFromTON("10"), Destination: ownerB, ResponseDestination: ownerA,
+			ForwardTONAmount: tlb.MustFromTON("0.01"), ForwardPayload: comment,
+		}),
+	}
+	ownerTx := makeTx(100, nil, transfer)
+
+	internal := &tlb.InternalMessage{
+		Bounce: true, SrcAddr: walletA, DstAddr: walletB, Amount: tlb.MustFromTON("0.09"),
+		Body: mustCell(InternalTransferPayload{
+			QueryID: 5, Amount: tlb.MustFromTON("10"), From: ownerA, ResponseAddress: ownerA,
+			ForwardTONAmount: tlb.MustFromTON("0.01"), ForwardPayload: comment,
+		}),
+	}
+	walletATx := makeTx(200, transfer, internal)
+
+	notification := &tlb.InternalMessage{
+		SrcAddr: walletB, DstAddr: ownerB, Amount: tlb.MustFromTON("0.01"),
+		Body: mustCell(TransferNotification{QueryID: 5, Amount: tlb.MustFromTON("10"), Sender: ownerA, ForwardPayload: comment}),
+	}
+	excesses := &tlb.InternalMessage{
+		SrcAddr: walletB, DstAddr: ownerA, Amount: tlb.MustFromTON("0.07"),
+		Body: mustCell(ExcessesPayload{QueryID: 5}),
+	}
+	walletBTx := makeTx(300, internal, notification, excesses)
+	ownerBTx := makeTx(400, notification)
+
+	// unrelated bounced internal transfer
+	bounced := &tlb.InternalMessage{
+		Bounced: true, SrcAddr: walletB, DstAddr: walletA, Amount: tlb.MustFromTON("0.05"), CreatedLT: 450,
+		Body: cell.BeginCell().MustStoreUInt(0xffffffff, 32).MustStoreUInt(OpInternalTransfer, 32).
+			MustStoreUInt(77, 64).MustStoreBigCoins(tlb.MustFromTON("3").Nano()).EndCell(),
+	}
+	bouncedTx := makeTx(500, bounced)
+
+	events, err := DecodeTransactions([]*tlb.Transaction{ownerTx, walletATx, walletBTx, ownerBTx, bouncedTx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 8 {
+		t.Fatal("incorrect events count", len(events))
+	}
+
+	flows := Correlate(events)
+	if len(flows) != 2 {
+		t.Fatal("incorrect flows count", len(flows))
+	}
+
+	f := flows[0]
+	if len(f.Events) != 7 {
+		t.Fatal("incorrect flow events count", len(f.Events))
+	}
+
+	n := f.Find(EventTransferNotification)
+	if n == nil || !n.Sender.Equals(ownerA) || !n.Receiver.Equals(ownerB) || n.Amount.String() != "10" || n.QueryID != 5 {
+		t.Fatal("incorrect notification")
+	}
+	if n.ForwardPayload.BeginParse().MustLoadUInt(32) != 0 || !n.TxSuccess {
+		t.Fatal("incorrect notification payload")
+	}
+
+	tr := f.Find(EventTransfer)
+	if tr == nil || tr.Incoming || !tr.Receiver.Equals(ownerB) || tr.ForwardTONAmount.String() != "0.01" {
+		t.Fatal("incorrect transfer")
+	}
+
+	if ex := f.Find(EventExcesses); ex == nil || !ex.Receiver.Equals(ownerA) || ex.TON.String() != "0.07" {
+		t.Fatal("incorrect excesses")
+	}
+
+	b := flows[1].Events[0]
+	if !b.Bounced || b.Type != EventInternalTransfer || b.QueryID != 77 || b.Amount.String() != "3" {
+		t.Fatal("incorrect bounced event")
+	}
+}
+
+func TestDecodeTransaction_Malformed(t *testing.T) {
+	owner := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	jw := address.MustParseAddr("EQAbMQzuuGiCne0R7QEj9nrXsjM7gNjeVmrlBZouyC-SCLlO")
+
+	truncated := &tlb.InternalMessage{
+		SrcAddr: owner, DstAddr: jw, Amount: tlb.MustFromTON("0.1"),
+		Body: cell.BeginCell().MustStoreUInt(OpTransfer, 32).MustStoreUInt(1, 16).EndCell(),
+	}
+	excesses := &tlb.InternalMessage{
+		SrcAddr: jw, DstAddr: owner, Amount: tlb.MustFromTON("0.05"),
+		Body: cell.BeginCell().MustStoreUInt(OpExcesses, 32).MustStoreUInt(7, 64).EndCell(),
+	}
+
+	tx := makeTx(100, truncated, excesses)
+	desc := tx.Description.Description.(tlb.TransactionDescriptionOrdinary)
+	desc.ActionPhase = &tlb.ActionPhase{Success: false}
+	tx.Description.Description = desc
+
+	events, err := DecodeTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatal("all messages should be decoded", len(events))
+	}
+	if events[0].Type != EventTransfer || events[0].ParseError == nil {
+		t.Fatal("transfer should be marked as unparsed")
+	}
+	if events[1].Type != EventExcesses || events[1].ParseError != nil || events[1].QueryID != 7 {
+		t.Fatal("excesses should be parsed")
+	}
+	if events[0].TxSuccess {
+		t.Fatal("transaction with failed action phase should not be successful")
+	}
+}
@@ -0,0 +1,242 @@
+package jetton
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton/nft"
+)
+
+const DefaultDecimals = 9
+
+var ErrUnsupportedURI = errors.New("unsupported content uri")
+
+// Metadata - resolved jetton metadata (TEP-64), on-chain values have priority over off-chain json
+type Metadata struct {
+	Name        string
+	Symbol      string
+	Description string
+	Image       string
+	ImageData   []byte
+	Decimals    int
+	// URI - off-chain content location, empty for fully on-chain content
+	URI string
+}
+
+// Fetcher - loads off-chain content by uri
+type Fetcher interface {
+	Fetch(ctx context.Context, uri string) ([]byte, error)
+}
+
+type FetcherFunc func(ctx context.Context, uri string) ([]byte, error)
+
+func (f FetcherFunc) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	return f(ctx, uri)
+}
+
+// HTTPFetcher - loads content using http, ipfs and ton storage uris are converted to gateway urls
+type HTTPFetcher struct {
+	Client *http.Client
+	// IPFSGateway - prefix for ipfs:// uris, default is https://ipfs.io/ipfs/
+	IPFSGateway string
+	// TONStorageGateway - prefix for tonstorage:// uris, for example http://127.0.0.1:8080/gateway/,
+	// when empty such uris are not supported
+	TONStorageGateway string
+	// MaxSize - max size of the response, default is 1 MB
+	MaxSize int64
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	url, err := f.toURL(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	maxSize := f.MaxSize
+	if maxSize <= 0 {
+		maxSize = 1 << 20
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("response is too big")
+	}
+	return data, nil
+}
+
+func (f *HTTPFetcher) toURL(uri string) (string, error) {
+	switch {
+	case strings.HasPrefix(uri, "https://"), strings.HasPrefix(uri, "http://"):
+		return uri, nil
+	case strings.HasPrefix(uri, "ipfs://"):
+		gw := f.IPFSGateway
+		if gw == "" {
+			gw = "https://ipfs.io/ipfs/"
+		}
+		return strings.TrimSuffix(gw, "/") + "/" + strings.TrimPrefix(uri, "ipfs://"), nil
+	case strings.HasPrefix(uri, "tonstorage://"):
+		if f.TONStorageGateway == "" {
+			return "", fmt.Errorf("%w: ton storage gateway is not configured", ErrUnsupportedURI)
+		}
+		return strings.TrimSuffix(f.TONStorageGateway, "/") + "/" + strings.TrimPrefix(uri, "tonstorage://"), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedURI, uri)
+}
+
+type MetadataResolver struct {
+	fetcher Fetcher
+}
+
+// NewMetadataResolver - creates resolver, when fetcher is nil, HTTPFetcher with default settings is used
+func NewMetadataResolver(fetcher Fetcher) *MetadataResolver {
+	if fetcher == nil {
+		fetcher = &HTTPFetcher{}
+	}
+	return &MetadataResolver{fetcher: fetcher}
+}
+
+type offchainMetadata struct {
+	Name        string          `json:"name"`
+	Symbol      string          `json:"symbol"`
+	Description string          `json:"description"`
+	Image       string          `json:"image"`
+	ImageData   string          `json:"image_data"`
+	Decimals    json.RawMessage `json:"decimals"`
+}
+
+// Resolve - merges on-chain attributes with json loaded from off-chain uri
+func (r *MetadataResolver) Resolve(ctx context.Context, content nft.ContentAny) (*Metadata, error) {
+	meta := &Metadata{
+		Decimals: DefaultDecimals,
+	}
+
+	var on *nft.ContentOnchain
+	switch c := content.(type) {
+	case *nft.ContentOnchain:
+		on = c
+	case *nft.ContentSemichain:
+		on = &c.ContentOnchain
+		meta.URI = c.URI
+	case *nft.ContentOffchain:
+		meta.URI = c.URI
+	case nil:
+		return nil, fmt.Errorf("content is nil")
+	default:
+		return nil, fmt.Errorf("unknown content type %T", content)
+	}
+
+	if meta.URI != "" {
+		data, err := r.fetcher.Fetch(ctx, meta.URI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch off-chain content: %w", err)
+		}
+
+		var off offchainMetadata
+		if err = json.Unmarshal(data, &off); err != nil {
+			return nil, fmt.Errorf("failed to parse off-chain content: %w", err)
+		}
+
+		meta.Name, meta.Symbol, meta.Description, meta.Image = off.Name, off.Symbol, off.Description, off.Image
+		if off.ImageData != "" {
+			// image_data is base64 encoded in json, by TEP-64
+			if meta.ImageData, err = base64.StdEncoding.DecodeString(off.ImageData); err != nil {
+				meta.ImageData = []byte(off.ImageData)
+			}
+		}
+
+		if len(off.Decimals) > 0 {
+			str := strings.Trim(string(off.Decimals), `"`)
+			if meta.Decimals, err = parseDecimals(str); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if on != nil {
+		// fields are filled when content is constructed manually, without attributes
+		overrideStr(&meta.Name, on.Name)
+		overrideStr(&meta.Description, on.Description)
+		overrideStr(&meta.Image, on.Image)
+		if len(on.ImageData) > 0 {
+			meta.ImageData = on.ImageData
+		}
+
+		overrideStr(&meta.Name, on.GetAttribute("name"))
+		overrideStr(&meta.Symbol, on.GetAttribute("symbol"))
+		overrideStr(&meta.Description, on.GetAttribute("description"))
+		overrideStr(&meta.Image, on.GetAttribute("image"))
+		if data := on.GetAttributeBinary("image_data"); len(data) > 0 {
+			meta.ImageData = data
+		}
+
+		if dec := on.GetAttribute("decimals"); dec != "" {
+			var err error
+			if meta.Decimals, err = parseDecimals(dec); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return meta, nil
+}
+
+// GetJettonMetadata - loads jetton data and resolves its content
+func (c *Client) GetJettonMetadata(ctx context.Context, resolver *MetadataResolver) (*Metadata, error) {
+	data, err := c.GetJettonData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return resolver.Resolve(ctx, data.Content)
+}
+
+// Coins - converts amount in minimal units to coins with jetton decimals
+func (m *Metadata) Coins(amount *big.Int) (tlb.Coins, error) {
+	return tlb.FromNano(amount, m.Decimals)
+}
+
+func parseDecimals(str string) (int, error) {
+	dec, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil || dec < 0 || dec > 255 {
+		return 0, fmt.Errorf("incorrect decimals '%s'", str)
+	}
+	return dec, nil
+}
+
+func overrideStr(dst *string, val string) {
+	if val != "" {
+		*dst = val
+	}
+}
@@ -0,0 +1,110 @@
+package jetton
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/ton/nft"
+)
+
+func TestMetadataResolver_Resolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/jetton.json", "/ipfs/QmHash/meta.json":
+			_, _ = w.Write([]byte(`{"name":"Tether USD","symbol":"USD₮","decimals":"6","image":"https://example.com/usdt.png","description":"stable","image_data":"PHN2Zz48L3N2Zz4="}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	r := NewMetadataResolver(&HTTPFetcher{
+		IPFSGateway: srv.URL + "/ipfs/",
+	})
+
+	ctx := context.Background()
+	for _, uri := range []string{srv.URL + "/jetton.json", "ipfs://QmHash/meta.json"} {
+		meta, err := r.Resolve(ctx, &nft.ContentOffchain{URI: uri})
+		if err != nil {
+			t.Fatal(uri, err)
+		}
+
+		if meta.Name != "Tether USD" || meta.Symbol != "USD₮" || meta.Decimals != 6 || meta.Description != "stable" || meta.URI != uri {
+			t.Fatal(uri, "incorrect metadata", meta)
+		}
+		if string(meta.ImageData) != "<svg></svg>" {
+			t.Fatal(uri, "image data should be decoded from base64", string(meta.ImageData))
+		}
+
+		amt, err := meta.Coins(big.NewInt(1500000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amt.String() != "1.5" {
+			t.Fatal("incorrect formatting", amt.String())
+		}
+	}
+
+	if _, err := r.Resolve(ctx, &nft.ContentOffchain{URI: srv.URL + "/missing.json"}); err == nil {
+		t.Fatal("should fail for missing content")
+	}
+
+	if _, err := r.Resolve(ctx, &nft.ContentOffchain{URI: "tonstorage://bag/meta.json"}); !errors.Is(err, ErrUnsupportedURI) {
+		t.Fatal("should be unsupported, but:", err)
+	}
+}
+
+func TestMetadataResolver_Semichain(t *testing.T) {
+	var fetched string
+	r := NewMetadataResolver(FetcherFunc(func(ctx context.Context, uri string) ([]byte, error) {
+		fetched = uri
+		return []byte(`{"name":"Off","symbol":"OFF","decimals":3,"image":"https://example.com/off.png"}`), nil
+	}))
+
+	on := &nft.ContentOnchain{}
+	if err := on.SetAttribute("name", "On"); err != nil {
+		t.Fatal(err)
+	}
+	if err := on.SetAttribute("decimals", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := on.SetAttribute("uri", "tonstorage://bag/meta.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := on.ContentCell()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := nft.ContentFromCell(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := r.Resolve(context.Background(), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fetched != "tonstorage://bag/meta.json" {
+		t.Fatal("incorrect fetched uri", fetched)
+	}
+
+	if meta.Name != "On" || meta.Symbol != "OFF" || meta.Decimals != 2 || meta.Image != "https://example.com/off.png" {
+		t.Fatal("on-chain values should have priority", meta)
+	}
+
+	// fully on-chain content without decimals uses default
+	meta, err = r.Resolve(context.Background(), &nft.ContentOnchain{Name: "Manual"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Name != "Manual" || meta.Decimals != DefaultDecimals {
+		t.Fatal("incorrect on-chain metadata", meta)
+	}
+}
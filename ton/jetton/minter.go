@@ -0,0 +1,409 @@
+package jetton
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton/nft"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var ErrUnexpectedOpcode = errors.New("unexpected opcode")
+
+// InternalTransferPayload - message from minter or jetton wallet to the receiver's jetton wallet
+type InternalTransferPayload struct {
+	_                tlb.Magic        `tlb:"#178d4519"`
+	QueryID          uint64           `tlb:"## 64"`
+	Amount           tlb.Coins        `tlb:"."`
+	From             *address.Address `tlb:"addr"`
+	ResponseAddress  *address.Address `tlb:"addr"`
+	ForwardTONAmount tlb.Coins        `tlb:"."`
+	ForwardPayload   *cell.Cell       `tlb:"either . ^"`
+}
+
+// ExcessesPayload - remaining TON returned to response destination
+type ExcessesPayload struct {
+	_       tlb.Magic `tlb:"#d53276db"`
+	QueryID uint64    `tlb:"## 64"`
+}
+
+// Standard jetton-minter admin messages
+
+type ChangeAdminPayload struct {
+	_        tlb.Magic        `tlb:"#00000003"`
+	QueryID  uint64           `tlb:"## 64"`
+	NewAdmin *address.Address `tlb:"addr"`
+}
+
+type ChangeContentPayload struct {
+	_       tlb.Magic  `tlb:"#00000004"`
+	QueryID uint64     `tlb:"## 64"`
+	Content *cell.Cell `tlb:"^"`
+}
+
+// Governance jetton-minter (used by stablecoins) admin messages
+
+type GovernanceMintPayload struct {
+	_         tlb.Magic               `tlb:"#642b7d07"`
+	QueryID   uint64                  `tlb:"## 64"`
+	ToAddress *address.Address        `tlb:"addr"`
+	TonAmount tlb.Coins               `tlb:"."`
+	MasterMsg InternalTransferPayload `tlb:"^"`
+}
+
+type GovernanceChangeAdminPayload struct {
+	_        tlb.Magic        `tlb:"#6501f354"`
+	QueryID  uint64           `tlb:"## 64"`
+	NewAdmin *address.Address `tlb:"addr"`
+}
+
+type ClaimAdminPayload struct {
+	_       tlb.Magic `tlb:"#fb88e119"`
+	QueryID uint64    `tlb:"## 64"`
+}
+
+type CallToPayload struct {
+	_         tlb.Magic        `tlb:"#235caf52"`
+	QueryID   uint64           `tlb:"## 64"`
+	ToAddress *address.Address `tlb:"addr"`
+	TonAmount tlb.Coins        `tlb:"."`
+	Action    *cell.Cell       `tlb:"^"`
+}
+
+// SetStatusPayload - action for CallToPayload, which locks jetton wallet
+type SetStatusPayload struct {
+	_       tlb.Magic `tlb:"#eed236d3"`
+	QueryID uint64    `tlb:"## 64"`
+	Status  uint8     `tlb:"## 4"`
+}
+
+const _GovernanceChangeMetadataOpcode = 0xcb862902
+
+// MintParams - jettons to mint, ForwardPayload will be delivered to the owner with transfer notification
+type MintParams struct {
+	To               *address.Address
+	Amount           tlb.Coins
+	ResponseTo       *address.Address
+	TonAmount        tlb.Coins
+	ForwardTONAmount tlb.Coins
+	ForwardPayload   *cell.Cell
+}
+
+// MinterData - initial data of the standard jetton-minter
+type MinterData struct {
+	TotalSupply tlb.Coins        `tlb:"."`
+	Admin       *address.Address `tlb:"addr"`
+	Content     *cell.Cell       `tlb:"^"`
+	WalletCode  *cell.Cell       `tlb:"^"`
+}
+
+// GovernanceMinterData - initial data of the governance jetton-minter
+type GovernanceMinterData struct {
+	TotalSupply tlb.Coins        `tlb:"."`
+	Admin       *address.Address `tlb:"addr"`
+	NextAdmin   *address.Address `tlb:"addr"`
+	WalletCode  *cell.Cell       `tlb:"^"`
+	MetadataURI *cell.Cell       `tlb:"^"`
+}
+
+// GetMinterStateInit - builds state init of the standard jetton-minter, its address can be calculated using CalcAddress
+func GetMinterStateInit(minterCode, walletCode *cell.Cell, admin *address.Address, content nft.ContentAny) (*tlb.StateInit, error) {
+	con, err := toContentCell(content)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := tlb.ToCell(MinterData{
+		TotalSupply: tlb.ZeroCoins,
+		Admin:       admin,
+		Content:     con,
+		WalletCode:  walletCode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize minter data: %w", err)
+	}
+
+	return &tlb.StateInit{
+		Code: minterCode,
+		Data: data,
+	}, nil
+}
+
+// GetGovernanceMinterStateInit - builds state init of the governance jetton-minter
+func GetGovernanceMinterStateInit(minterCode, walletCode *cell.Cell, admin *address.Address, metadataURI string) (*tlb.StateInit, error) {
+	uri := cell.BeginCell()
+	if err := uri.StoreStringSnake(metadataURI); err != nil {
+		return nil, fmt.Errorf("failed to store metadata uri: %w", err)
+	}
+
+	data, err := tlb.ToCell(GovernanceMinterData{
+		TotalSupply: tlb.ZeroCoins,
+		Admin:       admin,
+		NextAdmin:   address.NewAddressNone(),
+		WalletCode:  walletCode,
+		MetadataURI: uri.EndCell(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize minter data: %w", err)
+	}
+
+	return &tlb.StateInit{
+		Code: minterCode,
+		Data: data,
+	}, nil
+}
+
+// BuildMintPayload - mint message for the standard jetton-minter, should be sent by admin
+func (c *Client) BuildMintPayload(params MintParams) (*cell.Cell, error) {
+	queryID, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := tlb.ToCell(buildInternalTransfer(queryID, params))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert InternalTransferPayload to cell: %w", err)
+	}
+
+	rest, err := restOfInternalTransfer(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cut internal transfer: %w", err)
+	}
+
+	body, err := tlb.ToCell(MintPayload{
+		QueryID:   queryID,
+		ToAddress: params.To,
+		Amount:    params.TonAmount,
+		MasterMsg: MintPayloadMasterMsg{
+			Opcode:       0x178d4519,
+			QueryID:      queryID,
+			JettonAmount: params.Amount,
+			RestData:     rest,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert MintPayload to cell: %w", err)
+	}
+	return body, nil
+}
+
+func (c *Client) BuildChangeAdminPayload(newAdmin *address.Address) (*cell.Cell, error) {
+	queryID, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(ChangeAdminPayload{
+		QueryID:  queryID,
+		NewAdmin: newAdmin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ChangeAdminPayload to cell: %w", err)
+	}
+	return body, nil
+}
+
+func (c *Client) BuildChangeContentPayload(content nft.ContentAny) (*cell.Cell, error) {
+	con, err := toContentCell(content)
+	if err != nil {
+		return nil, err
+	}
+
+	queryID, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(ChangeContentPayload{
+		QueryID: queryID,
+		Content: con,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ChangeContentPayload to cell: %w", err)
+	}
+	return body, nil
+}
+
+// BuildGovernanceMintPayload - mint message for the governance jetton-minter
+func (c *Client) BuildGovernanceMintPayload(params MintParams) (*cell.Cell, error) {
+	queryID, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(GovernanceMintPayload{
+		QueryID:   queryID,
+		ToAddress: params.To,
+		TonAmount: params.TonAmount,
+		MasterMsg: buildInternalTransfer(queryID, params),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert GovernanceMintPayload to cell: %w", err)
+	}
+	return body, nil
+}
+
+// BuildGovernanceChangeAdminPayload - sets next admin, it should be claimed by the new admin using BuildClaimAdminPayload
+func (c *Client) BuildGovernanceChangeAdminPayload(newAdmin *address.Address) (*cell.Cell, error) {
+	queryID, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(GovernanceChangeAdminPayload{
+		QueryID:  queryID,
+		NewAdmin: newAdmin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert GovernanceChangeAdminPayload to cell: %w", err)
+	}
+	return body, nil
+}
+
+func (c *Client) BuildClaimAdminPayload() (*cell.Cell, error) {
+	queryID, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(ClaimAdminPayload{
+		QueryID: queryID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ClaimAdminPayload to cell: %w", err)
+	}
+	return body, nil
+}
+
+func (c *Client) BuildGovernanceChangeMetadataPayload(metadataURI string) (*cell.Cell, error) {
+	queryID, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	b := cell.BeginCell().MustStoreUInt(_GovernanceChangeMetadataOpcode, 32).MustStoreUInt(queryID, 64)
+	if err = b.StoreStringSnake(metadataURI); err != nil {
+		return nil, fmt.Errorf("failed to store metadata uri: %w", err)
+	}
+	return b.EndCell(), nil
+}
+
+// BuildCallToPayload - asks governance minter to send action to the jetton wallet of owner,
+// action can be SetStatusPayload, or transfer and burn payloads for forced operations.
+func (c *Client) BuildCallToPayload(owner *address.Address, tonAmount tlb.Coins, action *cell.Cell) (*cell.Cell, error) {
+	queryID, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(CallToPayload{
+		QueryID:   queryID,
+		ToAddress: owner,
+		TonAmount: tonAmount,
+		Action:    action,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert CallToPayload to cell: %w", err)
+	}
+	return body, nil
+}
+
+// ParseTransferNotification - parses body of the message which owner receives on incoming jetton transfer.
+// Sender of the message should be checked to be the owner's jetton wallet, otherwise notification can be fake.
+func ParseTransferNotification(body *cell.Cell) (*TransferNotification, error) {
+	var n TransferNotification
+	if err := loadTyped(&n, body, 0x7362d09c); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ParseExcesses - parses body of the message with remaining TON returned after jetton operation
+func ParseExcesses(body *cell.Cell) (*ExcessesPayload, error) {
+	var e ExcessesPayload
+	if err := loadTyped(&e, body, 0xd53276db); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ParseInternalTransfer - parses body of the message received by jetton wallet from minter or other wallet
+func ParseInternalTransfer(body *cell.Cell) (*InternalTransferPayload, error) {
+	var t InternalTransferPayload
+	if err := loadTyped(&t, body, 0x178d4519); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func loadTyped(v any, body *cell.Cell, opcode uint64) error {
+	if body == nil {
+		return fmt.Errorf("body is nil")
+	}
+
+	op, err := body.BeginParse().LoadUInt(32)
+	if err != nil {
+		return fmt.Errorf("failed to load opcode: %w", err)
+	}
+
+	if op != opcode {
+		return fmt.Errorf("%w: %x", ErrUnexpectedOpcode, op)
+	}
+
+	if err = tlb.LoadFromCell(v, body.BeginParse()); err != nil {
+		return fmt.Errorf("failed to parse body: %w", err)
+	}
+	return nil
+}
+
+func buildInternalTransfer(queryID uint64, params MintParams) InternalTransferPayload {
+	forward := params.ForwardPayload
+	if forward == nil {
+		forward = cell.BeginCell().EndCell()
+	}
+
+	return InternalTransferPayload{
+		QueryID:          queryID,
+		Amount:           params.Amount,
+		From:             address.NewAddressNone(),
+		ResponseAddress:  params.ResponseTo,
+		ForwardTONAmount: params.ForwardTONAmount,
+		ForwardPayload:   forward,
+	}
+}
+
+// restOfInternalTransfer - MintPayloadMasterMsg has opcode, query id and amount as fields,
+// so we cut them from the serialized internal transfer
+func restOfInternalTransfer(msg *cell.Cell) (*cell.Cell, error) {
+	s := msg.BeginParse()
+	s.MustLoadUInt(32)
+	s.MustLoadUInt(64)
+	if _, err := s.LoadBigCoins(); err != nil {
+		return nil, err
+	}
+	return s.ToCell()
+}
+
+func toContentCell(content nft.ContentAny) (*cell.Cell, error) {
+	if content == nil {
+		return cell.BeginCell().EndCell(), nil
+	}
+
+	con, err := content.ContentCell()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert content to cell: %w", err)
+	}
+	return con, nil
+}
+
+func randomQueryID() (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
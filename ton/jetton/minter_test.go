@@ -0,0 +1,122 @@
+package jetton
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton/nft"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+func TestClient_BuildMintPayload(t *testing.T) {
+	to := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	c := NewJettonMasterClient(nil, to)
+
+	params := MintParams{
+		To:               to,
+		Amount:           tlb.MustFromTON("100"),
+		ResponseTo:       to,
+		TonAmount:        tlb.MustFromTON("0.05"),
+		ForwardTONAmount: tlb.MustFromTON("0.01"),
+		ForwardPayload:   cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake("hello").EndCell(),
+	}
+
+	for name, build := range map[string]func(MintParams) (*cell.Cell, error){
+		"standard":   c.BuildMintPayload,
+		"governance": c.BuildGovernanceMintPayload,
+	} {
+		body, err := build(params)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		s := body.BeginParse()
+		s.MustLoadUInt(32 + 64)
+		if !s.MustLoadAddr().Equals(to) {
+			t.Fatal(name, "incorrect destination")
+		}
+		s.MustLoadBigCoins()
+
+		transfer, err := ParseInternalTransfer(s.MustLoadRef().MustToCell())
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if transfer.Amount.String() != "100" || transfer.ForwardTONAmount.String() != "0.01" ||
+			!transfer.ResponseAddress.Equals(to) || transfer.ForwardPayload.BeginParse().MustLoadUInt(32) != 0 {
+			t.Fatal(name, "incorrect internal transfer")
+		}
+	}
+}
+
+func TestParseTransferNotification(t *testing.T) {
+	sender := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	body, err := tlb.ToCell(TransferNotification{
+		QueryID:        7,
+		Amount:         tlb.MustFromTON("1.5"),
+		Sender:         sender,
+		ForwardPayload: cell.BeginCell().EndCell(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := ParseTransferNotification(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.QueryID != 7 || n.Amount.String() != "1.5" || !n.Sender.Equals(sender) {
+		t.Fatal("incorrect notification")
+	}
+
+	if _, err = ParseExcesses(body); !errors.Is(err, ErrUnexpectedOpcode) {
+		t.Fatal("should be unexpected opcode, but:", err)
+	}
+
+	excess, err := ParseExcesses(cell.BeginCell().MustStoreUInt(0xd53276db, 32).MustStoreUInt(9, 64).EndCell())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if excess.QueryID != 9 {
+		t.Fatal("incorrect excesses")
+	}
+}
+
+func TestGetMinterStateInit(t *testing.T) {
+	admin := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	code := cell.BeginCell().MustStoreUInt(1, 8).EndCell()
+	walletCode := cell.BeginCell().MustStoreUInt(2, 8).EndCell()
+
+	si, err := GetMinterStateInit(code, walletCode, admin, &nft.ContentOffchain{URI: "https://example.com/jetton.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data MinterData
+	if err = tlb.LoadFromCell(&data, si.Data.BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := nft.ContentFromCell(data.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.(*nft.ContentOffchain).URI != "https://example.com/jetton.json" || !data.Admin.Equals(admin) {
+		t.Fatal("incorrect minter data")
+	}
+
+	si, err = GetGovernanceMinterStateInit(code, walletCode, admin, "https://example.com/usd.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gov GovernanceMinterData
+	if err = tlb.LoadFromCell(&gov, si.Data.BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+	if gov.MetadataURI.BeginParse().MustLoadStringSnake() != "https://example.com/usd.json" || gov.NextAdmin.Type() != address.NoneAddress {
+		t.Fatal("incorrect governance minter data")
+	}
+}
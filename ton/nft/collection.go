@@ -258,6 +258,33 @@ func (c *CollectionClient) BuildMintEditablePayload(index *big.Int, owner, edito
 	return body, nil
 }
 
+// BuildMintSBTPayload - mint message for SBT collection, authority can revoke the item
+func (c *CollectionClient) BuildMintSBTPayload(index *big.Int, owner, authority *address.Address, amountForward tlb.Coins, content ContentAny) (_ *cell.Cell, err error) {
+	con, err := toNftContent(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert nft content to cell: %w", err)
+	}
+
+	con = cell.BeginCell().MustStoreAddr(owner).MustStoreRef(con).MustStoreAddr(authority).EndCell()
+
+	rnd, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(ItemMintPayload{
+		QueryID:   rnd,
+		Index:     index,
+		TonAmount: amountForward,
+		Content:   con,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ItemMintPayload to cell: %w", err)
+	}
+
+	return body, nil
+}
+
 func toNftContent(content ContentAny) (*cell.Cell, error) {
 	if content == nil {
 		return cell.BeginCell().EndCell(), nil
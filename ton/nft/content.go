@@ -103,6 +103,10 @@ func ContentFromSlice(s *cell.Slice) (ContentAny, error) {
 }
 
 func getOnchainVal(dict *cell.Dictionary, key string) []byte {
+	if dict == nil {
+		return nil
+	}
+
 	h := sha256.New()
 	h.Write([]byte(key))
 
@@ -0,0 +1,533 @@
+package nft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var ErrNotSequentialCollection = errors.New("collection items are not sequential")
+
+type EventType string
+
+const (
+	EventMint              EventType = "MINT"
+	EventTransfer          EventType = "TRANSFER"
+	EventOwnershipAssigned EventType = "OWNERSHIP_ASSIGNED"
+	EventExcesses          EventType = "EXCESSES"
+)
+
+const (
+	OpTransfer          = 0x5fcc3d14
+	OpOwnershipAssigned = 0x05138d91
+	OpExcesses          = 0xd53276db
+)
+
+type OwnershipAssignedPayload struct {
+	_              tlb.Magic        `tlb:"#05138d91"`
+	QueryID        uint64           `tlb:"## 64"`
+	PrevOwner      *address.Address `tlb:"addr"`
+	ForwardPayload *cell.Cell       `tlb:"either . ^"`
+}
+
+type IndexerAPI interface {
+	TonApi
+	GetAccount(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error)
+	ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error)
+	FindLastTransactionByOutMsgHash(ctx context.Context, addr *address.Address, msgHash []byte, maxTxNumToScan ...int) (*tlb.Transaction, error)
+}
+
+// Event - nft item operation found in the item transaction
+type Event struct {
+	Type    EventType
+	QueryID uint64
+	// Sender - sender of the message, for transfer it is the owner at the moment of transfer
+	Sender *address.Address
+	// Receiver - new owner for mint and transfer, destination of the message for others
+	Receiver            *address.Address
+	ResponseDestination *address.Address
+	ForwardAmount       tlb.Coins
+	ForwardPayload      *cell.Cell
+	TON                 tlb.Coins
+	// ParseError - message has nft op, but its body is malformed, so only type and message details are known
+	ParseError error
+
+	TxHash    []byte
+	TxLT      uint64
+	TxNow     uint32
+	TxSuccess bool
+}
+
+// Sale - details of the item purchase using fixed price sale contract
+type Sale struct {
+	// Contract - sale contract which owned the item and transferred it to buyer
+	Contract *address.Address
+	Buyer    *address.Address
+	// Price - sum of payouts made by sale contract, transfer to item and return of the change to buyer are not counted
+	Price tlb.Coins
+	// Royalty - amount actually paid to the royalty address of collection
+	Royalty       tlb.Coins
+	RoyaltyParams *CollectionRoyaltyParams
+}
+
+// Ownership - period when item was owned by the address
+type Ownership struct {
+	Owner     *address.Address
+	PrevOwner *address.Address
+	QueryID   uint64
+	// Since - unix time of transaction which assigned ownership
+	Since  uint32
+	TxLT   uint64
+	TxHash []byte
+	// Sale - filled when ownership was bought, nil for mint and direct transfers
+	Sale *Sale
+}
+
+// ItemHistory - provenance of the collection item
+type ItemHistory struct {
+	Index   *big.Int
+	Address *address.Address
+	// Owner - current owner, nil when item is not yet initialized
+	Owner      *address.Address
+	Ownerships []*Ownership
+	Events     []*Event
+
+	lastLT uint64
+}
+
+type Indexer struct {
+	api        IndexerAPI
+	collection *CollectionClient
+	// DetectSales - when true, transaction of the contract which transferred item is loaded,
+	// to check if it was a purchase and fill sale details
+	DetectSales bool
+	// TxBatch - number of transactions requested at once
+	TxBatch uint32
+
+	royalty       *CollectionRoyaltyParams
+	royaltyLoaded bool
+
+	items   map[string]*ItemHistory
+	byIndex map[string]*ItemHistory
+	mx      sync.RWMutex
+	// syncMx - serializes syncs, so item histories are not updated concurrently
+	syncMx sync.Mutex
+}
+
+func NewIndexer(api IndexerAPI, collectionAddr *address.Address) *Indexer {
+	return &Indexer{
+		api:         api,
+		collection:  NewCollectionClient(api, collectionAddr),
+		DetectSales: true,
+		TxBatch:     16,
+		items:       map[string]*ItemHistory{},
+		byIndex:     map[string]*ItemHistory{},
+	}
+}
+
+// Sync - enumerates all items of collection and loads their new transactions,
+// can be called periodically, only transactions after the last processed are loaded.
+func (idx *Indexer) Sync(ctx context.Context) error {
+	data, err := idx.collection.GetCollectionData(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get collection data: %w", err)
+	}
+
+	if data.NextItemIndex.Sign() < 0 {
+		return ErrNotSequentialCollection
+	}
+
+	for i := big.NewInt(0); i.Cmp(data.NextItemIndex) < 0; i = new(big.Int).Add(i, big.NewInt(1)) {
+		if _, err = idx.SyncItem(ctx, i); err != nil {
+			return fmt.Errorf("failed to sync item %s: %w", i.String(), err)
+		}
+	}
+	return nil
+}
+
+// SyncItem - loads new transactions of the item with index and updates its history.
+// Returned histories are not modified after, next sync replaces them with the updated copy.
+func (idx *Indexer) SyncItem(ctx context.Context, index *big.Int) (*ItemHistory, error) {
+	idx.syncMx.Lock()
+	defer idx.syncMx.Unlock()
+
+	item := idx.ItemByIndex(index)
+	if item != nil {
+		item = item.clone()
+	} else {
+		addr, err := idx.collection.GetNFTAddressByIndex(ctx, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get item address: %w", err)
+		}
+
+		item = &ItemHistory{
+			Index:   new(big.Int).Set(index),
+			Address: addr,
+		}
+	}
+
+	txs, err := idx.loadTransactions(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tx := range txs {
+		events, err := DecodeItemTransaction(tx, idx.collection.addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode transaction %d: %w", tx.LT, err)
+		}
+
+		own := item.apply(events)
+		if own != nil && own.PrevOwner != nil && idx.DetectSales {
+			if own.Sale, err = idx.findSale(ctx, item, tx); err != nil {
+				return nil, fmt.Errorf("failed to check sale in transaction %d: %w", tx.LT, err)
+			}
+		}
+		item.lastLT = tx.LT
+	}
+
+	idx.mx.Lock()
+	idx.items[item.Address.String()] = item
+	idx.byIndex[item.Index.String()] = item
+	idx.mx.Unlock()
+
+	return item, nil
+}
+
+// Item - returns known history of the item by its address, or nil
+func (idx *Indexer) Item(addr *address.Address) *ItemHistory {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return idx.items[addr.String()]
+}
+
+// ItemByIndex - returns known history of the item by its index, or nil
+func (idx *Indexer) ItemByIndex(index *big.Int) *ItemHistory {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return idx.byIndex[index.String()]
+}
+
+// Items - returns histories of all known items sorted by index
+func (idx *Indexer) Items() []*ItemHistory {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+
+	list := make([]*ItemHistory, 0, len(idx.items))
+	for _, item := range idx.items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Index.Cmp(list[j].Index) < 0
+	})
+	return list
+}
+
+// OwnedBy - returns items currently owned by the address
+func (idx *Indexer) OwnedBy(owner *address.Address) []*ItemHistory {
+	var list []*ItemHistory
+	for _, item := range idx.Items() {
+		if item.Owner != nil && item.Owner.Equals(owner) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// loadTransactions - returns transactions after the last processed one, the oldest first
+func (idx *Indexer) loadTransactions(ctx context.Context, item *ItemHistory) ([]*tlb.Transaction, error) {
+	block, err := idx.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+
+	acc, err := idx.api.WaitForBlock(block.SeqNo).GetAccount(ctx, block, item.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	var txs []*tlb.Transaction
+	for lt, hash := acc.LastTxLT, acc.LastTxHash; lt > item.lastLT; {
+		list, err := idx.api.ListTransactions(ctx, item.Address, idx.TxBatch, lt, hash)
+		if err != nil {
+			if errors.Is(err, ton.ErrNoTransactionsWereFound) {
+				break
+			}
+			return nil, fmt.Errorf("failed to list transactions: %w", err)
+		}
+
+		if len(list) == 0 {
+			break
+		}
+
+		for i := len(list) - 1; i >= 0; i-- {
+			if list[i].LT <= item.lastLT {
+				break
+			}
+			txs = append(txs, list[i])
+		}
+		lt, hash = list[0].PrevTxLT, list[0].PrevTxHash
+	}
+
+	// reverse to get the oldest first
+	for i, j := 0, len(txs)-1; i < j; i, j = i+1, j-1 {
+		txs[i], txs[j] = txs[j], txs[i]
+	}
+	return txs, nil
+}
+
+func (idx *Indexer) findSale(ctx context.Context, item *ItemHistory, tx *tlb.Transaction) (*Sale, error) {
+	in := tx.IO.In.AsInternal()
+
+	saleTx, err := idx.api.FindLastTransactionByOutMsgHash(ctx, in.SrcAddr, in.Body.Hash())
+	if err != nil {
+		if errors.Is(err, ton.ErrTxWasNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !idx.royaltyLoaded {
+		// collections are not required to support royalty get method
+		idx.royalty, _ = idx.collection.RoyaltyParams(ctx)
+		idx.royaltyLoaded = true
+	}
+
+	return ParseSale(saleTx, item.Address, idx.royalty)
+}
+
+// ParseSale - checks that transaction of sale contract is a purchase of the item, and calculates price and royalty.
+// Purchase is a transaction where incoming message from buyer caused item transfer to the same buyer.
+// Returns nil if it is not a purchase.
+func ParseSale(saleTx *tlb.Transaction, item *address.Address, royalty *CollectionRoyaltyParams) (*Sale, error) {
+	if saleTx.IO.In == nil || saleTx.IO.In.MsgType != tlb.MsgTypeInternal || saleTx.IO.Out == nil {
+		return nil, nil
+	}
+	buyer := saleTx.IO.In.AsInternal().SrcAddr
+
+	outs, err := saleTx.IO.Out.ToSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outgoing messages: %w", err)
+	}
+
+	sale := &Sale{
+		Buyer:         buyer,
+		RoyaltyParams: royalty,
+	}
+
+	var transferred bool
+	price, paid := new(big.Int), new(big.Int)
+	for _, out := range outs {
+		if out.MsgType != tlb.MsgTypeInternal {
+			continue
+		}
+		msg := out.AsInternal()
+
+		if msg.DstAddr.Equals(item) {
+			var p TransferPayload
+			if msg.Body == nil || tlb.LoadFromCell(&p, msg.Body.BeginParse()) != nil || !p.NewOwner.Equals(buyer) {
+				return nil, nil
+			}
+			sale.Contract = msg.SrcAddr
+			transferred = true
+			continue
+		}
+
+		if msg.DstAddr.Equals(buyer) {
+			// change returned to buyer
+			continue
+		}
+
+		price.Add(price, msg.Amount.Nano())
+		if royalty != nil && royalty.Address != nil && msg.DstAddr.Equals(royalty.Address) {
+			paid.Add(paid, msg.Amount.Nano())
+		}
+	}
+
+	if !transferred || price.Sign() == 0 {
+		return nil, nil
+	}
+
+	sale.Price = tlb.FromNanoTON(price)
+	sale.Royalty = tlb.FromNanoTON(paid)
+	return sale, nil
+}
+
+// ExpectedRoyalty - royalty which should be paid for the price according to collection params
+func (s *Sale) ExpectedRoyalty() tlb.Coins {
+	if s.RoyaltyParams == nil || s.RoyaltyParams.Base == 0 {
+		return tlb.ZeroCoins
+	}
+
+	amt := new(big.Int).Mul(s.Price.Nano(), big.NewInt(int64(s.RoyaltyParams.Factor)))
+	return tlb.FromNanoTON(amt.Div(amt, big.NewInt(int64(s.RoyaltyParams.Base))))
+}
+
+func (h *ItemHistory) clone() *ItemHistory {
+	c := *h
+	c.Ownerships = append([]*Ownership{}, h.Ownerships...)
+	c.Events = append([]*Event{}, h.Events...)
+	return &c
+}
+
+// OwnerAt - returns owner of the item right after the transaction with lt, nil if item was not minted at that moment
+func (h *ItemHistory) OwnerAt(lt uint64) *address.Address {
+	var owner *address.Address
+	for _, o := range h.Ownerships {
+		if o.TxLT > lt {
+			break
+		}
+		owner = o.Owner
+	}
+	return owner
+}
+
+// apply - appends events of the transaction and returns new ownership if it was changed
+func (h *ItemHistory) apply(events []*Event) *Ownership {
+	h.Events = append(h.Events, events...)
+
+	for _, ev := range events {
+		if !ev.TxSuccess || ev.ParseError != nil || (ev.Type != EventMint && ev.Type != EventTransfer) {
+			continue
+		}
+
+		own := &Ownership{
+			Owner:     ev.Receiver,
+			PrevOwner: h.Owner,
+			QueryID:   ev.QueryID,
+			Since:     ev.TxNow,
+			TxLT:      ev.TxLT,
+			TxHash:    ev.TxHash,
+		}
+		h.Owner = ev.Receiver
+		h.Ownerships = append(h.Ownerships, own)
+		return own
+	}
+	return nil
+}
+
+// DecodeItemTransaction - decodes nft item events from the transaction of item,
+// incoming transfer or mint, and outgoing ownership_assigned and excesses.
+// Mint is detected only for deploy message from the collection, after which item became active.
+// Messages with malformed bodies are returned with ParseError.
+func DecodeItemTransaction(tx *tlb.Transaction, collection *address.Address) ([]*Event, error) {
+	success := tx.IsSuccess()
+
+	var events []*Event
+	add := func(ev *Event) {
+		ev.TxHash, ev.TxLT, ev.TxNow, ev.TxSuccess = tx.Hash, tx.LT, tx.Now, success
+		events = append(events, ev)
+	}
+
+	if tx.IO.In != nil && tx.IO.In.MsgType == tlb.MsgTypeInternal {
+		in := tx.IO.In.AsInternal()
+		if !in.Bounced && in.Body != nil {
+			s := in.Body.BeginParse()
+
+			if tx.OrigStatus != tlb.AccountStatusActive {
+				if in.SrcAddr.Equals(collection) && tx.EndStatus == tlb.AccountStatusActive {
+					// deploy from collection, body is owner address and content
+					owner, err := s.LoadAddr()
+					if err == nil {
+						add(&Event{
+							Type:     EventMint,
+							Sender:   in.SrcAddr,
+							Receiver: owner,
+							TON:      in.Amount,
+						})
+					}
+				}
+			} else if op, err := s.LoadUInt(32); err == nil && op == OpTransfer {
+				var p TransferPayload
+				if err = tlb.LoadFromCell(&p, in.Body.BeginParse()); err != nil {
+					add(&Event{
+						Type:       EventTransfer,
+						Sender:     in.SrcAddr,
+						TON:        in.Amount,
+						ParseError: fmt.Errorf("failed to parse transfer: %w", err),
+					})
+				} else {
+					add(&Event{
+						Type:                EventTransfer,
+						QueryID:             p.QueryID,
+						Sender:              in.SrcAddr,
+						Receiver:            p.NewOwner,
+						ResponseDestination: p.ResponseDestination,
+						ForwardAmount:       p.ForwardAmount,
+						ForwardPayload:      p.ForwardPayload,
+						TON:                 in.Amount,
+					})
+				}
+			}
+		}
+	}
+
+	if tx.IO.Out != nil {
+		outs, err := tx.IO.Out.ToSlice()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load outgoing messages: %w", err)
+		}
+
+		for _, out := range outs {
+			if out.MsgType != tlb.MsgTypeInternal {
+				continue
+			}
+
+			msg := out.AsInternal()
+			if msg.Body == nil {
+				continue
+			}
+
+			op, err := msg.Body.BeginParse().LoadUInt(32)
+			if err != nil {
+				continue
+			}
+
+			switch op {
+			case OpOwnershipAssigned:
+				var p OwnershipAssignedPayload
+				if err = tlb.LoadFromCell(&p, msg.Body.BeginParse()); err != nil {
+					add(&Event{
+						Type:       EventOwnershipAssigned,
+						Receiver:   msg.DstAddr,
+						TON:        msg.Amount,
+						ParseError: fmt.Errorf("failed to parse ownership assigned: %w", err),
+					})
+					continue
+				}
+
+				add(&Event{
+					Type:           EventOwnershipAssigned,
+					QueryID:        p.QueryID,
+					Sender:         p.PrevOwner,
+					Receiver:       msg.DstAddr,
+					ForwardPayload: p.ForwardPayload,
+					TON:            msg.Amount,
+				})
+			case OpExcesses:
+				s := msg.Body.BeginParse()
+				s.MustLoadUInt(32)
+
+				ev := &Event{
+					Type:     EventExcesses,
+					Sender:   msg.SrcAddr,
+					Receiver: msg.DstAddr,
+					TON:      msg.Amount,
+				}
+				if ev.QueryID, err = s.LoadUInt(64); err != nil {
+					ev.ParseError = fmt.Errorf("failed to parse excesses: %w", err)
+				}
+				add(ev)
+			}
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,199 @@
+package nft
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+func makeTx(lt uint64, status tlb.AccountStatus, in *tlb.InternalMessage, outs ...*tlb.InternalMessage) *tlb.Transaction {
+	tx := &tlb.Transaction{
+		LT:         lt,
+		Now:        uint32(lt),
+		OrigStatus: status,
+		EndStatus:  tlb.AccountStatusActive,
+		Hash:       big.NewInt(int64(lt)).FillBytes(make([]byte, 32)),
+	}
+	tx.Description.Description = tlb.TransactionDescriptionOrdinary{
+		ComputePhase: tlb.ComputePhase{Phase: tlb.ComputePhaseVM{Success: true}},
+	}
+
+	if in != nil {
+		tx.IO.In = &tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: in}
+	}
+
+	if len(outs) > 0 {
+		dict := cell.NewDict(15)
+		for i, out := range outs {
+			out.CreatedLT = lt + uint64(i) + 1
+			c, err := tlb.ToCell(out)
+			if err != nil {
+				panic(err)
+			}
+			if err = dict.SetIntKey(big.NewInt(int64(i)), cell.BeginCell().MustStoreRef(c).EndCell()); err != nil {
+				panic(err)
+			}
+		}
+		tx.IO.Out = &tlb.MessagesList{List: dict}
+	}
+	return tx
+}
+
+func mustCell(v any) *cell.Cell {
+	c, err := tlb.ToCell(v)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestItemHistory(t *testing.T) {
+	collection := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	item := address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju")
+	seller := address.MustParseAddr("EQAbMQzuuGiCne0R7QEj9nrXsjM7gNjeVmrlBZouyC-SCLlO")
+	saleContract := address.MustParseAddr("EQD4vUD2PYRLQd0mSwjmnnWSpeulTjZoFypJVUJAyJoUbrRu")
+	buyer := address.MustParseAddr("EQA5Fa4g4JfeQoA41N6mJx0MvH75i30dV1CXKoOijFa-XnmZ")
+	royaltyAddr := address.MustParseAddr("EQB3ncyBUTjZUA5EnFKR5_EnOMI9V1tTEAAPaiU71gc4TiUt")
+
+	mintTx := makeTx(100, tlb.AccountStatusUninit, &tlb.InternalMessage{
+		SrcAddr: collection, DstAddr: item, Amount: tlb.MustFromTON("0.05"),
+		Body: cell.BeginCell().MustStoreAddr(seller).MustStoreRef(cell.BeginCell().EndCell()).EndCell(),
+	})
+
+	toSale := &tlb.InternalMessage{
+		Bounce: true, SrcAddr: seller, DstAddr: item, Amount: tlb.MustFromTON("0.2"),
+		Body: mustCell(TransferPayload{
+			QueryID: 1, NewOwner: saleContract, ResponseDestination: seller,
+			ForwardAmount: tlb.MustFromTON("0.1"), ForwardPayload: cell.BeginCell().EndCell(),
+		}),
+	}
+	listTx := makeTx(200, tlb.AccountStatusActive, toSale,
+		&tlb.InternalMessage{
+			SrcAddr: item, DstAddr: saleContract, Amount: tlb.MustFromTON("0.1"),
+			Body: mustCell(OwnershipAssignedPayload{QueryID: 1, PrevOwner: seller, ForwardPayload: cell.BeginCell().EndCell()}),
+		},
+		&tlb.InternalMessage{
+			SrcAddr: item, DstAddr: seller, Amount: tlb.MustFromTON("0.09"),
+			Body: cell.BeginCell().MustStoreUInt(OpExcesses, 32).MustStoreUInt(1, 64).EndCell(),
+		},
+	)
+
+	toBuyer := &tlb.InternalMessage{
+		Bounce: true, SrcAddr: saleContract, DstAddr: item, Amount: tlb.MustFromTON("1"),
+		Body: mustCell(TransferPayload{
+			QueryID: 2, NewOwner: buyer, ResponseDestination: buyer,
+			ForwardAmount: tlb.ZeroCoins, ForwardPayload: cell.BeginCell().EndCell(),
+		}),
+	}
+	saleTx := makeTx(290, tlb.AccountStatusActive, &tlb.InternalMessage{
+		SrcAddr: buyer, DstAddr: saleContract, Amount: tlb.MustFromTON("12"),
+	},
+		&tlb.InternalMessage{SrcAddr: saleContract, DstAddr: seller, Amount: tlb.MustFromTON("9")},
+		&tlb.InternalMessage{SrcAddr: saleContract, DstAddr: royaltyAddr, Amount: tlb.MustFromTON("1")},
+		toBuyer,
+		&tlb.InternalMessage{SrcAddr: saleContract, DstAddr: buyer, Amount: tlb.MustFromTON("0.9")},
+	)
+	buyTx := makeTx(300, tlb.AccountStatusActive, toBuyer)
+
+	h := &ItemHistory{Index: big.NewInt(0), Address: item}
+	for _, tx := range []*tlb.Transaction{mintTx, listTx, buyTx} {
+		events, err := DecodeItemTransaction(tx, collection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.apply(events)
+	}
+
+	if len(h.Events) != 5 || len(h.Ownerships) != 3 || !h.Owner.Equals(buyer) {
+		t.Fatal("incorrect history", len(h.Events), len(h.Ownerships))
+	}
+
+	if h.Events[0].Type != EventMint || h.Events[2].Type != EventOwnershipAssigned || !h.Events[2].Sender.Equals(seller) ||
+		h.Events[3].Type != EventExcesses || h.Events[3].QueryID != 1 {
+		t.Fatal("incorrect events")
+	}
+
+	if h.OwnerAt(99) != nil || !h.OwnerAt(150).Equals(seller) || !h.OwnerAt(250).Equals(saleContract) {
+		t.Fatal("incorrect owner at")
+	}
+
+	last := h.Ownerships[2]
+	if !last.PrevOwner.Equals(saleContract) || last.QueryID != 2 || last.TxLT != 300 {
+		t.Fatal("incorrect ownership")
+	}
+
+	sale, err := ParseSale(saleTx, item, &CollectionRoyaltyParams{Factor: 10, Base: 100, Address: royaltyAddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sale == nil || !sale.Buyer.Equals(buyer) || !sale.Contract.Equals(saleContract) {
+		t.Fatal("sale not detected")
+	}
+	if sale.Price.String() != "10" || sale.Royalty.String() != "1" || sale.ExpectedRoyalty().String() != "1" {
+		t.Fatal("incorrect sale amounts", sale.Price.String(), sale.Royalty.String())
+	}
+
+	// direct transfer by owner wallet is not a sale
+	if sale, err = ParseSale(listTx, item, nil); err != nil || sale != nil {
+		t.Fatal("should not be a sale", err)
+	}
+}
+
+func TestDecodeItemTransaction_Invalid(t *testing.T) {
+	collection := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	item := address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju")
+	owner := address.MustParseAddr("EQAbMQzuuGiCne0R7QEj9nrXsjM7gNjeVmrlBZouyC-SCLlO")
+
+	deployBody := cell.BeginCell().MustStoreAddr(owner).MustStoreRef(cell.BeginCell().EndCell()).EndCell()
+
+	// deploy is sent not by collection
+	fakeMint := makeTx(100, tlb.AccountStatusUninit, &tlb.InternalMessage{
+		SrcAddr: owner, DstAddr: item, Amount: tlb.MustFromTON("0.05"), Body: deployBody,
+	})
+	events, err := DecodeItemTransaction(fakeMint, collection)
+	if err != nil || len(events) != 0 {
+		t.Fatal("mint should not be detected", err)
+	}
+
+	// item was not initialized by message
+	failedMint := makeTx(100, tlb.AccountStatusUninit, &tlb.InternalMessage{
+		SrcAddr: collection, DstAddr: item, Amount: tlb.MustFromTON("0.05"), Body: deployBody,
+	})
+	failedMint.EndStatus = tlb.AccountStatusUninit
+	if events, err = DecodeItemTransaction(failedMint, collection); err != nil || len(events) != 0 {
+		t.Fatal("mint should not be detected", err)
+	}
+
+	h := &ItemHistory{Index: big.NewInt(0), Address: item, Owner: owner}
+
+	malformed := makeTx(200, tlb.AccountStatusActive, &tlb.InternalMessage{
+		SrcAddr: owner, DstAddr: item, Amount: tlb.MustFromTON("0.05"),
+		Body: cell.BeginCell().MustStoreUInt(OpTransfer, 32).MustStoreUInt(1, 8).EndCell(),
+	})
+	if events, err = DecodeItemTransaction(malformed, collection); err != nil {
+		t.Fatal("malformed body should not fail decoding", err)
+	}
+	if len(events) != 1 || events[0].ParseError == nil {
+		t.Fatal("transfer should be marked as unparsed")
+	}
+	if h.apply(events) != nil || !h.Owner.Equals(owner) {
+		t.Fatal("unparsed transfer should not change owner")
+	}
+
+	skipped := makeTx(300, tlb.AccountStatusActive, &tlb.InternalMessage{
+		SrcAddr: owner, DstAddr: item, Amount: tlb.MustFromTON("0.05"),
+		Body: mustCell(TransferPayload{
+			QueryID: 1, NewOwner: collection, ResponseDestination: owner,
+			ForwardAmount: tlb.ZeroCoins, ForwardPayload: cell.BeginCell().EndCell(),
+		}),
+	})
+	skipped.Description.Description = tlb.TransactionDescriptionOrdinary{
+		ComputePhase: tlb.ComputePhase{Phase: tlb.ComputePhaseSkipped{}},
+	}
+	if events, err = DecodeItemTransaction(skipped, collection); err != nil || len(events) != 1 || events[0].TxSuccess {
+		t.Fatal("transaction with skipped compute should not be successful", err)
+	}
+}
@@ -0,0 +1,213 @@
+package nft
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+// ProveOwnershipPayload - asks SBT to send ownership_proof with forward payload to destination, should be sent by owner
+type ProveOwnershipPayload struct {
+	_              tlb.Magic        `tlb:"#04ded148"`
+	QueryID        uint64           `tlb:"## 64"`
+	Destination    *address.Address `tlb:"addr"`
+	ForwardPayload *cell.Cell       `tlb:"^"`
+	WithContent    bool             `tlb:"bool"`
+}
+
+// RequestOwnerPayload - asks SBT to send owner_info with forward payload to destination, can be sent by anyone
+type RequestOwnerPayload struct {
+	_              tlb.Magic        `tlb:"#d0c3bfea"`
+	QueryID        uint64           `tlb:"## 64"`
+	Destination    *address.Address `tlb:"addr"`
+	ForwardPayload *cell.Cell       `tlb:"^"`
+	WithContent    bool             `tlb:"bool"`
+}
+
+// DestroyPayload - removes owner and authority from SBT, should be sent by owner
+type DestroyPayload struct {
+	_       tlb.Magic `tlb:"#1f04537a"`
+	QueryID uint64    `tlb:"## 64"`
+}
+
+// RevokePayload - marks SBT as revoked, should be sent by authority
+type RevokePayload struct {
+	_       tlb.Magic `tlb:"#6f89f5e3"`
+	QueryID uint64    `tlb:"## 64"`
+}
+
+// OwnershipProof - sent by SBT to destination as a response to prove_ownership
+type OwnershipProof struct {
+	_         tlb.Magic        `tlb:"#0524c7ae"`
+	QueryID   uint64           `tlb:"## 64"`
+	ItemID    *big.Int         `tlb:"## 256"`
+	Owner     *address.Address `tlb:"addr"`
+	Data      *cell.Cell       `tlb:"^"`
+	RevokedAt uint64           `tlb:"## 64"`
+	Content   *cell.Cell       `tlb:"maybe ^"`
+}
+
+// OwnerInfo - sent by SBT to destination as a response to request_owner
+type OwnerInfo struct {
+	_         tlb.Magic        `tlb:"#0dd607e3"`
+	QueryID   uint64           `tlb:"## 64"`
+	ItemID    *big.Int         `tlb:"## 256"`
+	Initiator *address.Address `tlb:"addr"`
+	Owner     *address.Address `tlb:"addr"`
+	Data      *cell.Cell       `tlb:"^"`
+	RevokedAt uint64           `tlb:"## 64"`
+	Content   *cell.Cell       `tlb:"maybe ^"`
+}
+
+type SBTItemClient struct {
+	*ItemClient
+}
+
+func NewSBTItemClient(api TonApi, nftAddr *address.Address) *SBTItemClient {
+	return &SBTItemClient{
+		ItemClient: NewItemClient(api, nftAddr),
+	}
+}
+
+func (c *SBTItemClient) GetAuthorityAddress(ctx context.Context) (*address.Address, error) {
+	b, err := c.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+	return c.GetAuthorityAddressAtBlock(ctx, b)
+}
+
+func (c *SBTItemClient) GetAuthorityAddressAtBlock(ctx context.Context, b *ton.BlockIDExt) (*address.Address, error) {
+	res, err := c.api.WaitForBlock(b.SeqNo).RunGetMethod(ctx, b, c.addr, "get_authority_address")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run get_authority_address method: %w", err)
+	}
+
+	x, err := res.Slice(0)
+	if err != nil {
+		return nil, fmt.Errorf("result is not slice, err: %w", err)
+	}
+
+	addr, err := x.LoadAddr()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load address from result slice: %w", err)
+	}
+
+	return addr, nil
+}
+
+// GetRevokedTime - returns unix time when SBT was revoked, 0 if it is not revoked
+func (c *SBTItemClient) GetRevokedTime(ctx context.Context) (uint64, error) {
+	b, err := c.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+	return c.GetRevokedTimeAtBlock(ctx, b)
+}
+
+func (c *SBTItemClient) GetRevokedTimeAtBlock(ctx context.Context, b *ton.BlockIDExt) (uint64, error) {
+	res, err := c.api.WaitForBlock(b.SeqNo).RunGetMethod(ctx, b, c.addr, "get_revoked_time")
+	if err != nil {
+		return 0, fmt.Errorf("failed to run get_revoked_time method: %w", err)
+	}
+
+	tm, err := res.Int(0)
+	if err != nil {
+		return 0, fmt.Errorf("result is not int, err: %w", err)
+	}
+
+	return tm.Uint64(), nil
+}
+
+func (c *SBTItemClient) BuildProveOwnershipPayload(dest *address.Address, payloadForward *cell.Cell, withContent bool) (*cell.Cell, error) {
+	if payloadForward == nil {
+		payloadForward = cell.BeginCell().EndCell()
+	}
+
+	rnd, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(ProveOwnershipPayload{
+		QueryID:        rnd,
+		Destination:    dest,
+		ForwardPayload: payloadForward,
+		WithContent:    withContent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ProveOwnershipPayload to cell: %w", err)
+	}
+
+	return body, nil
+}
+
+func (c *SBTItemClient) BuildRequestOwnerPayload(dest *address.Address, payloadForward *cell.Cell, withContent bool) (*cell.Cell, error) {
+	if payloadForward == nil {
+		payloadForward = cell.BeginCell().EndCell()
+	}
+
+	rnd, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(RequestOwnerPayload{
+		QueryID:        rnd,
+		Destination:    dest,
+		ForwardPayload: payloadForward,
+		WithContent:    withContent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert RequestOwnerPayload to cell: %w", err)
+	}
+
+	return body, nil
+}
+
+func (c *SBTItemClient) BuildDestroyPayload() (*cell.Cell, error) {
+	rnd, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(DestroyPayload{
+		QueryID: rnd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert DestroyPayload to cell: %w", err)
+	}
+
+	return body, nil
+}
+
+func (c *SBTItemClient) BuildRevokePayload() (*cell.Cell, error) {
+	rnd, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(RevokePayload{
+		QueryID: rnd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert RevokePayload to cell: %w", err)
+	}
+
+	return body, nil
+}
+
+func randomQueryID() (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
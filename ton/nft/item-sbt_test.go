@@ -0,0 +1,78 @@
+package nft
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+func TestSBTItemClient(t *testing.T) {
+	owner := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	authority := address.MustParseAddr("EQAbMQzuuGiCne0R7QEj9nrXsjM7gNjeVmrlBZouyC-SCLlO")
+	item := address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju")
+
+	api := &getMethodMock{result: []any{addrSlice(authority)}}
+	c := NewSBTItemClient(api, item)
+
+	addr, err := c.GetAuthorityAddress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !addr.Equals(authority) {
+		t.Fatal("incorrect authority")
+	}
+
+	api.result = []any{big.NewInt(1700000000)}
+	tm, err := c.GetRevokedTime(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm != 1700000000 {
+		t.Fatal("incorrect revoked time")
+	}
+
+	body, err := c.BuildProveOwnershipPayload(owner, cell.BeginCell().MustStoreUInt(777, 32).EndCell(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prove ProveOwnershipPayload
+	if err = tlb.LoadFromCell(&prove, body.BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+	if !prove.Destination.Equals(owner) || !prove.WithContent || prove.ForwardPayload.BeginParse().MustLoadUInt(32) != 777 {
+		t.Fatal("incorrect prove ownership payload")
+	}
+
+	for op, build := range map[uint64]func() (*cell.Cell, error){
+		0x1f04537a: c.BuildDestroyPayload,
+		0x6f89f5e3: c.BuildRevokePayload,
+	} {
+		body, err = build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body.BeginParse().MustLoadUInt(32) != op {
+			t.Fatal("incorrect op", op)
+		}
+	}
+
+	body, err = NewCollectionClient(api, item).BuildMintSBTPayload(big.NewInt(3), owner, authority, tlb.MustFromTON("0.05"), &ContentOffchain{URI: "3.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mint ItemMintPayload
+	if err = tlb.LoadFromCell(&mint, body.BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+
+	s := mint.Content.BeginParse()
+	if !s.MustLoadAddr().Equals(owner) || s.MustLoadRef().MustLoadStringSnake() != "3.json" || !s.MustLoadAddr().Equals(authority) {
+		t.Fatal("incorrect sbt mint content")
+	}
+}
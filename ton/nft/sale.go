@@ -0,0 +1,553 @@
+package nft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+// Layouts and get-methods of getgems fixed price sale v3r3 and auction v3r3 contracts,
+// contract code should be provided by caller, it is taken from the marketplace which will index the sale.
+
+const (
+	_SaleMagicFixPrice = 0x46495850 // FIXP
+	_SaleMagicAuction  = 0x415543   // AUC
+
+	OpSaleAcceptCoins = 1
+	OpSaleBuy         = 2
+	OpSaleCancel      = 3
+)
+
+var ErrUnexpectedSaleType = errors.New("unexpected sale contract type")
+
+type SaleOpPayload struct {
+	Op      uint32 `tlb:"## 32"`
+	QueryID uint64 `tlb:"## 64"`
+}
+
+// FeeParams - fee in parts of the price, fee = price * Factor / Base
+type FeeParams struct {
+	Address *address.Address
+	Factor  uint32
+	Base    uint32
+}
+
+// FeeSplit - distribution of the price between participants
+type FeeSplit struct {
+	MarketplaceFee tlb.Coins
+	Royalty        tlb.Coins
+	// Seller - amount which owner receives after fees
+	Seller tlb.Coins
+}
+
+// CalcFeeSplit - calculates fees same way as sale contracts do, marketplace fee and royalty are taken from the price
+func CalcFeeSplit(price tlb.Coins, marketplace, royalty FeeParams) FeeSplit {
+	mp := calcFee(price.Nano(), marketplace)
+	roy := calcFee(price.Nano(), royalty)
+
+	seller := new(big.Int).Sub(price.Nano(), mp)
+	seller.Sub(seller, roy)
+	if seller.Sign() < 0 {
+		seller.SetInt64(0)
+	}
+
+	return FeeSplit{
+		MarketplaceFee: tlb.FromNanoTON(mp),
+		Royalty:        tlb.FromNanoTON(roy),
+		Seller:         tlb.FromNanoTON(seller),
+	}
+}
+
+// RoyaltyFee - converts collection royalty params to fee params
+func (p *CollectionRoyaltyParams) RoyaltyFee() FeeParams {
+	return FeeParams{
+		Address: p.Address,
+		Factor:  uint32(p.Factor),
+		Base:    uint32(p.Base),
+	}
+}
+
+func calcFee(price *big.Int, fee FeeParams) *big.Int {
+	if fee.Base == 0 {
+		return big.NewInt(0)
+	}
+	amt := new(big.Int).Mul(price, big.NewInt(int64(fee.Factor)))
+	return amt.Div(amt, big.NewInt(int64(fee.Base)))
+}
+
+type FixPriceSaleFees struct {
+	MarketplaceFeeAddress *address.Address `tlb:"addr"`
+	MarketplaceFee        tlb.Coins        `tlb:"."`
+	RoyaltyAddress        *address.Address `tlb:"addr"`
+	RoyaltyAmount         tlb.Coins        `tlb:"."`
+}
+
+// FixPriceSaleStorage - initial data of the fixed price sale contract
+type FixPriceSaleStorage struct {
+	IsComplete         bool             `tlb:"bool"`
+	CreatedAt          uint32           `tlb:"## 32"`
+	MarketplaceAddress *address.Address `tlb:"addr"`
+	NFTAddress         *address.Address `tlb:"addr"`
+	NFTOwnerAddress    *address.Address `tlb:"addr"`
+	FullPrice          tlb.Coins        `tlb:"."`
+	Fees               FixPriceSaleFees `tlb:"^"`
+	SoldAt             uint32           `tlb:"## 32"`
+	QueryID            uint64           `tlb:"## 64"`
+}
+
+// FixPriceSaleParams - parameters of the new listing
+type FixPriceSaleParams struct {
+	Marketplace *address.Address
+	NFT         *address.Address
+	// Owner - seller, it is set by contract when nft ownership is assigned, so can be nil
+	Owner          *address.Address
+	Price          tlb.Coins
+	MarketplaceFee FeeParams
+	Royalty        FeeParams
+	CreatedAt      uint32
+}
+
+type FixPriceSaleData struct {
+	IsComplete            bool
+	CreatedAt             uint32
+	MarketplaceAddress    *address.Address
+	NFTAddress            *address.Address
+	NFTOwnerAddress       *address.Address
+	FullPrice             tlb.Coins
+	MarketplaceFeeAddress *address.Address
+	MarketplaceFee        tlb.Coins
+	RoyaltyAddress        *address.Address
+	RoyaltyAmount         tlb.Coins
+}
+
+// Split - returns distribution of the full price
+func (d *FixPriceSaleData) Split() FeeSplit {
+	seller := new(big.Int).Sub(d.FullPrice.Nano(), d.MarketplaceFee.Nano())
+	seller.Sub(seller, d.RoyaltyAmount.Nano())
+	if seller.Sign() < 0 {
+		seller.SetInt64(0)
+	}
+
+	return FeeSplit{
+		MarketplaceFee: d.MarketplaceFee,
+		Royalty:        d.RoyaltyAmount,
+		Seller:         tlb.FromNanoTON(seller),
+	}
+}
+
+// GetFixPriceSaleStateInit - builds state init of the fixed price sale, fee amounts are calculated from the price.
+// After deploy, nft should be transferred to the address of sale (StateInit.CalcAddress) with forward amount,
+// contract will remember previous owner as the seller.
+func GetFixPriceSaleStateInit(code *cell.Cell, params FixPriceSaleParams) (*tlb.StateInit, error) {
+	split := CalcFeeSplit(params.Price, params.MarketplaceFee, params.Royalty)
+
+	owner := params.Owner
+	if owner == nil {
+		owner = address.NewAddressNone()
+	}
+
+	mpFeeAddr := params.MarketplaceFee.Address
+	if mpFeeAddr == nil {
+		mpFeeAddr = params.Marketplace
+	}
+
+	royaltyAddr := params.Royalty.Address
+	if royaltyAddr == nil {
+		royaltyAddr = address.NewAddressNone()
+	}
+
+	data, err := tlb.ToCell(FixPriceSaleStorage{
+		CreatedAt:          params.CreatedAt,
+		MarketplaceAddress: params.Marketplace,
+		NFTAddress:         params.NFT,
+		NFTOwnerAddress:    owner,
+		FullPrice:          params.Price,
+		Fees: FixPriceSaleFees{
+			MarketplaceFeeAddress: mpFeeAddr,
+			MarketplaceFee:        split.MarketplaceFee,
+			RoyaltyAddress:        royaltyAddr,
+			RoyaltyAmount:         split.Royalty,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize sale data: %w", err)
+	}
+
+	return &tlb.StateInit{
+		Code: code,
+		Data: data,
+	}, nil
+}
+
+type SaleClient struct {
+	addr *address.Address
+	api  TonApi
+}
+
+func NewSaleClient(api TonApi, saleAddr *address.Address) *SaleClient {
+	return &SaleClient{
+		addr: saleAddr,
+		api:  api,
+	}
+}
+
+func (c *SaleClient) GetSaleAddress() *address.Address {
+	return c.addr
+}
+
+func (c *SaleClient) GetSaleData(ctx context.Context) (*FixPriceSaleData, error) {
+	b, err := c.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+	return c.GetSaleDataAtBlock(ctx, b)
+}
+
+func (c *SaleClient) GetSaleDataAtBlock(ctx context.Context, b *ton.BlockIDExt) (*FixPriceSaleData, error) {
+	res, err := c.api.WaitForBlock(b.SeqNo).RunGetMethod(ctx, b, c.addr, "get_sale_data")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run get_sale_data method: %w", err)
+	}
+
+	magic, err := res.Int(0)
+	if err != nil {
+		return nil, fmt.Errorf("magic get err: %w", err)
+	}
+	if magic.Uint64() != _SaleMagicFixPrice {
+		return nil, fmt.Errorf("%w: magic %x", ErrUnexpectedSaleType, magic)
+	}
+
+	r := resultReader{res: res, i: 1}
+	data := &FixPriceSaleData{
+		IsComplete:            r.bool("is_complete"),
+		CreatedAt:             uint32(r.uint("created_at")),
+		MarketplaceAddress:    r.addr("marketplace_address"),
+		NFTAddress:            r.addr("nft_address"),
+		NFTOwnerAddress:       r.addr("nft_owner_address"),
+		FullPrice:             r.coins("full_price"),
+		MarketplaceFeeAddress: r.addr("marketplace_fee_address"),
+		MarketplaceFee:        r.coins("marketplace_fee"),
+		RoyaltyAddress:        r.addr("royalty_address"),
+		RoyaltyAmount:         r.coins("royalty_amount"),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return data, nil
+}
+
+// BuildBuyPayload - body of the purchase message, it should be sent by buyer with amount of full price + 1 TON for fees,
+// which is partially returned
+func (c *SaleClient) BuildBuyPayload() (*cell.Cell, error) {
+	return buildSaleOpPayload(OpSaleBuy)
+}
+
+// BuildCancelPayload - body of the cancel message, it should be sent by owner or marketplace, nft is returned to owner
+func (c *SaleClient) BuildCancelPayload() (*cell.Cell, error) {
+	return buildSaleOpPayload(OpSaleCancel)
+}
+
+func buildSaleOpPayload(op uint32) (*cell.Cell, error) {
+	rnd, err := randomQueryID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tlb.ToCell(SaleOpPayload{
+		Op:      op,
+		QueryID: rnd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert SaleOpPayload to cell: %w", err)
+	}
+	return body, nil
+}
+
+type AuctionFees struct {
+	MarketplaceFeeAddress *address.Address `tlb:"addr"`
+	MarketplaceFeeFactor  uint32           `tlb:"## 32"`
+	MarketplaceFeeBase    uint32           `tlb:"## 32"`
+	RoyaltyAddress        *address.Address `tlb:"addr"`
+	RoyaltyFactor         uint32           `tlb:"## 32"`
+	RoyaltyBase           uint32           `tlb:"## 32"`
+}
+
+type AuctionConstants struct {
+	MarketplaceAddress *address.Address `tlb:"addr"`
+	MinBid             tlb.Coins        `tlb:"."`
+	MaxBid             tlb.Coins        `tlb:"."`
+	MinStep            uint8            `tlb:"## 7"`
+	StepTime           uint32           `tlb:"## 17"`
+	NFTAddress         *address.Address `tlb:"addr"`
+	CreatedAt          uint32           `tlb:"## 32"`
+}
+
+// AuctionStorage - initial data of the auction contract
+type AuctionStorage struct {
+	End         bool             `tlb:"bool"`
+	Activated   bool             `tlb:"bool"`
+	IsCanceled  bool             `tlb:"bool"`
+	LastMember  *address.Address `tlb:"addr"`
+	LastBid     tlb.Coins        `tlb:"."`
+	LastBidAt   uint32           `tlb:"## 32"`
+	EndTime     uint32           `tlb:"## 32"`
+	NFTOwner    *address.Address `tlb:"addr"`
+	LastQueryID uint64           `tlb:"## 64"`
+	Fees        AuctionFees      `tlb:"^"`
+	Constants   AuctionConstants `tlb:"^"`
+}
+
+// AuctionParams - parameters of the new auction
+type AuctionParams struct {
+	Marketplace    *address.Address
+	NFT            *address.Address
+	MarketplaceFee FeeParams
+	Royalty        FeeParams
+	MinBid         tlb.Coins
+	// MaxBid - instant buy price, zero means no limit
+	MaxBid tlb.Coins
+	// MinStep - minimal increase of the bid in percents
+	MinStep uint8
+	// StepTime - auction end is extended for this number of seconds after each late bid
+	StepTime  uint32
+	EndTime   uint32
+	CreatedAt uint32
+}
+
+type AuctionData struct {
+	End                bool
+	EndTime            uint32
+	MarketplaceAddress *address.Address
+	NFTAddress         *address.Address
+	NFTOwnerAddress    *address.Address
+	LastBid            tlb.Coins
+	LastMember         *address.Address
+	MinStep            uint8
+	MarketplaceFee     FeeParams
+	Royalty            FeeParams
+	MaxBid             tlb.Coins
+	MinBid             tlb.Coins
+	CreatedAt          uint32
+	LastBidAt          uint32
+	IsCanceled         bool
+	StepTime           uint32
+	LastQueryID        uint64
+}
+
+// NextMinBid - minimal amount of the next bid
+func (d *AuctionData) NextMinBid() tlb.Coins {
+	if d.LastBid.Nano().Sign() == 0 {
+		return d.MinBid
+	}
+
+	step := new(big.Int).Mul(d.LastBid.Nano(), big.NewInt(int64(d.MinStep)))
+	step.Div(step, big.NewInt(100))
+
+	next := new(big.Int).Add(d.LastBid.Nano(), step)
+	if next.Cmp(d.MinBid.Nano()) < 0 {
+		return d.MinBid
+	}
+	return tlb.FromNanoTON(next)
+}
+
+// Split - returns distribution of the last bid
+func (d *AuctionData) Split() FeeSplit {
+	return CalcFeeSplit(d.LastBid, d.MarketplaceFee, d.Royalty)
+}
+
+// GetAuctionStateInit - builds state init of the auction, after deploy nft should be transferred to its address
+func GetAuctionStateInit(code *cell.Cell, params AuctionParams) (*tlb.StateInit, error) {
+	mpFeeAddr := params.MarketplaceFee.Address
+	if mpFeeAddr == nil {
+		mpFeeAddr = params.Marketplace
+	}
+
+	royaltyAddr := params.Royalty.Address
+	if royaltyAddr == nil {
+		royaltyAddr = address.NewAddressNone()
+	}
+
+	maxBid := params.MaxBid
+	if maxBid.Nano().Sign() == 0 {
+		maxBid = tlb.ZeroCoins
+	}
+
+	data, err := tlb.ToCell(AuctionStorage{
+		LastMember: address.NewAddressNone(),
+		LastBid:    tlb.ZeroCoins,
+		EndTime:    params.EndTime,
+		NFTOwner:   address.NewAddressNone(),
+		Fees: AuctionFees{
+			MarketplaceFeeAddress: mpFeeAddr,
+			MarketplaceFeeFactor:  params.MarketplaceFee.Factor,
+			MarketplaceFeeBase:    params.MarketplaceFee.Base,
+			RoyaltyAddress:        royaltyAddr,
+			RoyaltyFactor:         params.Royalty.Factor,
+			RoyaltyBase:           params.Royalty.Base,
+		},
+		Constants: AuctionConstants{
+			MarketplaceAddress: params.Marketplace,
+			MinBid:             params.MinBid,
+			MaxBid:             maxBid,
+			MinStep:            params.MinStep,
+			StepTime:           params.StepTime,
+			NFTAddress:         params.NFT,
+			CreatedAt:          params.CreatedAt,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize auction data: %w", err)
+	}
+
+	return &tlb.StateInit{
+		Code: code,
+		Data: data,
+	}, nil
+}
+
+type AuctionClient struct {
+	addr *address.Address
+	api  TonApi
+}
+
+func NewAuctionClient(api TonApi, auctionAddr *address.Address) *AuctionClient {
+	return &AuctionClient{
+		addr: auctionAddr,
+		api:  api,
+	}
+}
+
+func (c *AuctionClient) GetAuctionAddress() *address.Address {
+	return c.addr
+}
+
+func (c *AuctionClient) GetAuctionData(ctx context.Context) (*AuctionData, error) {
+	b, err := c.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+	return c.GetAuctionDataAtBlock(ctx, b)
+}
+
+func (c *AuctionClient) GetAuctionDataAtBlock(ctx context.Context, b *ton.BlockIDExt) (*AuctionData, error) {
+	res, err := c.api.WaitForBlock(b.SeqNo).RunGetMethod(ctx, b, c.addr, "get_sale_data")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run get_sale_data method: %w", err)
+	}
+
+	magic, err := res.Int(0)
+	if err != nil {
+		return nil, fmt.Errorf("magic get err: %w", err)
+	}
+	if magic.Uint64() != _SaleMagicAuction {
+		return nil, fmt.Errorf("%w: magic %x", ErrUnexpectedSaleType, magic)
+	}
+
+	r := resultReader{res: res, i: 1}
+	data := &AuctionData{}
+	data.End = r.bool("end")
+	data.EndTime = uint32(r.uint("end_time"))
+	data.MarketplaceAddress = r.addr("mp_addr")
+	data.NFTAddress = r.addr("nft_addr")
+	data.NFTOwnerAddress = r.addr("nft_owner")
+	data.LastBid = r.coins("last_bid")
+	data.LastMember = r.addr("last_member")
+	data.MinStep = uint8(r.uint("min_step"))
+	data.MarketplaceFee.Address = r.addr("mp_fee_addr")
+	data.MarketplaceFee.Factor = uint32(r.uint("mp_fee_factor"))
+	data.MarketplaceFee.Base = uint32(r.uint("mp_fee_base"))
+	data.Royalty.Address = r.addr("royalty_fee_addr")
+	data.Royalty.Factor = uint32(r.uint("royalty_fee_factor"))
+	data.Royalty.Base = uint32(r.uint("royalty_fee_base"))
+	data.MaxBid = r.coins("max_bid")
+	data.MinBid = r.coins("min_bid")
+	data.CreatedAt = uint32(r.uint("created_at"))
+	data.LastBidAt = uint32(r.uint("last_bid_at"))
+	data.IsCanceled = r.bool("is_canceled")
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// fields added in later versions
+	if stepTime, err := res.Int(20); err == nil {
+		data.StepTime = uint32(stepTime.Uint64())
+	}
+	if queryID, err := res.Int(21); err == nil {
+		data.LastQueryID = queryID.Uint64()
+	}
+	return data, nil
+}
+
+// BuildBidPayload - bid is a simple transfer of the bid amount to the auction, body is empty
+func (c *AuctionClient) BuildBidPayload() *cell.Cell {
+	return cell.BeginCell().EndCell()
+}
+
+// BuildCancelPayload - cancels auction without bids, should be sent by owner or marketplace
+func (c *AuctionClient) BuildCancelPayload() *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake("cancel").EndCell()
+}
+
+// BuildStopPayload - finishes auction with the current last bid, should be sent by owner or marketplace
+func (c *AuctionClient) BuildStopPayload() *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake("stop").EndCell()
+}
+
+// resultReader - reads get-method result sequentially, remembering the first error
+type resultReader struct {
+	res *ton.ExecutionResult
+	i   uint
+	err error
+}
+
+func (r *resultReader) int(name string) *big.Int {
+	if r.err != nil {
+		return big.NewInt(0)
+	}
+
+	v, err := r.res.Int(r.i)
+	r.i++
+	if err != nil {
+		r.err = fmt.Errorf("%s get err: %w", name, err)
+		return big.NewInt(0)
+	}
+	return v
+}
+
+func (r *resultReader) uint(name string) uint64 {
+	return r.int(name).Uint64()
+}
+
+func (r *resultReader) bool(name string) bool {
+	return r.int(name).Sign() != 0
+}
+
+func (r *resultReader) coins(name string) tlb.Coins {
+	return tlb.FromNanoTON(r.int(name))
+}
+
+func (r *resultReader) addr(name string) *address.Address {
+	if r.err != nil {
+		return nil
+	}
+
+	s, err := r.res.Slice(r.i)
+	r.i++
+	if err != nil {
+		r.err = fmt.Errorf("%s get err: %w", name, err)
+		return nil
+	}
+
+	addr, err := s.LoadAddr()
+	if err != nil {
+		r.err = fmt.Errorf("failed to load %s from result slice: %w", name, err)
+		return nil
+	}
+	return addr
+}
@@ -0,0 +1,142 @@
+package nft
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+type getMethodMock struct {
+	ton.APIClientWrapped
+	result []any
+}
+
+func (m *getMethodMock) CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error) {
+	return &ton.BlockIDExt{}, nil
+}
+
+func (m *getMethodMock) WaitForBlock(seqno uint32) ton.APIClientWrapped {
+	return m
+}
+
+func (m *getMethodMock) RunGetMethod(ctx context.Context, blockInfo *ton.BlockIDExt, addr *address.Address, method string, params ...any) (*ton.ExecutionResult, error) {
+	return ton.NewExecutionResult(m.result), nil
+}
+
+func addrSlice(a *address.Address) *cell.Slice {
+	return cell.BeginCell().MustStoreAddr(a).EndCell().BeginParse()
+}
+
+func TestCalcFeeSplit(t *testing.T) {
+	split := CalcFeeSplit(tlb.MustFromTON("10"), FeeParams{Factor: 5, Base: 100}, FeeParams{Factor: 1, Base: 10})
+	if split.MarketplaceFee.String() != "0.5" || split.Royalty.String() != "1" || split.Seller.String() != "8.5" {
+		t.Fatal("incorrect split", split.MarketplaceFee.String(), split.Royalty.String(), split.Seller.String())
+	}
+
+	split = CalcFeeSplit(tlb.MustFromTON("1"), FeeParams{}, FeeParams{Factor: 3, Base: 2})
+	if split.Seller.String() != "0" {
+		t.Fatal("seller amount should not be negative", split.Seller.String())
+	}
+}
+
+func TestGetFixPriceSaleStateInit(t *testing.T) {
+	mp := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	item := address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju")
+	royalty := address.MustParseAddr("EQAbMQzuuGiCne0R7QEj9nrXsjM7gNjeVmrlBZouyC-SCLlO")
+
+	si, err := GetFixPriceSaleStateInit(cell.BeginCell().EndCell(), FixPriceSaleParams{
+		Marketplace:    mp,
+		NFT:            item,
+		Price:          tlb.MustFromTON("20"),
+		MarketplaceFee: FeeParams{Factor: 5, Base: 100},
+		Royalty:        (&CollectionRoyaltyParams{Factor: 10, Base: 100, Address: royalty}).RoyaltyFee(),
+		CreatedAt:      1700000000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data FixPriceSaleStorage
+	if err = tlb.LoadFromCell(&data, si.Data.BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+
+	if data.FullPrice.String() != "20" || data.Fees.MarketplaceFee.String() != "1" || data.Fees.RoyaltyAmount.String() != "2" ||
+		!data.Fees.MarketplaceFeeAddress.Equals(mp) || !data.Fees.RoyaltyAddress.Equals(royalty) ||
+		data.NFTOwnerAddress.Type() != address.NoneAddress || data.CreatedAt != 1700000000 {
+		t.Fatal("incorrect sale storage")
+	}
+
+	api := &getMethodMock{result: []any{
+		big.NewInt(_SaleMagicFixPrice), big.NewInt(0), big.NewInt(1700000000),
+		addrSlice(mp), addrSlice(item), addrSlice(royalty),
+		big.NewInt(20_000_000_000), addrSlice(mp), big.NewInt(1_000_000_000), addrSlice(royalty), big.NewInt(2_000_000_000),
+	}}
+
+	sale, err := NewSaleClient(api, si.CalcAddress(0)).GetSaleData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sale.IsComplete || !sale.NFTOwnerAddress.Equals(royalty) || sale.Split().Seller.String() != "17" {
+		t.Fatal("incorrect sale data")
+	}
+
+	if _, err = NewAuctionClient(api, si.CalcAddress(0)).GetAuctionData(context.Background()); err == nil {
+		t.Fatal("should fail on fix price sale")
+	}
+
+	body, err := NewSaleClient(api, si.CalcAddress(0)).BuildCancelPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.BeginParse().MustLoadUInt(32) != OpSaleCancel {
+		t.Fatal("incorrect cancel op")
+	}
+}
+
+func TestAuctionData(t *testing.T) {
+	mp := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	item := address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju")
+
+	si, err := GetAuctionStateInit(cell.BeginCell().EndCell(), AuctionParams{
+		Marketplace:    mp,
+		NFT:            item,
+		MarketplaceFee: FeeParams{Factor: 5, Base: 100},
+		MinBid:         tlb.MustFromTON("1"),
+		MinStep:        5,
+		StepTime:       300,
+		EndTime:        1700086400,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var storage AuctionStorage
+	if err = tlb.LoadFromCell(&storage, si.Data.BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+	if storage.Constants.MinBid.String() != "1" || storage.Constants.MinStep != 5 || storage.Constants.StepTime != 300 ||
+		storage.Constants.MaxBid.String() != "0" || !storage.Fees.MarketplaceFeeAddress.Equals(mp) || storage.EndTime != 1700086400 {
+		t.Fatal("incorrect auction storage")
+	}
+
+	data := &AuctionData{
+		LastBid:        tlb.MustFromTON("10"),
+		MinBid:         tlb.MustFromTON("1"),
+		MinStep:        5,
+		MarketplaceFee: FeeParams{Factor: 5, Base: 100},
+	}
+	if data.NextMinBid().String() != "10.5" || data.Split().Seller.String() != "9.5" {
+		t.Fatal("incorrect next bid", data.NextMinBid().String())
+	}
+
+	data.LastBid = tlb.ZeroCoins
+	if data.NextMinBid().String() != "1" {
+		t.Fatal("incorrect first bid", data.NextMinBid().String())
+	}
+}
@@ -166,13 +166,16 @@ func (c *AsyncChannel) Address() *address.Address {
 // we do this because we cannot prove method execution for now,
 // but can proof contract data and code, so this approach is safe
 func (s *AsyncChannelStorageData) calcState() ChannelStatus {
+	return s.calcStateAt(time.Now().Unix())
+}
+
+func (s *AsyncChannelStorageData) calcStateAt(now int64) ChannelStatus {
 	if !s.Initialized {
 		return ChannelStatusUninitialized
 	}
 	if s.Quarantine == nil {
 		return ChannelStatusOpen
 	}
-	now := time.Now().Unix()
 	quarantineEnds := int64(s.Quarantine.QuarantineStarts) + int64(s.ClosingConfig.QuarantineDuration)
 	if quarantineEnds > now {
 		return ChannelStatusClosureStarted
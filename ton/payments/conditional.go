@@ -0,0 +1,390 @@
+package payments
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+// Condition is a code which is executed by the channel contract during settlement,
+// input slice from SettleConditionals is passed on the stack, and if condition returns true,
+// amount of the conditional payment is added to the sent amount of its owner.
+
+// TVM opcodes used in condition templates
+const (
+	_OpDrop    = 0x30
+	_OpAnd     = 0xB0
+	_OpLess    = 0xB9
+	_OpEqual   = 0xBA
+	_OpGeq     = 0xBE
+	_OpPushInt = 0x82
+	_OpNow     = 0xF823
+	_OpSHA256U = 0xF902
+)
+
+var (
+	ErrUnknownCondition    = errors.New("unknown condition")
+	ErrConditionalExists   = errors.New("conditional with this key already exists")
+	ErrConditionalNotFound = errors.New("conditional is not found")
+)
+
+// HTLC - parsed hashlock condition, Deadline is 0 when there is no time limit
+type HTLC struct {
+	Hash     []byte
+	Deadline uint32
+}
+
+// HashlockCondition - condition is true when input is the preimage of sha256 hash
+func HashlockCondition(hash []byte) (*cell.Cell, error) {
+	b, err := hashlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	return b.EndCell(), nil
+}
+
+// HTLCCondition - condition is true when input is the preimage of sha256 hash,
+// and it is settled before the deadline (unix time).
+func HTLCCondition(hash []byte, deadline uint32) (*cell.Cell, error) {
+	b, err := hashlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	b.MustStoreUInt(_OpNow, 16)
+	storePushInt(b, new(big.Int).SetUint64(uint64(deadline)), 2)
+	b.MustStoreUInt(_OpLess, 8)
+	b.MustStoreUInt(_OpAnd, 8)
+	return b.EndCell(), nil
+}
+
+// TimelockCondition - condition is true when it is settled after the specified unix time, input is ignored
+func TimelockCondition(after uint32) *cell.Cell {
+	b := cell.BeginCell().MustStoreUInt(_OpDrop, 8).MustStoreUInt(_OpNow, 16)
+	storePushInt(b, new(big.Int).SetUint64(uint64(after)), 2)
+	b.MustStoreUInt(_OpGeq, 8)
+	return b.EndCell()
+}
+
+// HashlockInput - settlement input for hashlock and HTLC conditions
+func HashlockInput(preimage []byte) *cell.Cell {
+	return cell.BeginCell().MustStoreSlice(preimage, uint(len(preimage))*8).EndCell()
+}
+
+// ParseHTLCCondition - parses condition created by HashlockCondition or HTLCCondition,
+// it is used to check conditionals of counterparty before accepting them.
+func ParseHTLCCondition(condition *cell.Cell) (*HTLC, error) {
+	s := condition.BeginParse()
+	if s.RefsNum() != 0 {
+		return nil, ErrUnknownCondition
+	}
+
+	if op, err := s.LoadUInt(16); err != nil || op != _OpSHA256U {
+		return nil, ErrUnknownCondition
+	}
+
+	hash, err := loadPushInt(s, 30)
+	if err != nil || hash.Sign() < 0 || hash.BitLen() > 256 {
+		return nil, ErrUnknownCondition
+	}
+
+	if op, err := s.LoadUInt(8); err != nil || op != _OpEqual {
+		return nil, ErrUnknownCondition
+	}
+
+	res := &HTLC{
+		Hash: hash.FillBytes(make([]byte, 32)),
+	}
+
+	if s.BitsLeft() == 0 {
+		return res, nil
+	}
+
+	if op, err := s.LoadUInt(16); err != nil || op != _OpNow {
+		return nil, ErrUnknownCondition
+	}
+
+	deadline, err := loadPushInt(s, 2)
+	if err != nil || deadline.Sign() < 0 || deadline.BitLen() > 32 {
+		return nil, ErrUnknownCondition
+	}
+
+	if op, err := s.LoadUInt(16); err != nil || op != _OpLess<<8|_OpAnd {
+		return nil, ErrUnknownCondition
+	}
+
+	if s.BitsLeft() != 0 {
+		return nil, ErrUnknownCondition
+	}
+
+	res.Deadline = uint32(deadline.Uint64())
+	return res, nil
+}
+
+func hashlock(hash []byte) (*cell.Builder, error) {
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("hash should be 32 bytes")
+	}
+
+	b := cell.BeginCell().MustStoreUInt(_OpSHA256U, 16)
+	storePushInt(b, new(big.Int).SetBytes(hash), 30)
+	b.MustStoreUInt(_OpEqual, 8)
+	return b, nil
+}
+
+// storePushInt - PUSHINT with long form, l determines length of the int as 8*l+19 bits,
+// only non-negative values are used, so bits above 257 are always zero.
+func storePushInt(b *cell.Builder, val *big.Int, l uint) {
+	sz := 8*l + 19
+	b.MustStoreUInt(_OpPushInt, 8).MustStoreUInt(uint64(l), 5)
+	if sz > 257 {
+		b.MustStoreUInt(0, sz-257)
+		sz = 257
+	}
+	b.MustStoreBigInt(val, sz)
+}
+
+func loadPushInt(s *cell.Slice, l uint) (*big.Int, error) {
+	op, err := s.LoadUInt(8)
+	if err != nil {
+		return nil, err
+	}
+
+	sz, err := s.LoadUInt(5)
+	if err != nil {
+		return nil, err
+	}
+
+	if op != _OpPushInt || sz != uint64(l) {
+		return nil, ErrUnknownCondition
+	}
+
+	n := 8*l + 19
+	if n > 257 {
+		high, err := s.LoadUInt(n - 257)
+		if err != nil {
+			return nil, err
+		}
+		if high != 0 {
+			return nil, ErrUnknownCondition
+		}
+		n = 257
+	}
+	return s.LoadBigInt(n)
+}
+
+// AddConditional - locks amount of our balance under the condition, key should be unique.
+// Returned state should be delivered to counterparty.
+func (s *ChannelSession) AddConditional(key uint32, payment ConditionalPayment) (*SignedSemiChannel, error) {
+	if payment.Condition == nil {
+		return nil, fmt.Errorf("condition is nil")
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	conds := copyConditionals(s.our.State.Data.Conditionals)
+	if _, err := conds.LoadValue(conditionalKey(key)); err == nil {
+		return nil, ErrConditionalExists
+	}
+
+	val, err := tlb.ToCell(payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize conditional: %w", err)
+	}
+
+	if err = conds.Set(conditionalKey(key), val); err != nil {
+		return nil, fmt.Errorf("failed to set conditional: %w", err)
+	}
+
+	body := s.our.State.Data
+	body.Seqno++
+	body.Conditionals = conds
+
+	return s.updateOurState(body)
+}
+
+// ResolveConditional - removes conditional from our state and adds its amount to sent,
+// it should be done when condition is met off-chain, for example when counterparty revealed preimage.
+func (s *ChannelSession) ResolveConditional(key uint32) (*SignedSemiChannel, error) {
+	return s.removeConditional(key, true)
+}
+
+// CancelConditional - removes conditional from our state without payment, for example when it is expired.
+func (s *ChannelSession) CancelConditional(key uint32) (*SignedSemiChannel, error) {
+	return s.removeConditional(key, false)
+}
+
+func (s *ChannelSession) removeConditional(key uint32, pay bool) (*SignedSemiChannel, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	conds := copyConditionals(s.our.State.Data.Conditionals)
+	val, err := conds.LoadValue(conditionalKey(key))
+	if err != nil {
+		return nil, ErrConditionalNotFound
+	}
+
+	var payment ConditionalPayment
+	if err = tlb.LoadFromCell(&payment, val); err != nil {
+		return nil, fmt.Errorf("failed to parse conditional: %w", err)
+	}
+
+	if err = conds.Delete(conditionalKey(key)); err != nil {
+		return nil, fmt.Errorf("failed to delete conditional: %w", err)
+	}
+
+	body := s.our.State.Data
+	body.Seqno++
+	body.Conditionals = conds
+	if pay {
+		body.Sent = tlb.FromNanoTON(new(big.Int).Add(body.Sent.Nano(), payment.Amount.Nano()))
+	}
+
+	return s.updateOurState(body)
+}
+
+// TheirConditional - returns conditional payment of counterparty by key
+func (s *ChannelSession) TheirConditional(key uint32) (*ConditionalPayment, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if s.their == nil || s.their.State.Data.Conditionals.IsEmpty() {
+		return nil, ErrConditionalNotFound
+	}
+
+	val, err := s.their.State.Data.Conditionals.LoadValue(conditionalKey(key))
+	if err != nil {
+		return nil, ErrConditionalNotFound
+	}
+
+	var payment ConditionalPayment
+	if err = tlb.LoadFromCell(&payment, val); err != nil {
+		return nil, fmt.Errorf("failed to parse conditional: %w", err)
+	}
+	return &payment, nil
+}
+
+// AddPreimage - remembers preimage, it will be used to settle hashlock conditionals of counterparty
+func (s *ChannelSession) AddPreimage(preimage []byte) {
+	hash := sha256.Sum256(preimage)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.preimages == nil {
+		s.preimages = map[string][]byte{}
+	}
+	s.preimages[string(hash[:])] = append([]byte{}, preimage...)
+}
+
+// SettleConditionals - creates message which settles conditionals of counterparty with the given inputs,
+// it can be sent during conditional-close window, counterparty's signed state is used as a proof.
+func (s *ChannelSession) SettleConditionals(inputs map[uint32]*cell.Cell) (*SettleConditionals, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.buildSettle(inputs)
+}
+
+// SettleKnownConditionals - settles all hashlock conditionals of counterparty which preimages we know,
+// and which deadline is not passed at the moment now. Nil is returned when there is nothing to settle.
+func (s *ChannelSession) SettleKnownConditionals(now int64) (*SettleConditionals, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if s.their == nil || s.their.State.Data.Conditionals.IsEmpty() {
+		return nil, nil
+	}
+
+	list, err := s.their.State.Data.Conditionals.LoadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conditionals: %w", err)
+	}
+
+	inputs := map[uint32]*cell.Cell{}
+	for _, kv := range list {
+		var payment ConditionalPayment
+		if err = tlb.LoadFromCell(&payment, kv.Value); err != nil {
+			return nil, fmt.Errorf("failed to parse conditional: %w", err)
+		}
+
+		htlc, err := ParseHTLCCondition(payment.Condition)
+		if err != nil {
+			// not our template, we cannot settle it automatically
+			continue
+		}
+
+		if htlc.Deadline != 0 && int64(htlc.Deadline) <= now {
+			continue
+		}
+
+		preimage, ok := s.preimages[string(htlc.Hash)]
+		if !ok {
+			continue
+		}
+
+		key, err := kv.Key.LoadUInt(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load conditional key: %w", err)
+		}
+		inputs[uint32(key)] = HashlockInput(preimage)
+	}
+
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	return s.buildSettle(inputs)
+}
+
+func (s *ChannelSession) buildSettle(inputs map[uint32]*cell.Cell) (*SettleConditionals, error) {
+	if s.their == nil {
+		return nil, ErrCounterpartyNoState
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("nothing to settle")
+	}
+
+	toSettle := cell.NewDict(32)
+	for key, input := range inputs {
+		if s.their.State.Data.Conditionals == nil {
+			return nil, fmt.Errorf("%w: %d", ErrConditionalNotFound, key)
+		}
+		if _, err := s.their.State.Data.Conditionals.LoadValue(conditionalKey(key)); err != nil {
+			return nil, fmt.Errorf("%w: %d", ErrConditionalNotFound, key)
+		}
+
+		if err := toSettle.Set(conditionalKey(key), input); err != nil {
+			return nil, fmt.Errorf("failed to set input: %w", err)
+		}
+	}
+
+	msg := &SettleConditionals{}
+	msg.IsFromA = s.isA
+	msg.Signed.ChannelID = s.channelID
+	msg.Signed.ConditionalsToSettle = toSettle
+	msg.Signed.B = *s.their
+
+	var err error
+	msg.Signature, err = toSignature(msg.Signed, s.ourKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign settle: %w", err)
+	}
+	return msg, nil
+}
+
+func conditionalKey(key uint32) *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(uint64(key), 32).EndCell()
+}
+
+func copyConditionals(conds *cell.Dictionary) *cell.Dictionary {
+	if conds == nil {
+		return cell.NewDict(32)
+	}
+	return conds.Copy()
+}
@@ -0,0 +1,143 @@
+package payments
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/tlb"
+)
+
+func TestParseHTLCCondition(t *testing.T) {
+	hash := sha256.Sum256([]byte("secret"))
+
+	cond, err := HTLCCondition(hash[:], 1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	htlc, err := ParseHTLCCondition(cond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(htlc.Hash, hash[:]) || htlc.Deadline != 1700000000 {
+		t.Fatal("incorrect htlc", htlc)
+	}
+
+	cond, err = HashlockCondition(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	htlc, err = ParseHTLCCondition(cond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(htlc.Hash, hash[:]) || htlc.Deadline != 0 {
+		t.Fatal("incorrect hashlock", htlc)
+	}
+
+	if _, err = ParseHTLCCondition(TimelockCondition(1700000000)); !errors.Is(err, ErrUnknownCondition) {
+		t.Fatal("timelock should not be parsed as htlc, but:", err)
+	}
+}
+
+func TestChannelSession_Conditionals(t *testing.T) {
+	a, b := newTestSessions(t)
+
+	preimage := []byte("some secret preimage")
+	hash := sha256.Sum256(preimage)
+
+	cond, err := HTLCCondition(hash[:], 1700000100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := a.AddConditional(7, ConditionalPayment{
+		Amount:    tlb.MustFromTON("0.2"),
+		Condition: cond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = a.AddConditional(7, ConditionalPayment{Amount: tlb.MustFromTON("0.1"), Condition: cond}); !errors.Is(err, ErrConditionalExists) {
+		t.Fatal("duplicate key should be rejected, but:", err)
+	}
+
+	if avail, _ := a.Available(); avail.String() != "0.8" {
+		t.Fatal("amount should be locked", avail.String())
+	}
+
+	amt, err := b.ReceiveState(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amt.Nano().Sign() != 0 {
+		t.Fatal("conditional is not a payment yet")
+	}
+
+	payment, err := b.TheirConditional(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if htlc, err := ParseHTLCCondition(payment.Condition); err != nil || !bytes.Equal(htlc.Hash, hash[:]) {
+		t.Fatal("incorrect conditional", err)
+	}
+
+	msg, err := b.SettleKnownConditionals(1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != nil {
+		t.Fatal("preimage is unknown, nothing should be settled")
+	}
+
+	b.AddPreimage(preimage)
+
+	if msg, err = b.SettleKnownConditionals(1700000100); err != nil || msg != nil {
+		t.Fatal("expired conditional should not be settled", err)
+	}
+
+	msg, err = b.SettleKnownConditionals(1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil || msg.IsFromA || msg.Signed.B.State.Data.Seqno != st.State.Data.Seqno {
+		t.Fatal("incorrect settle message")
+	}
+
+	input, err := msg.Signed.ConditionalsToSettle.LoadValue(conditionalKey(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(input.MustLoadSlice(uint(len(preimage))*8), preimage) {
+		t.Fatal("incorrect settle input")
+	}
+
+	signed, _ := tlb.ToCell(msg.Signed)
+	if !signed.Verify(b.ourKey.Public().(ed25519.PublicKey), msg.Signature.Value) {
+		t.Fatal("incorrect settle signature")
+	}
+
+	// preimage was revealed off-chain, so a resolves conditional
+	if st, err = a.ResolveConditional(7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.ResolveConditional(7); !errors.Is(err, ErrConditionalNotFound) {
+		t.Fatal("should be not found, but:", err)
+	}
+
+	if amt, err = b.ReceiveState(st); err != nil {
+		t.Fatal(err)
+	}
+	if amt.String() != "0.2" {
+		t.Fatal("incorrect received amount", amt.String())
+	}
+
+	if _, err = a.PrepareCooperativeClose(); err != nil {
+		t.Fatal("conditionals should be empty", err)
+	}
+}
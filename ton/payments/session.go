@@ -0,0 +1,566 @@
+package payments
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var (
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrChannelIDMismatch    = errors.New("channel id mismatch")
+	ErrOutdatedState        = errors.New("state is outdated")
+	ErrNotEnoughBalance     = errors.New("not enough balance")
+	ErrCounterpartyNoState  = errors.New("counterparty state is not received yet")
+	ErrConditionalsNotEmpty = errors.New("conditionals are not empty")
+)
+
+// ChannelSession - off-chain state of the channel from the side of one party.
+// It keeps our last signed semi-channel and the last semi-channel signed by counterparty,
+// both of them are required to close the channel, so they should be persisted after each change.
+type ChannelSession struct {
+	channelID ChannelID
+	isA       bool
+	ourKey    ed25519.PrivateKey
+	theirKey  ed25519.PublicKey
+
+	balanceA tlb.Coins
+	balanceB tlb.Coins
+
+	our   *SignedSemiChannel
+	their *SignedSemiChannel
+
+	// preimages of hashlocks, by sha256 hash
+	preimages map[string][]byte
+
+	mx sync.RWMutex
+}
+
+// NewChannelSession - creates session with initial (seqno 0) state signed by our key,
+// balances are on-chain deposits of the parties.
+func NewChannelSession(channelID ChannelID, isA bool, ourKey ed25519.PrivateKey, theirKey ed25519.PublicKey, balanceA, balanceB tlb.Coins) (*ChannelSession, error) {
+	if len(channelID) != 16 {
+		return nil, fmt.Errorf("channelId len should be 16 bytes")
+	}
+	if len(theirKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("incorrect counterparty key")
+	}
+
+	s := &ChannelSession{
+		channelID: channelID,
+		isA:       isA,
+		ourKey:    ourKey,
+		theirKey:  theirKey,
+		balanceA:  balanceA,
+		balanceB:  balanceB,
+	}
+
+	our, err := s.signState(SemiChannel{
+		ChannelID: channelID,
+		Data:      SemiChannelBody{Sent: tlb.ZeroCoins},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.our = our
+
+	return s, nil
+}
+
+// NewSession - creates off-chain session for the deployed channel,
+// side is detected by our key, balances are taken from the contract storage.
+func (c *AsyncChannel) NewSession(ourKey ed25519.PrivateKey) (*ChannelSession, error) {
+	pub := ourKey.Public().(ed25519.PublicKey)
+
+	switch {
+	case bytes.Equal(pub, c.Storage.KeyA):
+		return NewChannelSession(c.Storage.ChannelID, true, ourKey, c.Storage.KeyB, c.Storage.BalanceA, c.Storage.BalanceB)
+	case bytes.Equal(pub, c.Storage.KeyB):
+		return NewChannelSession(c.Storage.ChannelID, false, ourKey, c.Storage.KeyA, c.Storage.BalanceA, c.Storage.BalanceB)
+	}
+	return nil, fmt.Errorf("key is not a party of the channel")
+}
+
+func (s *ChannelSession) ChannelID() ChannelID {
+	return s.channelID
+}
+
+func (s *ChannelSession) IsA() bool {
+	return s.isA
+}
+
+// SetBalances - updates on-chain deposits of the parties, should be called after topup.
+func (s *ChannelSession) SetBalances(balanceA, balanceB tlb.Coins) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.balanceA, s.balanceB = balanceA, balanceB
+}
+
+// OurState - our last signed state, it should be sent to counterparty
+func (s *ChannelSession) OurState() *SignedSemiChannel {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.our
+}
+
+// TheirState - last counterparty's state which we accepted, nil if nothing received yet
+func (s *ChannelSession) TheirState() *SignedSemiChannel {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.their
+}
+
+// Restore - sets previously persisted states, their signatures are verified.
+// their can be nil if counterparty state was not received yet.
+func (s *ChannelSession) Restore(our, their *SignedSemiChannel) error {
+	if our == nil {
+		return fmt.Errorf("our state is required")
+	}
+
+	if err := s.verifyState(our, s.ourKey.Public().(ed25519.PublicKey)); err != nil {
+		return fmt.Errorf("invalid our state: %w", err)
+	}
+
+	if their != nil {
+		if err := s.verifyState(their, s.theirKey); err != nil {
+			return fmt.Errorf("invalid counterparty state: %w", err)
+		}
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.our, s.their = our, their
+	return nil
+}
+
+// Balances - current off-chain balances of A and B, locked conditionals are not included.
+func (s *ChannelSession) Balances() (balanceA, balanceB tlb.Coins) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	a, b := s.balances()
+	return tlb.FromNanoTON(a), tlb.FromNanoTON(b)
+}
+
+// Available - amount which we can send to counterparty
+func (s *ChannelSession) Available() (tlb.Coins, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	a, b := s.balances()
+
+	our := a
+	if !s.isA {
+		our = b
+	}
+
+	locked, err := conditionalsSum(s.our.State.Data.Conditionals)
+	if err != nil {
+		return tlb.Coins{}, err
+	}
+	our.Sub(our, locked)
+
+	if our.Sign() < 0 {
+		return tlb.ZeroCoins, nil
+	}
+	return tlb.FromNanoTON(our), nil
+}
+
+// Pay - increases our sent amount, increments seqno and signs new state.
+// Returned state should be delivered to counterparty.
+func (s *ChannelSession) Pay(amount tlb.Coins) (*SignedSemiChannel, error) {
+	if amount.Nano().Sign() <= 0 {
+		return nil, fmt.Errorf("amount should be positive")
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	body := s.our.State.Data
+	body.Seqno++
+	body.Sent = tlb.FromNanoTON(new(big.Int).Add(body.Sent.Nano(), amount.Nano()))
+
+	return s.updateOurState(body)
+}
+
+// updateOurState - checks that we have enough balance for the new body, signs and saves it
+func (s *ChannelSession) updateOurState(body SemiChannelBody) (*SignedSemiChannel, error) {
+	ourDeposit, _ := s.deposits()
+
+	var theirSent = big.NewInt(0)
+	var theirBody *SemiChannelBody
+	if s.their != nil {
+		theirSent = s.their.State.Data.Sent.Nano()
+		b := s.their.State.Data
+		theirBody = &b
+	}
+
+	if err := checkSemiChannelBalance(&body, ourDeposit, theirSent); err != nil {
+		return nil, err
+	}
+
+	st, err := s.signState(SemiChannel{
+		ChannelID:        s.channelID,
+		Data:             body,
+		CounterpartyData: theirBody,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.our = st
+
+	return st, nil
+}
+
+// ReceiveState - verifies and accepts new state of counterparty, returns amount received since the previous state.
+func (s *ChannelSession) ReceiveState(state *SignedSemiChannel) (tlb.Coins, error) {
+	if err := s.verifyState(state, s.theirKey); err != nil {
+		return tlb.Coins{}, err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	prevSent := big.NewInt(0)
+	if s.their != nil {
+		if state.State.Data.Seqno <= s.their.State.Data.Seqno {
+			return tlb.Coins{}, fmt.Errorf("%w: seqno %d, current %d", ErrOutdatedState, state.State.Data.Seqno, s.their.State.Data.Seqno)
+		}
+		prevSent = s.their.State.Data.Sent.Nano()
+	}
+
+	sent := state.State.Data.Sent.Nano()
+	if sent.Cmp(prevSent) < 0 {
+		return tlb.Coins{}, fmt.Errorf("%w: sent amount decreased", ErrOutdatedState)
+	}
+
+	if cp := state.State.CounterpartyData; cp != nil {
+		// counterparty cannot know our state newer than we have
+		if cp.Seqno > s.our.State.Data.Seqno {
+			return tlb.Coins{}, fmt.Errorf("%w: counterparty refers to unknown our state", ErrOutdatedState)
+		}
+	}
+
+	_, theirDeposit := s.deposits()
+	if err := checkSemiChannelBalance(&state.State.Data, theirDeposit, s.our.State.Data.Sent.Nano()); err != nil {
+		return tlb.Coins{}, err
+	}
+
+	s.their = state
+	return tlb.FromNanoTON(new(big.Int).Sub(sent, prevSent)), nil
+}
+
+// PrepareCooperativeCommit - creates commit of the current seqnos signed by our side,
+// it should be sent to counterparty to be signed by SignCooperativeCommit.
+func (s *ChannelSession) PrepareCooperativeCommit() (*CooperativeCommit, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	msg := &CooperativeCommit{}
+	msg.IsA = s.isA
+	msg.Signed.ChannelID = s.channelID
+	msg.Signed.SeqnoA, msg.Signed.SeqnoB = s.seqnos()
+
+	sig, err := toSignature(msg.Signed, s.ourKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign commit: %w", err)
+	}
+	s.setOurSignature(&msg.SignatureA, &msg.SignatureB, sig)
+
+	return msg, nil
+}
+
+// SignCooperativeCommit - verifies commit signed by counterparty and adds our signature,
+// seqnos of the commit cannot be newer than states we know.
+func (s *ChannelSession) SignCooperativeCommit(msg *CooperativeCommit) error {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if !bytes.Equal(msg.Signed.ChannelID, s.channelID) {
+		return ErrChannelIDMismatch
+	}
+
+	seqnoA, seqnoB := s.seqnos()
+	if msg.Signed.SeqnoA > seqnoA || msg.Signed.SeqnoB > seqnoB {
+		return fmt.Errorf("%w: commit has unknown seqno", ErrOutdatedState)
+	}
+
+	if err := s.verifyTheirSignature(msg.Signed, msg.SignatureA, msg.SignatureB); err != nil {
+		return err
+	}
+
+	sig, err := toSignature(msg.Signed, s.ourKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign commit: %w", err)
+	}
+	s.setOurSignature(&msg.SignatureA, &msg.SignatureB, sig)
+
+	return nil
+}
+
+// PrepareCooperativeClose - creates close request with the current balances signed by our side,
+// it should be sent to counterparty to be signed by SignCooperativeClose.
+// Conditionals should be resolved before cooperative close.
+func (s *ChannelSession) PrepareCooperativeClose() (*CooperativeClose, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	msg, err := s.buildCooperativeClose()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := toSignature(msg.Signed, s.ourKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign close: %w", err)
+	}
+	s.setOurSignature(&msg.SignatureA, &msg.SignatureB, sig)
+
+	return msg, nil
+}
+
+// SignCooperativeClose - verifies close request signed by counterparty, it should match our view of balances and seqnos.
+// After our signature is added, message can be sent to the channel contract.
+func (s *ChannelSession) SignCooperativeClose(msg *CooperativeClose) error {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	expected, err := s.buildCooperativeClose()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(msg.Signed.ChannelID, s.channelID) {
+		return ErrChannelIDMismatch
+	}
+
+	if msg.Signed.SeqnoA != expected.Signed.SeqnoA || msg.Signed.SeqnoB != expected.Signed.SeqnoB ||
+		msg.Signed.BalanceA.Nano().Cmp(expected.Signed.BalanceA.Nano()) != 0 ||
+		msg.Signed.BalanceB.Nano().Cmp(expected.Signed.BalanceB.Nano()) != 0 {
+		return fmt.Errorf("close request does not match our state")
+	}
+
+	if err = s.verifyTheirSignature(msg.Signed, msg.SignatureA, msg.SignatureB); err != nil {
+		return err
+	}
+
+	sig, err := toSignature(msg.Signed, s.ourKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign close: %w", err)
+	}
+	s.setOurSignature(&msg.SignatureA, &msg.SignatureB, sig)
+
+	return nil
+}
+
+// StartUncooperativeClose - creates message with both last states signed by our key,
+// it can be used when counterparty is not responding.
+func (s *ChannelSession) StartUncooperativeClose() (*StartUncooperativeClose, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if s.their == nil {
+		return nil, ErrCounterpartyNoState
+	}
+
+	msg := &StartUncooperativeClose{}
+	msg.IsSignedByA = s.isA
+	msg.Signed.ChannelID = s.channelID
+	msg.Signed.A, msg.Signed.B = *s.our, *s.their
+	if !s.isA {
+		msg.Signed.A, msg.Signed.B = msg.Signed.B, msg.Signed.A
+	}
+
+	var err error
+	msg.Signature, err = toSignature(msg.Signed, s.ourKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign uncooperative close: %w", err)
+	}
+	return msg, nil
+}
+
+// ChallengeQuarantinedState - creates challenge with both last states signed by our key,
+// it replaces outdated state committed by counterparty during the quarantine.
+func (s *ChannelSession) ChallengeQuarantinedState() (*ChallengeQuarantinedState, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if s.their == nil {
+		return nil, ErrCounterpartyNoState
+	}
+
+	msg := &ChallengeQuarantinedState{}
+	msg.IsChallengedByA = s.isA
+	msg.Signed.ChannelID = s.channelID
+	msg.Signed.A, msg.Signed.B = *s.our, *s.their
+	if !s.isA {
+		msg.Signed.A, msg.Signed.B = msg.Signed.B, msg.Signed.A
+	}
+
+	var err error
+	msg.Signature, err = toSignature(msg.Signed, s.ourKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign challenge: %w", err)
+	}
+	return msg, nil
+}
+
+func (s *ChannelSession) buildCooperativeClose() (*CooperativeClose, error) {
+	if !s.our.State.Data.Conditionals.IsEmpty() || (s.their != nil && !s.their.State.Data.Conditionals.IsEmpty()) {
+		return nil, ErrConditionalsNotEmpty
+	}
+
+	a, b := s.balances()
+	if a.Sign() < 0 || b.Sign() < 0 {
+		return nil, ErrNotEnoughBalance
+	}
+
+	msg := &CooperativeClose{}
+	msg.Signed.ChannelID = s.channelID
+	msg.Signed.BalanceA = tlb.FromNanoTON(a)
+	msg.Signed.BalanceB = tlb.FromNanoTON(b)
+	msg.Signed.SeqnoA, msg.Signed.SeqnoB = s.seqnos()
+	return msg, nil
+}
+
+func (s *ChannelSession) seqnos() (seqnoA, seqnoB uint64) {
+	seqnoA = s.our.State.Data.Seqno
+	if s.their != nil {
+		seqnoB = s.their.State.Data.Seqno
+	}
+
+	if !s.isA {
+		seqnoA, seqnoB = seqnoB, seqnoA
+	}
+	return seqnoA, seqnoB
+}
+
+func (s *ChannelSession) balances() (a, b *big.Int) {
+	sentA, sentB := s.our.State.Data.Sent.Nano(), big.NewInt(0)
+	if s.their != nil {
+		sentB = s.their.State.Data.Sent.Nano()
+	}
+	if !s.isA {
+		sentA, sentB = sentB, sentA
+	}
+
+	a = new(big.Int).Add(s.balanceA.Nano(), sentB)
+	a.Sub(a, sentA)
+	b = new(big.Int).Add(s.balanceB.Nano(), sentA)
+	b.Sub(b, sentB)
+	return a, b
+}
+
+// deposits - on-chain balances of our and their side
+func (s *ChannelSession) deposits() (our, their *big.Int) {
+	if s.isA {
+		return s.balanceA.Nano(), s.balanceB.Nano()
+	}
+	return s.balanceB.Nano(), s.balanceA.Nano()
+}
+
+func (s *ChannelSession) setOurSignature(a, b *Signature, sig Signature) {
+	if s.isA {
+		*a = sig
+	} else {
+		*b = sig
+	}
+}
+
+func (s *ChannelSession) verifyTheirSignature(signed any, a, b Signature) error {
+	sig := b
+	if !s.isA {
+		sig = a
+	}
+
+	c, err := tlb.ToCell(signed)
+	if err != nil {
+		return fmt.Errorf("failed to serialize signed data: %w", err)
+	}
+
+	if !c.Verify(s.theirKey, sig.Value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *ChannelSession) signState(state SemiChannel) (*SignedSemiChannel, error) {
+	sig, err := toSignature(state, s.ourKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign state: %w", err)
+	}
+
+	return &SignedSemiChannel{
+		Signature: sig,
+		State:     state,
+	}, nil
+}
+
+func (s *ChannelSession) verifyState(state *SignedSemiChannel, key ed25519.PublicKey) error {
+	if state == nil {
+		return fmt.Errorf("state is nil")
+	}
+
+	if !bytes.Equal(state.State.ChannelID, s.channelID) {
+		return ErrChannelIDMismatch
+	}
+
+	c, err := tlb.ToCell(state.State)
+	if err != nil {
+		return fmt.Errorf("failed to serialize state: %w", err)
+	}
+
+	if !c.Verify(key, state.Signature.Value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// checkSemiChannelBalance - party cannot send and lock more than it has deposited plus received
+func checkSemiChannelBalance(body *SemiChannelBody, deposit, received *big.Int) error {
+	locked, err := conditionalsSum(body.Conditionals)
+	if err != nil {
+		return err
+	}
+
+	left := new(big.Int).Add(deposit, received)
+	left.Sub(left, body.Sent.Nano())
+	left.Sub(left, locked)
+
+	if left.Sign() < 0 {
+		return ErrNotEnoughBalance
+	}
+	return nil
+}
+
+// conditionalsSum - total amount locked by conditional payments
+func conditionalsSum(conditionals *cell.Dictionary) (*big.Int, error) {
+	sum := big.NewInt(0)
+	if conditionals.IsEmpty() {
+		return sum, nil
+	}
+
+	list, err := conditionals.LoadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conditionals: %w", err)
+	}
+
+	for _, kv := range list {
+		var cond ConditionalPayment
+		if err = tlb.LoadFromCell(&cond, kv.Value); err != nil {
+			return nil, fmt.Errorf("failed to parse conditional: %w", err)
+		}
+		sum.Add(sum, cond.Amount.Nano())
+	}
+	return sum, nil
+}
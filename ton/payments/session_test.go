@@ -0,0 +1,172 @@
+package payments
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+func newTestSessions(t *testing.T) (a, b *ChannelSession) {
+	chID, err := RandomChannelID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubA, keyA, _ := ed25519.GenerateKey(nil)
+	pubB, keyB, _ := ed25519.GenerateKey(nil)
+
+	a, err = NewChannelSession(chID, true, keyA, pubB, tlb.MustFromTON("1"), tlb.MustFromTON("0.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = NewChannelSession(chID, false, keyB, pubA, tlb.MustFromTON("1"), tlb.MustFromTON("0.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// exchange initial states
+	if _, err = a.ReceiveState(b.OurState()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.ReceiveState(a.OurState()); err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestChannelSession_Pay(t *testing.T) {
+	a, b := newTestSessions(t)
+
+	st, err := a.Pay(tlb.MustFromTON("0.3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// state should survive serialization
+	c, err := tlb.ToCell(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received SignedSemiChannel
+	if err = tlb.LoadFromCell(&received, c.BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+
+	amt, err := b.ReceiveState(&received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amt.Nano().Cmp(tlb.MustFromTON("0.3").Nano()) != 0 {
+		t.Fatal("incorrect received amount", amt.String())
+	}
+
+	if _, err = b.ReceiveState(&received); !errors.Is(err, ErrOutdatedState) {
+		t.Fatal("replay should be rejected, but:", err)
+	}
+
+	st, err = b.Pay(tlb.MustFromTON("0.7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.ReceiveState(st); err != nil {
+		t.Fatal(err)
+	}
+
+	balA, balB := a.Balances()
+	if balA.String() != "1.4" || balB.String() != "0.1" {
+		t.Fatal("incorrect balances", balA.String(), balB.String())
+	}
+
+	if _, err = b.Pay(tlb.MustFromTON("0.2")); !errors.Is(err, ErrNotEnoughBalance) {
+		t.Fatal("should be not enough balance, but:", err)
+	}
+
+	// forged state
+	forged := *a.OurState()
+	forged.State.Data.Seqno++
+	forged.State.Data.Sent = tlb.ZeroCoins
+	if _, err = b.ReceiveState(&forged); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatal("forged state should be rejected, but:", err)
+	}
+
+	// counterparty cannot spend locked funds
+	conds := cell.NewDict(32)
+	if err = conds.SetIntKey(tlb.MustFromTON("0").Nano(), cell.BeginCell().MustStoreBigCoins(tlb.MustFromTON("1.4").Nano()).EndCell()); err != nil {
+		t.Fatal(err)
+	}
+	body := a.OurState().State.Data
+	body.Seqno++
+	body.Conditionals = conds
+	if _, err = a.updateOurState(body); err != nil {
+		t.Fatal(err)
+	}
+	if avail, _ := a.Available(); avail.Nano().Sign() != 0 {
+		t.Fatal("all funds should be locked", avail.String())
+	}
+	if _, err = a.Pay(tlb.MustFromTON("0.01")); !errors.Is(err, ErrNotEnoughBalance) {
+		t.Fatal("should be not enough balance, but:", err)
+	}
+}
+
+func TestChannelSession_Cooperative(t *testing.T) {
+	a, b := newTestSessions(t)
+
+	st, err := a.Pay(tlb.MustFromTON("0.25"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.ReceiveState(st); err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err := b.PrepareCooperativeCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.SignCooperativeCommit(commit); err != nil {
+		t.Fatal(err)
+	}
+	if commit.Signed.SeqnoA != 1 || commit.Signed.SeqnoB != 0 {
+		t.Fatal("incorrect commit seqnos")
+	}
+
+	c, _ := tlb.ToCell(commit.Signed)
+	if !c.Verify(a.ourKey.Public().(ed25519.PublicKey), commit.SignatureA.Value) ||
+		!c.Verify(b.ourKey.Public().(ed25519.PublicKey), commit.SignatureB.Value) {
+		t.Fatal("commit should be signed by both")
+	}
+
+	closeMsg, err := a.PrepareCooperativeClose()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := *closeMsg
+	tampered.Signed.BalanceA = tlb.MustFromTON("1")
+	if err = b.SignCooperativeClose(&tampered); err == nil {
+		t.Fatal("tampered close should be rejected")
+	}
+
+	if err = b.SignCooperativeClose(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+
+	if closeMsg.Signed.BalanceA.String() != "0.75" || closeMsg.Signed.BalanceB.String() != "0.75" {
+		t.Fatal("incorrect close balances")
+	}
+
+	if _, err = tlb.ToCell(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+
+	unc, err := b.StartUncooperativeClose()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unc.IsSignedByA || unc.Signed.A.State.Data.Seqno != 1 || unc.Signed.B.State.Data.Seqno != 0 {
+		t.Fatal("incorrect uncooperative close")
+	}
+}
@@ -42,6 +42,7 @@ type SemiChannelBody struct {
 
 type SemiChannel struct {
 	_                tlb.Magic        `tlb:"#43685374"`
+	ChannelID        ChannelID        `tlb:"bits 128"`
 	Data             SemiChannelBody  `tlb:"."`
 	CounterpartyData *SemiChannelBody `tlb:"maybe ^"`
 }
@@ -0,0 +1,309 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton/wallet"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var Logger = func(v ...any) {}
+
+var timeNow = time.Now
+
+type WatchtowerAPI interface {
+	TonApi
+	SubscribeOnTransactions(workerCtx context.Context, addr *address.Address, lastProcessedLT uint64, channel chan<- *tlb.Transaction)
+}
+
+// Transactor - delivers message body to the channel contract, usually it is a wallet
+// which sends internal message with some amount to pay fees.
+type Transactor interface {
+	SendToChannel(ctx context.Context, channel *address.Address, body *cell.Cell) error
+}
+
+type walletTransactor struct {
+	w      *wallet.Wallet
+	amount tlb.Coins
+}
+
+// WalletTransactor - Transactor which sends messages from wallet, amount is attached to pay contract fees
+func WalletTransactor(w *wallet.Wallet, amount tlb.Coins) Transactor {
+	return &walletTransactor{w: w, amount: amount}
+}
+
+func (t *walletTransactor) SendToChannel(ctx context.Context, channel *address.Address, body *cell.Cell) error {
+	return t.w.Send(ctx, wallet.SimpleMessage(channel, t.amount, body), true)
+}
+
+// Watchtower - monitors channels and protects them when counterparty is trying to close channel
+// with outdated state. It challenges quarantined state with our latest one,
+// settles conditionals which preimages are known, and finalizes closure when all windows are ended.
+// Sent messages are verified against channel state, and sent again if they were not applied in ConfirmTimeout.
+type Watchtower struct {
+	client     *Client
+	api        WatchtowerAPI
+	transactor Transactor
+
+	// PollInterval - how often channel state is checked when nothing happens
+	PollInterval time.Duration
+	// RetryInterval - delay before next check when sending was failed
+	RetryInterval time.Duration
+	// ConfirmTimeout - how long to wait for sent message to change channel state, before sending it again
+	ConfirmTimeout time.Duration
+
+	channels map[string]*watchedChannel
+	mx       sync.Mutex
+}
+
+type watchedChannel struct {
+	addr    *address.Address
+	session *ChannelSession
+	cancel  context.CancelFunc
+
+	// quarantine start time which we already challenged
+	challenged   uint32
+	challengedAt time.Time
+	// hash of quarantine at the moment when we sent settle, and hash of the sent settle
+	settled   []byte
+	settleMsg []byte
+	settledAt time.Time
+	finished  bool
+	finishAt  time.Time
+}
+
+func NewWatchtower(api WatchtowerAPI, transactor Transactor) *Watchtower {
+	return &Watchtower{
+		client:         NewPaymentChannelClient(api),
+		api:            api,
+		transactor:     transactor,
+		PollInterval:   1 * time.Minute,
+		RetryInterval:  10 * time.Second,
+		ConfirmTimeout: 1 * time.Minute,
+		channels:       map[string]*watchedChannel{},
+	}
+}
+
+// Watch - starts monitoring of the channel, session should be the same object which is used for payments,
+// so watchtower always knows the latest states.
+func (w *Watchtower) Watch(ctx context.Context, addr *address.Address, session *ChannelSession) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	key := addr.String()
+	if _, ok := w.channels[key]; ok {
+		return fmt.Errorf("channel is already watched")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	wc := &watchedChannel{
+		addr:    addr,
+		session: session,
+		cancel:  cancel,
+	}
+	w.channels[key] = wc
+
+	go w.watch(ctx, wc)
+	return nil
+}
+
+// Unwatch - stops monitoring of the channel
+func (w *Watchtower) Unwatch(addr *address.Address) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if wc, ok := w.channels[addr.String()]; ok {
+		wc.cancel()
+		delete(w.channels, addr.String())
+	}
+}
+
+// Stop - stops monitoring of all channels
+func (w *Watchtower) Stop() {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	for key, wc := range w.channels {
+		wc.cancel()
+		delete(w.channels, key)
+	}
+}
+
+func (w *Watchtower) watch(ctx context.Context, wc *watchedChannel) {
+	var lastLT uint64
+	var txs chan *tlb.Transaction
+
+	subscribe := func() {
+		txs = make(chan *tlb.Transaction, 10)
+		go w.api.SubscribeOnTransactions(ctx, wc.addr, lastLT, txs)
+	}
+	subscribe()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tx, ok := <-txs:
+			if !ok {
+				if ctx.Err() != nil {
+					return
+				}
+				// subscription was interrupted, continue from the last seen transaction
+				subscribe()
+				continue
+			}
+			lastLT = tx.LT
+		case <-timer.C:
+		}
+
+		next, err := w.check(ctx, wc)
+		if err != nil {
+			Logger("watchtower failed to check channel", wc.addr.String(), ":", err.Error())
+			next = w.RetryInterval
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(next)
+	}
+}
+
+// check - fetches channel state and reacts on it, returns time to wait before the next check
+func (w *Watchtower) check(ctx context.Context, wc *watchedChannel) (time.Duration, error) {
+	block, err := w.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	ch, err := w.client.GetAsyncChannel(ctx, block, wc.addr, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get channel: %w", err)
+	}
+
+	now := timeNow().Unix()
+	st := &ch.Storage
+
+	switch st.calcStateAt(now) {
+	case ChannelStatusClosureStarted:
+		q := st.Quarantine
+		if !q.StateChallenged && q.StateCommittedByA != wc.session.IsA() &&
+			!w.pending(wc.challenged == q.QuarantineStarts, wc.challengedAt) && wc.session.isOutdated(q) {
+			msg, err := wc.session.ChallengeQuarantinedState()
+			if err != nil {
+				return 0, fmt.Errorf("failed to build challenge: %w", err)
+			}
+
+			if err = w.send(ctx, wc.addr, msg); err != nil {
+				return 0, fmt.Errorf("failed to send challenge: %w", err)
+			}
+			wc.challenged, wc.challengedAt = q.QuarantineStarts, timeNow()
+			Logger("watchtower challenged outdated state of channel", wc.addr.String())
+		}
+		return w.until(now, int64(q.QuarantineStarts)+int64(st.ClosingConfig.QuarantineDuration)), nil
+	case ChannelStatusSettlingConditionals:
+		qc, err := tlb.ToCell(st.Quarantine)
+		if err != nil {
+			return 0, fmt.Errorf("failed to serialize quarantine: %w", err)
+		}
+
+		// settle is rebuilt on each check, because preimages can be added and deadlines can pass during the window
+		msg, err := wc.session.SettleKnownConditionals(now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to build settle: %w", err)
+		}
+
+		if msg != nil {
+			mc, err := tlb.ToCell(msg)
+			if err != nil {
+				return 0, fmt.Errorf("failed to serialize settle: %w", err)
+			}
+
+			// the same settle is sent again only when it was lost:
+			// when quarantine is changed after our settle, it was applied,
+			// if it is still the same for too long, we send it again
+			unchanged := bytes.Equal(wc.settled, qc.Hash())
+			if !bytes.Equal(wc.settleMsg, mc.Hash()) || (unchanged && !w.pending(true, wc.settledAt)) {
+				if err = w.send(ctx, wc.addr, msg); err != nil {
+					return 0, fmt.Errorf("failed to send settle: %w", err)
+				}
+				wc.settled, wc.settleMsg, wc.settledAt = qc.Hash(), mc.Hash(), timeNow()
+				Logger("watchtower settled conditionals of channel", wc.addr.String())
+			}
+		}
+
+		ends := int64(st.Quarantine.QuarantineStarts) + int64(st.ClosingConfig.QuarantineDuration) +
+			int64(st.ClosingConfig.ConditionalCloseDuration)
+		return w.until(now, ends), nil
+	case ChannelStatusAwaitingFinalization:
+		// channel is still not closed, so if we already sent finish, it was not applied
+		if !w.pending(wc.finished, wc.finishAt) {
+			if err = w.send(ctx, wc.addr, FinishUncooperativeClose{}); err != nil {
+				return 0, fmt.Errorf("failed to send finish: %w", err)
+			}
+			wc.finished, wc.finishAt = true, timeNow()
+			Logger("watchtower finalized closure of channel", wc.addr.String())
+		}
+		return w.confirmWait(wc.finishAt), nil
+	case ChannelStatusOpen:
+		// channel could be closed and reopened, so we may need to react again
+		wc.challenged, wc.settled, wc.settleMsg, wc.finished = 0, nil, nil, false
+	}
+
+	return w.PollInterval, nil
+}
+
+// pending - true when message was sent, and we are still waiting for it to be applied on chain
+func (w *Watchtower) pending(sent bool, at time.Time) bool {
+	return sent && timeNow().Sub(at) < w.ConfirmTimeout
+}
+
+// confirmWait - time to wait till sent message should be applied, but not longer than poll interval
+func (w *Watchtower) confirmWait(sentAt time.Time) time.Duration {
+	wait := sentAt.Add(w.ConfirmTimeout).Sub(timeNow())
+	if wait <= 0 || wait > w.PollInterval {
+		return w.PollInterval
+	}
+	return wait
+}
+
+func (w *Watchtower) send(ctx context.Context, addr *address.Address, msg any) error {
+	body, err := tlb.ToCell(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize message: %w", err)
+	}
+	return w.transactor.SendToChannel(ctx, addr, body)
+}
+
+// until - time to wait till deadline, but not longer than poll interval
+func (w *Watchtower) until(now, deadline int64) time.Duration {
+	wait := time.Duration(deadline-now+1) * time.Second
+	if wait > w.PollInterval {
+		return w.PollInterval
+	}
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// isOutdated - checks if quarantined state is older than the states we have
+func (s *ChannelSession) isOutdated(q *QuarantinedState) bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	seqnoA, seqnoB := s.seqnos()
+	return q.StateA.Seqno < seqnoA || q.StateB.Seqno < seqnoB
+}
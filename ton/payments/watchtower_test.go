@@ -0,0 +1,276 @@
+package payments
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+type channelMock struct {
+	ton.APIClientWrapped
+	storage AsyncChannelStorageData
+}
+
+func (m *channelMock) CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error) {
+	return &ton.BlockIDExt{SeqNo: 1}, nil
+}
+
+func (m *channelMock) GetAccount(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error) {
+	data, err := tlb.ToCell(m.storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tlb.Account{
+		IsActive: true,
+		State: &tlb.AccountState{
+			IsValid: true,
+			Address: addr,
+			AccountStorage: tlb.AccountStorage{
+				Status: tlb.AccountStatusActive,
+			},
+		},
+		Code: AsyncPaymentChannelCode,
+		Data: data,
+	}, nil
+}
+
+type transactorMock struct {
+	sent []*cell.Cell
+}
+
+func (t *transactorMock) SendToChannel(ctx context.Context, channel *address.Address, body *cell.Cell) error {
+	t.sent = append(t.sent, body)
+	return nil
+}
+
+func TestWatchtower_Check(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	a, b := newTestSessions(t)
+
+	storage := AsyncChannelStorageData{
+		KeyA:      a.ourKey.Public().(ed25519.PublicKey),
+		KeyB:      b.ourKey.Public().(ed25519.PublicKey),
+		ChannelID: a.ChannelID(),
+		ClosingConfig: ClosingConfig{
+			QuarantineDuration:       180,
+			MisbehaviorFine:          tlb.ZeroCoins,
+			ConditionalCloseDuration: 200,
+		},
+		Payments: PaymentConfig{
+			ExcessFee: tlb.ZeroCoins,
+			DestA:     address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N"),
+			DestB:     address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju"),
+		},
+	}
+
+	data, _ := tlb.ToCell(storage)
+	si, _ := tlb.ToCell(tlb.StateInit{Code: AsyncPaymentChannelCode, Data: data})
+	addr := address.NewAddress(0, 0, si.Hash())
+
+	for i := 0; i < 2; i++ {
+		st, err := b.Pay(tlb.MustFromTON("0.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = a.ReceiveState(st); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage.Initialized = true
+	storage.BalanceA = tlb.MustFromTON("1")
+	storage.BalanceB = tlb.MustFromTON("0.5")
+
+	// b commits old state, where it has sent less
+	storage.Quarantine = &QuarantinedState{
+		StateA:            a.OurState().State.Data,
+		StateB:            SemiChannelBody{Seqno: 1, Sent: tlb.MustFromTON("0.1")},
+		QuarantineStarts:  uint32(now.Unix() - 10),
+		StateCommittedByA: false,
+	}
+
+	api := &channelMock{storage: storage}
+	tr := &transactorMock{}
+	w := NewWatchtower(api, tr)
+	wc := &watchedChannel{addr: addr, session: a}
+
+	next, err := w.check(context.Background(), wc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.sent) != 1 {
+		t.Fatal("challenge should be sent")
+	}
+	if next != time.Minute {
+		t.Fatal("incorrect next check", next)
+	}
+
+	var challenge ChallengeQuarantinedState
+	if err = tlb.LoadFromCell(&challenge, tr.sent[0].BeginParse()); err != nil {
+		t.Fatal(err)
+	}
+	if !challenge.IsChallengedByA || challenge.Signed.B.State.Data.Seqno != 2 {
+		t.Fatal("incorrect challenge")
+	}
+
+	// should not be sent twice
+	if _, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 1 {
+		t.Fatal("challenge should not be resent")
+	}
+
+	// challenge was not applied on chain
+	now = now.Add(61 * time.Second)
+	if _, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 2 {
+		t.Fatal("challenge should be resent")
+	}
+
+	api.storage.Quarantine.StateChallenged = true
+	now = now.Add(99 * time.Second)
+	if next, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if next != 11*time.Second {
+		t.Fatal("next check should be at quarantine end", next)
+	}
+
+	now = now.Add(400 * time.Second)
+	if _, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 3 {
+		t.Fatal("finish should be sent")
+	}
+	if tr.sent[2].BeginParse().MustLoadUInt(32) != 0x25432a91 {
+		t.Fatal("incorrect finish message")
+	}
+
+	now = now.Add(10 * time.Second)
+	if next, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 3 {
+		t.Fatal("finish should not be resent before confirm timeout")
+	}
+	if next != 50*time.Second {
+		t.Fatal("next check should be at confirm timeout", next)
+	}
+
+	now = now.Add(50 * time.Second)
+	if _, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 4 {
+		t.Fatal("finish should be resent, channel is still not closed")
+	}
+}
+
+func TestWatchtower_SettleLater(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	a, b := newTestSessions(t)
+
+	preimage := []byte("some secret preimage")
+	hash := sha256.Sum256(preimage)
+	cond, err := HTLCCondition(hash[:], uint32(now.Unix()+1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := b.AddConditional(7, ConditionalPayment{Amount: tlb.MustFromTON("0.2"), Condition: cond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.ReceiveState(st); err != nil {
+		t.Fatal(err)
+	}
+
+	storage := AsyncChannelStorageData{
+		Initialized: true,
+		KeyA:        a.ourKey.Public().(ed25519.PublicKey),
+		KeyB:        b.ourKey.Public().(ed25519.PublicKey),
+		ChannelID:   a.ChannelID(),
+		BalanceA:    tlb.MustFromTON("1"),
+		BalanceB:    tlb.MustFromTON("1"),
+		ClosingConfig: ClosingConfig{
+			QuarantineDuration:       180,
+			MisbehaviorFine:          tlb.ZeroCoins,
+			ConditionalCloseDuration: 200,
+		},
+		Payments: PaymentConfig{
+			ExcessFee: tlb.ZeroCoins,
+			DestA:     address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N"),
+			DestB:     address.MustParseAddr("EQBletedrsSdih8H_-bR0cDZhdbLRy73ol6psGCrRKDahFju"),
+		},
+		Quarantine: &QuarantinedState{
+			StateA:            a.OurState().State.Data,
+			StateB:            st.State.Data,
+			QuarantineStarts:  uint32(now.Unix() - 190),
+			StateCommittedByA: false,
+			StateChallenged:   true,
+		},
+	}
+
+	// address is calculated from the initial data of the channel
+	initial := storage
+	initial.Initialized, initial.BalanceA, initial.BalanceB, initial.Quarantine = false, tlb.Coins{}, tlb.Coins{}, nil
+	data, _ := tlb.ToCell(initial)
+	si, _ := tlb.ToCell(tlb.StateInit{Code: AsyncPaymentChannelCode, Data: data})
+
+	tr := &transactorMock{}
+	w := NewWatchtower(&channelMock{storage: storage}, tr)
+	wc := &watchedChannel{addr: address.NewAddress(0, 0, si.Hash()), session: a}
+
+	if _, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 0 {
+		t.Fatal("preimage is unknown, nothing should be sent")
+	}
+
+	// preimage is learned later, during the conditionals window
+	a.AddPreimage(preimage)
+	now = now.Add(10 * time.Second)
+	if _, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 1 {
+		t.Fatal("settle should be sent")
+	}
+
+	if _, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 1 {
+		t.Fatal("settle should not be resent before confirm timeout")
+	}
+
+	now = now.Add(61 * time.Second)
+	if _, err = w.check(context.Background(), wc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 2 {
+		t.Fatal("settle should be resent, quarantine is not changed")
+	}
+}
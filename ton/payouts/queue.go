@@ -0,0 +1,683 @@
+package payouts
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/ton/wallet"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var Logger = func(v ...any) {}
+
+var timeNow = time.Now
+
+var (
+	ErrIntentConflict = errors.New("intent with this key already exists, but with different parameters")
+)
+
+// highload v3 packs messages to internal message to itself with this op
+const highloadV3InternalTransferOp = 0xae42e5a4
+
+type TonAPI interface {
+	WaitForBlock(seqno uint32) ton.APIClientWrapped
+	CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error)
+	SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error
+}
+
+type Config struct {
+	// MaxBatchSize - max messages in one external message, 0 means max for wallet version.
+	// Consider that external message size is limited to 64KB, so set it when sending big payloads using highload.
+	MaxBatchSize int
+	// MessageTTL - time during which external message can be accepted by wallet,
+	// cannot be less than ttl configured in wallet. Default is ttl of the wallet.
+	MessageTTL time.Duration
+	// ExpireGap - additional time after MessageTTL, to make sure that blockchain is also past expiration. Default is 1 min.
+	ExpireGap time.Duration
+	// ResendInterval - how often not yet confirmed message is resent. Default is 15s.
+	ResendInterval time.Duration
+	// PollInterval - how often queue is processed in Run. Default is 3s.
+	PollInterval time.Duration
+	// MaxAttempts - how many times intent can be included in expired batch before it is failed. Default is 3.
+	MaxAttempts int
+	// MaxScanTransactions - max wallet transactions to check in one step when searching for batch transaction,
+	// search position is saved to store, so the next step continues from it. Default is 1000.
+	MaxScanTransactions int
+}
+
+// Transfer - request to send coins, Key is used for idempotency
+type Transfer struct {
+	Key    string
+	To     *address.Address
+	Amount tlb.Coins
+	Bounce bool
+	Body   *cell.Cell
+}
+
+// Queue - durable queue of outgoing transfers of the wallet.
+// Every intent is persisted in store before it is sent, and batch with the exact external message
+// is persisted before it is broadcast, so after restart queue continues from the same point:
+// resends the same message until it is confirmed or expired, and only after definitive
+// expiration intents can be included in new batch. Only one batch is in flight at the same time.
+type Queue struct {
+	api   TonAPI
+	w     *wallet.Wallet
+	store Store
+	cfg   Config
+
+	lastResend map[string]time.Time
+	mx         sync.Mutex
+}
+
+func NewQueue(api TonAPI, w *wallet.Wallet, store Store, cfg Config) (*Queue, error) {
+	maxNum := MaxMessagesPerExternal(w)
+	if maxNum == 0 {
+		return nil, fmt.Errorf("wallet version is not supported: %w", wallet.ErrUnsupportedWalletVersion)
+	}
+
+	if cfg.MaxBatchSize <= 0 || cfg.MaxBatchSize > maxNum {
+		cfg.MaxBatchSize = maxNum
+	}
+	walletTTL := WalletMessageTTL(w)
+	if cfg.MessageTTL <= 0 {
+		cfg.MessageTTL = walletTTL
+	}
+	if cfg.MessageTTL <= 0 {
+		cfg.MessageTTL = 3 * time.Minute
+	}
+	if cfg.MessageTTL < walletTTL {
+		return nil, fmt.Errorf("message ttl %s is less than wallet message ttl %s", cfg.MessageTTL, walletTTL)
+	}
+	if cfg.ExpireGap <= 0 {
+		cfg.ExpireGap = 1 * time.Minute
+	}
+	if cfg.ResendInterval <= 0 {
+		cfg.ResendInterval = 15 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 3 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.MaxScanTransactions <= 0 {
+		cfg.MaxScanTransactions = 1000
+	}
+
+	return &Queue{
+		api:        api,
+		w:          w,
+		store:      store,
+		cfg:        cfg,
+		lastResend: map[string]time.Time{},
+	}, nil
+}
+
+// MaxMessagesPerExternal - returns how many messages wallet can send in one external message, 0 if sending is not supported
+func MaxMessagesPerExternal(w *wallet.Wallet) int {
+	switch w.GetSpec().(type) {
+	case *wallet.SpecV3, *wallet.SpecV4R2:
+		return 4
+	case *wallet.SpecHighloadV2R2:
+		return 254
+	case *wallet.SpecV5R1Beta, *wallet.SpecV5R1Final, *wallet.SpecPreprocessedV2:
+		return 255
+	case *wallet.SpecHighloadV3:
+		return 254 * 254
+	}
+	return 0
+}
+
+// WalletMessageTTL - returns time during which wallet accepts external message
+func WalletMessageTTL(w *wallet.Wallet) time.Duration {
+	switch s := w.GetSpec().(type) {
+	case *wallet.SpecHighloadV3:
+		return time.Duration(s.GetMessageTTL()) * time.Second
+	case interface{ GetMessagesTTL() uint32 }:
+		return time.Duration(s.GetMessagesTTL()) * time.Second
+	}
+	return 0
+}
+
+// Enqueue - adds transfer to the queue, if transfer with the same key was already added,
+// existing intent is returned, or ErrIntentConflict if parameters are different.
+func (q *Queue) Enqueue(ctx context.Context, transfer Transfer) (*Intent, error) {
+	if transfer.Key == "" {
+		return nil, fmt.Errorf("key should be set")
+	}
+	if transfer.To == nil {
+		return nil, fmt.Errorf("destination should be set")
+	}
+
+	intent := &Intent{
+		Key:       transfer.Key,
+		To:        transfer.To,
+		Amount:    transfer.Amount,
+		Bounce:    transfer.Bounce,
+		Body:      transfer.Body,
+		Status:    IntentStatusPending,
+		CreatedAt: timeNow(),
+	}
+
+	err := q.store.CreateIntent(ctx, intent)
+	if err != nil {
+		if !errors.Is(err, ErrIntentExists) {
+			return nil, fmt.Errorf("failed to save intent: %w", err)
+		}
+
+		existing, err := q.store.GetIntent(ctx, transfer.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get existing intent: %w", err)
+		}
+
+		if !existing.To.Equals(intent.To) || existing.Amount.Nano().Cmp(intent.Amount.Nano()) != 0 ||
+			existing.Bounce != intent.Bounce || !bytes.Equal(bodyHash(existing.Body), bodyHash(intent.Body)) {
+			return nil, ErrIntentConflict
+		}
+		return existing, nil
+	}
+	return intent, nil
+}
+
+// Get - returns current state of the intent
+func (q *Queue) Get(ctx context.Context, key string) (*Intent, error) {
+	return q.store.GetIntent(ctx, key)
+}
+
+// Wait - waits until intent is confirmed or failed, queue should be running in parallel
+func (q *Queue) Wait(ctx context.Context, key string) (*Intent, error) {
+	for {
+		intent, err := q.store.GetIntent(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if intent.Status == IntentStatusConfirmed || intent.Status == IntentStatusFailed {
+			return intent, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(q.cfg.PollInterval):
+		}
+	}
+}
+
+// Run - processes queue until context is done
+func (q *Queue) Run(ctx context.Context) error {
+	for {
+		if err := q.Step(ctx); err != nil {
+			Logger("payouts queue step failed:", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(q.cfg.PollInterval):
+		}
+	}
+}
+
+// Step - resolves sent batches, and if there is nothing in flight, sends the next batch
+func (q *Queue) Step(ctx context.Context) error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	batches, err := q.store.ListSentBatches(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list batches: %w", err)
+	}
+
+	inFlight := false
+	for _, batch := range batches {
+		resolved, err := q.resolveBatch(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to resolve batch %s: %w", batch.ID, err)
+		}
+
+		if !resolved {
+			inFlight = true
+		}
+	}
+
+	if inFlight {
+		return nil
+	}
+	return q.sendNext(ctx)
+}
+
+func (q *Queue) sendNext(ctx context.Context) error {
+	intents, err := q.store.ListPendingIntents(ctx, q.cfg.MaxBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to list pending intents: %w", err)
+	}
+
+	if len(intents) == 0 {
+		return nil
+	}
+
+	acc, err := q.getAccount(ctx)
+	if err != nil {
+		return err
+	}
+
+	messages := make([]*wallet.Message, 0, len(intents))
+	for _, intent := range intents {
+		messages = append(messages, &wallet.Message{
+			Mode: wallet.PayGasSeparately + wallet.IgnoreErrors,
+			InternalMessage: &tlb.InternalMessage{
+				IHRDisabled: true,
+				Bounce:      intent.Bounce,
+				DstAddr:     intent.To,
+				Amount:      intent.Amount,
+				Body:        intent.Body,
+			},
+		})
+	}
+
+	now := timeNow()
+	ext, err := q.w.BuildExternalMessageForMany(ctx, messages)
+	if err != nil {
+		return fmt.Errorf("failed to build external message: %w", err)
+	}
+
+	extCell, err := tlb.ToCell(ext)
+	if err != nil {
+		return fmt.Errorf("failed to serialize external message: %w", err)
+	}
+
+	inMsgHash := ext.Body.Hash()
+	batch := &Batch{
+		ID:        hex.EncodeToString(inMsgHash),
+		InMsgHash: inMsgHash,
+		External:  extCell,
+		Status:    BatchStatusSent,
+		StartLT:   acc.LastTxLT,
+		ExpireAt:  now.Add(q.cfg.MessageTTL),
+		SentAt:    now,
+	}
+
+	for _, intent := range intents {
+		batch.Keys = append(batch.Keys, intent.Key)
+		intent.Status = IntentStatusSent
+		intent.BatchID = batch.ID
+	}
+
+	// we persist batch before sending, so after restart we will track exactly this message
+	if err = q.store.SaveBatch(ctx, batch, intents); err != nil {
+		return fmt.Errorf("failed to save batch: %w", err)
+	}
+
+	q.lastResend[batch.ID] = now
+	if err = q.api.SendExternalMessage(ctx, ext); err != nil {
+		// it will be resent later
+		Logger("payouts queue failed to send batch", batch.ID, ":", err.Error())
+	}
+	return nil
+}
+
+func (q *Queue) resolveBatch(ctx context.Context, batch *Batch) (bool, error) {
+	now := timeNow()
+	expired := now.After(batch.ExpireAt.Add(q.cfg.ExpireGap))
+
+	if batch.TxLT == 0 {
+		caughtUp, err := q.searchBatchTransaction(ctx, batch)
+		if err != nil {
+			return false, err
+		}
+
+		if batch.TxLT == 0 {
+			// we can be sure that message was not accepted only when all transactions are checked
+			if expired && caughtUp {
+				return true, q.expireBatch(ctx, batch)
+			}
+
+			if now.Sub(q.lastResend[batch.ID]) >= q.cfg.ResendInterval {
+				q.lastResend[batch.ID] = now
+				if err = q.resend(ctx, batch); err != nil {
+					Logger("payouts queue failed to resend batch", batch.ID, ":", err.Error())
+				}
+			}
+			return false, nil
+		}
+	}
+
+	// batch transaction and the next ones, which can be internal transactions of highload chain
+	transactions, err := q.scanTransactions(ctx, batch.StartLT)
+	if err != nil {
+		return false, err
+	}
+
+	var extTx *tlb.Transaction
+	for _, tx := range transactions {
+		if tx.LT == batch.TxLT && isBatchTransaction(tx, batch) {
+			extTx = tx
+			break
+		}
+	}
+
+	if extTx == nil {
+		return false, fmt.Errorf("batch transaction %d is not found", batch.TxLT)
+	}
+
+	outs, err := q.collectOutMessages(transactions, extTx)
+	if err != nil {
+		return false, err
+	}
+
+	return q.confirmBatch(ctx, batch, extTx, outs, expired)
+}
+
+// searchBatchTransaction - searches transaction of the batch external message from new to old, checking
+// at most MaxScanTransactions per call. Position is saved in the batch, so search continues from it after restart.
+// Returns true when all transactions of the wallet were checked.
+func (q *Queue) searchBatchTransaction(ctx context.Context, batch *Batch) (caughtUp bool, err error) {
+	block, err := q.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	api := q.api.WaitForBlock(block.SeqNo)
+	acc, err := api.GetAccount(ctx, block, q.w.Address())
+	if err != nil {
+		return false, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	startLT, scanLT, scanTopLT := batch.StartLT, batch.ScanLT, batch.ScanTopLT
+	defer func() {
+		if err == nil && (startLT != batch.StartLT || scanLT != batch.ScanLT || scanTopLT != batch.ScanTopLT) {
+			if err = q.store.SaveBatch(ctx, batch, nil); err != nil {
+				err = fmt.Errorf("failed to save batch: %w", err)
+			}
+		}
+	}()
+
+	scanned := 0
+	for scanned < q.cfg.MaxScanTransactions {
+		if batch.ScanLT == 0 {
+			if acc.LastTxLT <= batch.StartLT {
+				return true, nil
+			}
+
+			// new search window, from the last transaction down to already checked ones
+			batch.ScanTopLT = acc.LastTxLT
+			batch.ScanLT, batch.ScanHash = acc.LastTxLT, acc.LastTxHash
+		}
+
+		num := q.cfg.MaxScanTransactions - scanned
+		if num > 16 {
+			num = 16
+		}
+
+		list, err := api.ListTransactions(ctx, q.w.Address(), uint32(num), batch.ScanLT, batch.ScanHash)
+		if err != nil && !errors.Is(err, ton.ErrNoTransactionsWereFound) {
+			return false, fmt.Errorf("failed to list transactions: %w", err)
+		}
+
+		// list is from old to new
+		for i := len(list) - 1; i >= 0; i-- {
+			tx := list[i]
+			if tx.LT <= batch.StartLT {
+				break
+			}
+			scanned++
+
+			if isBatchTransaction(tx, batch) {
+				batch.StartLT, batch.TxLT = tx.PrevTxLT, tx.LT
+				batch.ScanLT, batch.ScanHash, batch.ScanTopLT = 0, nil, 0
+				return false, nil
+			}
+		}
+
+		if len(list) > 0 && list[0].PrevTxLT > batch.StartLT {
+			batch.ScanLT, batch.ScanHash = list[0].PrevTxLT, list[0].PrevTxHash
+			continue
+		}
+
+		// window is fully checked
+		batch.StartLT = batch.ScanTopLT
+		batch.ScanLT, batch.ScanHash, batch.ScanTopLT = 0, nil, 0
+	}
+	return false, nil
+}
+
+func isBatchTransaction(tx *tlb.Transaction, batch *Batch) bool {
+	return tx.IO.In != nil && tx.IO.In.MsgType == tlb.MsgTypeExternalIn &&
+		bytes.Equal(tx.IO.In.AsExternalIn().Body.Hash(), batch.InMsgHash)
+}
+
+type sentMessage struct {
+	msg  *tlb.InternalMessage
+	tx   *tlb.Transaction
+	used bool
+}
+
+// collectOutMessages - returns messages sent by transaction, and by internal transactions of highload chain
+// which are already processed, messages of not yet processed transactions are not included
+func (q *Queue) collectOutMessages(transactions []*tlb.Transaction, tx *tlb.Transaction) ([]*sentMessage, error) {
+	if tx.IO.Out == nil {
+		return nil, nil
+	}
+
+	list, err := tx.IO.Out.ToSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse out messages: %w", err)
+	}
+
+	var res []*sentMessage
+	for _, m := range list {
+		if m.MsgType != tlb.MsgTypeInternal {
+			continue
+		}
+		msg := m.AsInternal()
+
+		if msg.DstAddr.Equals(q.w.Address()) && msg.Body != nil {
+			if op, err := msg.Body.BeginParse().PreloadUInt(32); err == nil && op == highloadV3InternalTransferOp {
+				// messages are packed and will be sent by the next transaction
+				next := findTxByInternalBody(transactions, msg.Body.Hash())
+				if next == nil {
+					continue
+				}
+
+				nextOuts, err := q.collectOutMessages(transactions, next)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, nextOuts...)
+				continue
+			}
+		}
+
+		res = append(res, &sentMessage{msg: msg, tx: tx})
+	}
+	return res, nil
+}
+
+func findTxByInternalBody(transactions []*tlb.Transaction, hash []byte) *tlb.Transaction {
+	for _, tx := range transactions {
+		if tx.IO.In != nil && tx.IO.In.MsgType == tlb.MsgTypeInternal &&
+			bytes.Equal(bodyHash(tx.IO.In.AsInternal().Body), hash) {
+			return tx
+		}
+	}
+	return nil
+}
+
+// confirmBatch - marks intents which messages were sent as confirmed, others are failed only after batch is expired,
+// because internal transactions of highload wallet can be processed later. Returns true when batch is resolved.
+func (q *Queue) confirmBatch(ctx context.Context, batch *Batch, extTx *tlb.Transaction, outs []*sentMessage, expired bool) (bool, error) {
+	intents, err := q.loadIntents(ctx, batch)
+	if err != nil {
+		return false, err
+	}
+
+	reason := "message was not sent by wallet"
+	if desc, ok := extTx.Description.Description.(tlb.TransactionDescriptionOrdinary); ok {
+		if desc.ActionPhase != nil && desc.ActionPhase.NoFunds {
+			reason += ", not enough funds"
+		}
+	}
+
+	resolved := true
+	for _, intent := range intents {
+		var sent *sentMessage
+		for _, out := range outs {
+			if !out.used && out.msg.DstAddr.Equals(intent.To) &&
+				out.msg.Amount.Nano().Cmp(intent.Amount.Nano()) == 0 &&
+				bytes.Equal(bodyHash(out.msg.Body), bodyHash(intent.Body)) {
+				sent = out
+				break
+			}
+		}
+
+		if sent != nil {
+			sent.used = true
+			intent.Status = IntentStatusConfirmed
+			intent.TxHash = sent.tx.Hash
+			intent.TxLT = sent.tx.LT
+			continue
+		}
+
+		if !expired {
+			// can be sent by the next transactions of highload chain, wait for them
+			resolved = false
+			continue
+		}
+
+		intent.Status = IntentStatusFailed
+		intent.TxHash = extTx.Hash
+		intent.TxLT = extTx.LT
+		intent.Error = reason
+	}
+
+	if resolved {
+		batch.Status = BatchStatusResolved
+	}
+	if err = q.store.SaveBatch(ctx, batch, intents); err != nil {
+		return false, fmt.Errorf("failed to save batch: %w", err)
+	}
+	if resolved {
+		delete(q.lastResend, batch.ID)
+	}
+	return resolved, nil
+}
+
+func (q *Queue) expireBatch(ctx context.Context, batch *Batch) error {
+	intents, err := q.loadIntents(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	for _, intent := range intents {
+		intent.Attempts++
+		intent.BatchID = ""
+		if intent.Attempts >= q.cfg.MaxAttempts {
+			intent.Status = IntentStatusFailed
+			intent.Error = "message was not accepted by wallet before expiration"
+			continue
+		}
+		// message cannot be accepted anymore, so it is safe to send it again
+		intent.Status = IntentStatusPending
+	}
+
+	batch.Status = BatchStatusResolved
+	if err = q.store.SaveBatch(ctx, batch, intents); err != nil {
+		return fmt.Errorf("failed to save batch: %w", err)
+	}
+	delete(q.lastResend, batch.ID)
+	return nil
+}
+
+func (q *Queue) loadIntents(ctx context.Context, batch *Batch) ([]*Intent, error) {
+	intents := make([]*Intent, 0, len(batch.Keys))
+	for _, key := range batch.Keys {
+		intent, err := q.store.GetIntent(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get intent %s: %w", key, err)
+		}
+		intents = append(intents, intent)
+	}
+	return intents, nil
+}
+
+func (q *Queue) resend(ctx context.Context, batch *Batch) error {
+	var ext tlb.ExternalMessage
+	if err := tlb.LoadFromCell(&ext, batch.External.BeginParse()); err != nil {
+		return fmt.Errorf("failed to parse external message: %w", err)
+	}
+	return q.api.SendExternalMessage(ctx, &ext)
+}
+
+func (q *Queue) getAccount(ctx context.Context) (*tlb.Account, error) {
+	block, err := q.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	acc, err := q.api.WaitForBlock(block.SeqNo).GetAccount(ctx, block, q.w.Address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	return acc, nil
+}
+
+// scanTransactions - returns wallet transactions after lt, in order from old to new
+func (q *Queue) scanTransactions(ctx context.Context, afterLT uint64) ([]*tlb.Transaction, error) {
+	block, err := q.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	api := q.api.WaitForBlock(block.SeqNo)
+	acc, err := api.GetAccount(ctx, block, q.w.Address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	var res []*tlb.Transaction
+	lastLT, lastHash := acc.LastTxLT, acc.LastTxHash
+	for lastLT > afterLT {
+		list, err := api.ListTransactions(ctx, q.w.Address(), 16, lastLT, lastHash)
+		if err != nil {
+			if errors.Is(err, ton.ErrNoTransactionsWereFound) {
+				break
+			}
+			return nil, fmt.Errorf("failed to list transactions: %w", err)
+		}
+
+		if len(list) == 0 {
+			break
+		}
+
+		// list is from old to new
+		for i := len(list) - 1; i >= 0; i-- {
+			if list[i].LT <= afterLT {
+				break
+			}
+			res = append(res, list[i])
+		}
+		lastLT, lastHash = list[0].PrevTxLT, list[0].PrevTxHash
+	}
+
+	// reverse to old to new order
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res, nil
+}
+
+func bodyHash(body *cell.Cell) []byte {
+	if body == nil {
+		return cell.BeginCell().EndCell().Hash()
+	}
+	return body.Hash()
+}
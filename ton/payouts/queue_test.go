@@ -0,0 +1,414 @@
+package payouts
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/ton/wallet"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+type chainMock struct {
+	ton.APIClientWrapped
+
+	addr *address.Address
+	txs  []*tlb.Transaction
+	sent []*tlb.ExternalMessage
+	mx   sync.Mutex
+}
+
+func (m *chainMock) WaitForBlock(seqno uint32) ton.APIClientWrapped {
+	return m
+}
+
+func (m *chainMock) CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error) {
+	return &ton.BlockIDExt{SeqNo: 1}, nil
+}
+
+func (m *chainMock) GetAccount(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	acc := &tlb.Account{
+		IsActive: true,
+		State: &tlb.AccountState{
+			IsValid: true,
+			Address: addr,
+			AccountStorage: tlb.AccountStorage{
+				Status: tlb.AccountStatusActive,
+			},
+		},
+	}
+
+	if len(m.txs) > 0 {
+		acc.LastTxLT = m.txs[len(m.txs)-1].LT
+		acc.LastTxHash = m.txs[len(m.txs)-1].Hash
+	}
+	return acc, nil
+}
+
+func (m *chainMock) ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	for i, tx := range m.txs {
+		if tx.LT == lt {
+			from := i + 1 - int(num)
+			if from < 0 {
+				from = 0
+			}
+			return m.txs[from : i+1], nil
+		}
+	}
+	return nil, ton.ErrNoTransactionsWereFound
+}
+
+func (m *chainMock) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *chainMock) addTx(in *tlb.Message, outs []*tlb.InternalMessage) *tlb.Transaction {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	tx := &tlb.Transaction{
+		LT:   uint64(len(m.txs)+1) * 1000,
+		Hash: big.NewInt(int64(len(m.txs) + 1)).FillBytes(make([]byte, 32)),
+	}
+	if len(m.txs) > 0 {
+		tx.PrevTxLT = m.txs[len(m.txs)-1].LT
+		tx.PrevTxHash = m.txs[len(m.txs)-1].Hash
+	}
+	tx.Description.Description = tlb.TransactionDescriptionOrdinary{}
+	tx.IO.In = in
+
+	if len(outs) > 0 {
+		dict := cell.NewDict(15)
+		for i, out := range outs {
+			out.SrcAddr = m.addr
+			c, err := tlb.ToCell(out)
+			if err != nil {
+				panic(err)
+			}
+			if err = dict.SetIntKey(big.NewInt(int64(i)), cell.BeginCell().MustStoreRef(c).EndCell()); err != nil {
+				panic(err)
+			}
+		}
+		tx.IO.Out = &tlb.MessagesList{List: dict}
+	}
+
+	m.txs = append(m.txs, tx)
+	return tx
+}
+
+func newHighloadWallet(t *testing.T, api *chainMock) *wallet.Wallet {
+	var queryID uint32
+	w, err := wallet.FromPrivateKey(api, ed25519.NewKeyFromSeed(make([]byte, 32)), wallet.ConfigHighloadV3{
+		MessageTTL: 120,
+		MessageBuilder: func(ctx context.Context, subWalletId uint32) (id uint32, createdAt int64, err error) {
+			queryID++
+			return queryID, timeNow().Unix() - 30, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.addr = w.Address()
+	return w
+}
+
+func TestQueue_Confirm(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	api := &chainMock{}
+	w := newHighloadWallet(t, api)
+	store := NewMemoryStore()
+
+	if _, err := NewQueue(api, w, store, Config{MessageTTL: time.Minute}); err == nil {
+		t.Fatal("ttl less than wallet ttl should not be accepted")
+	}
+
+	q, err := NewQueue(api, w, store, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.cfg.MessageTTL != 120*time.Second {
+		t.Fatal("ttl should be taken from wallet", q.cfg.MessageTTL)
+	}
+
+	ctx := context.Background()
+	dst := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	comment, _ := wallet.CreateCommentCell("order 1")
+
+	transfers := []Transfer{
+		{Key: "1", To: dst, Amount: tlb.MustFromTON("1"), Body: comment},
+		{Key: "2", To: dst, Amount: tlb.MustFromTON("2")},
+		{Key: "3", To: dst, Amount: tlb.MustFromTON("3")},
+	}
+	for _, tr := range transfers {
+		if _, err = q.Enqueue(ctx, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	intent, err := q.Enqueue(ctx, transfers[0])
+	if err != nil {
+		t.Fatal("duplicate should be idempotent:", err)
+	}
+	if intent.Status != IntentStatusPending {
+		t.Fatal("incorrect status")
+	}
+
+	if _, err = q.Enqueue(ctx, Transfer{Key: "1", To: dst, Amount: tlb.MustFromTON("5")}); !errors.Is(err, ErrIntentConflict) {
+		t.Fatal("should be conflict, but:", err)
+	}
+
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(api.sent) != 1 {
+		t.Fatal("batch should be sent")
+	}
+
+	intent, _ = q.Get(ctx, "2")
+	if intent.Status != IntentStatusSent || intent.BatchID == "" {
+		t.Fatal("intent should be in batch")
+	}
+
+	// nothing is processed yet, we should not send anything new
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.sent) != 1 {
+		t.Fatal("should not be resent so fast")
+	}
+
+	// emulate highload processing: external creates internal to itself, which sends messages
+	ext := api.sent[0]
+	var packed tlb.InternalMessage
+	if err = tlb.LoadFromCell(&packed, ext.Body.BeginParse().MustLoadRef().MustLoadRef()); err != nil {
+		t.Fatal(err)
+	}
+
+	api.addTx(&tlb.Message{MsgType: tlb.MsgTypeExternalIn, Msg: ext}, []*tlb.InternalMessage{&packed})
+
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	intent, _ = q.Get(ctx, "1")
+	if intent.Status != IntentStatusSent {
+		t.Fatal("should wait for internal transaction")
+	}
+
+	// only 2 of 3 messages were sent
+	api.addTx(&tlb.Message{MsgType: tlb.MsgTypeInternal, Msg: &packed}, []*tlb.InternalMessage{
+		{DstAddr: dst, Amount: tlb.MustFromTON("1"), Body: comment},
+		{DstAddr: dst, Amount: tlb.MustFromTON("3"), Body: cell.BeginCell().EndCell()},
+	})
+	sentTx := api.txs[len(api.txs)-1]
+
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, status := range map[string]IntentStatus{"1": IntentStatusConfirmed, "2": IntentStatusSent, "3": IntentStatusConfirmed} {
+		intent, _ = q.Get(ctx, key)
+		if intent.Status != status {
+			t.Fatal("incorrect status before expiration of", key, intent.Status)
+		}
+	}
+
+	// missing message could still be sent by next transactions of highload chain until batch is expired
+	now = now.Add(3*time.Minute + time.Second)
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.sent) != 1 {
+		t.Fatal("nothing should be sent")
+	}
+
+	for key, status := range map[string]IntentStatus{"1": IntentStatusConfirmed, "2": IntentStatusFailed, "3": IntentStatusConfirmed} {
+		intent, _ = q.Get(ctx, key)
+		if intent.Status != status {
+			t.Fatal("incorrect status of", key, intent.Status)
+		}
+
+		if status == IntentStatusConfirmed && (!bytes.Equal(intent.TxHash, sentTx.Hash) || intent.TxLT != sentTx.LT) {
+			t.Fatal("incorrect tx of", key)
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if intent, err = q.Wait(waitCtx, "2"); err != nil || intent.Error == "" {
+		t.Fatal("should be failed with reason", err)
+	}
+}
+
+func TestQueue_ExpireAndRestart(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	api := &chainMock{}
+	w := newHighloadWallet(t, api)
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	// unrelated transaction before the batch
+	api.addTx(nil, nil)
+
+	q, err := NewQueue(api, w, store, Config{MaxAttempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	if _, err = q.Enqueue(ctx, Transfer{Key: "a", To: dst, Amount: tlb.MustFromTON("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart
+	q, err = NewQueue(api, w, store, Config{MaxAttempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(api.sent) != 2 || !bytes.Equal(api.sent[0].Body.Hash(), api.sent[1].Body.Hash()) {
+		t.Fatal("the same message should be resent")
+	}
+
+	// expired, intent should go to the new batch
+	now = now.Add(3*time.Minute + time.Second)
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(api.sent) != 3 || bytes.Equal(api.sent[0].Body.Hash(), api.sent[2].Body.Hash()) {
+		t.Fatal("new message should be sent")
+	}
+
+	intent, _ := q.Get(ctx, "a")
+	if intent.Attempts != 1 || intent.Status != IntentStatusSent {
+		t.Fatal("incorrect intent state", intent.Attempts, intent.Status)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	intent, _ = q.Get(ctx, "a")
+	if intent.Status != IntentStatusFailed {
+		t.Fatal("intent should be failed after max attempts", intent.Status)
+	}
+
+	if len(api.sent) != 3 {
+		t.Fatal("nothing should be sent")
+	}
+}
+
+func TestQueue_ScanCursor(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	api := &chainMock{}
+	w := newHighloadWallet(t, api)
+	store := NewMemoryStore()
+	ctx := context.Background()
+	cfg := Config{MaxScanTransactions: 3}
+
+	q, err := NewQueue(api, w, store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	if _, err = q.Enqueue(ctx, Transfer{Key: "a", To: dst, Amount: tlb.MustFromTON("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// single message is sent by highload directly, without internal transfer
+	ext := api.sent[0]
+	var msg tlb.InternalMessage
+	if err = tlb.LoadFromCell(&msg, ext.Body.BeginParse().MustLoadRef().MustLoadRef()); err != nil {
+		t.Fatal(err)
+	}
+
+	// busy wallet, batch transaction is followed by many others
+	extTx := api.addTx(&tlb.Message{MsgType: tlb.MsgTypeExternalIn, Msg: ext}, []*tlb.InternalMessage{&msg})
+	for i := 0; i < 8; i++ {
+		api.addTx(nil, nil)
+	}
+
+	getBatch := func() *Batch {
+		list, err := store.ListSentBatches(ctx)
+		if err != nil || len(list) != 1 {
+			t.Fatal("batch should be in flight", err)
+		}
+		return list[0]
+	}
+
+	// 9 transactions after batch start, 3 per step, so it is found only on the third step
+	for i := 0; i < 2; i++ {
+		if err = q.Step(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if b := getBatch(); b.TxLT != 0 || b.ScanLT == 0 {
+			t.Fatal("search should be continued from cursor", i, b.TxLT, b.ScanLT)
+		}
+
+		// restart, search should be resumed from the saved cursor
+		if q, err = NewQueue(api, w, store, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = q.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	intent, _ := q.Get(ctx, "a")
+	if intent.Status != IntentStatusConfirmed || intent.TxLT != extTx.LT {
+		t.Fatal("intent should be confirmed", intent.Status)
+	}
+
+	if b := store.batches[intent.BatchID]; b.TxLT != extTx.LT || b.Status != BatchStatusResolved {
+		t.Fatal("batch transaction should be saved")
+	}
+}
@@ -0,0 +1,186 @@
+package payouts
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var (
+	ErrIntentExists   = errors.New("intent with this key already exists")
+	ErrIntentNotFound = errors.New("intent is not found")
+)
+
+type IntentStatus string
+
+const (
+	// IntentStatusPending - waiting to be included in batch
+	IntentStatusPending IntentStatus = "PENDING"
+	// IntentStatusSent - included in batch which was sent, but not yet resolved
+	IntentStatusSent IntentStatus = "SENT"
+	// IntentStatusConfirmed - message was sent by wallet, TxHash and TxLT are set
+	IntentStatusConfirmed IntentStatus = "CONFIRMED"
+	// IntentStatusFailed - message will never be sent, Error contains reason
+	IntentStatusFailed IntentStatus = "FAILED"
+)
+
+type BatchStatus string
+
+const (
+	BatchStatusSent     BatchStatus = "SENT"
+	BatchStatusResolved BatchStatus = "RESOLVED"
+)
+
+// Intent - transfer which should be done exactly once
+type Intent struct {
+	// Key - idempotency key, intents with the same key are considered the same
+	Key    string
+	To     *address.Address
+	Amount tlb.Coins
+	Bounce bool
+	Body   *cell.Cell
+
+	Status    IntentStatus
+	Attempts  int
+	BatchID   string
+	TxHash    []byte
+	TxLT      uint64
+	Error     string
+	CreatedAt time.Time
+}
+
+// Batch - external message sent by wallet, which contains messages of intents
+type Batch struct {
+	// ID - hex of InMsgHash
+	ID string
+	// InMsgHash - hash of the external message body, it is used to find transaction
+	InMsgHash []byte
+	// External - serialized external message, it is resent as is, until confirmed or expired
+	External *cell.Cell
+	Keys     []string
+	Status   BatchStatus
+	// StartLT - last transaction lt of the wallet before sending, used as a scan boundary,
+	// it is moved forward when transactions are checked, and to the batch transaction when it is found
+	StartLT uint64
+	// ScanLT, ScanHash - cursor of not finished search of the batch transaction,
+	// transactions from it down to StartLT are not checked yet
+	ScanLT   uint64
+	ScanHash []byte
+	// ScanTopLT - newest transaction of the not finished search, StartLT is moved to it when search reaches StartLT
+	ScanTopLT uint64
+	// TxLT - lt of the batch transaction, 0 when it is not found yet
+	TxLT uint64
+	// ExpireAt - time after which the message cannot be accepted by the wallet contract
+	ExpireAt time.Time
+	SentAt   time.Time
+}
+
+// Store - persistent storage of the queue, implementation should be safe for concurrent use.
+type Store interface {
+	// CreateIntent - saves new intent, ErrIntentExists should be returned if intent with the same key exists
+	CreateIntent(ctx context.Context, intent *Intent) error
+	// GetIntent - ErrIntentNotFound should be returned if intent is not exists
+	GetIntent(ctx context.Context, key string) (*Intent, error)
+	// ListPendingIntents - returns pending intents in order of creation
+	ListPendingIntents(ctx context.Context, limit int) ([]*Intent, error)
+	// SaveBatch - saves batch and updated intents atomically
+	SaveBatch(ctx context.Context, batch *Batch, intents []*Intent) error
+	// ListSentBatches - returns not yet resolved batches
+	ListSentBatches(ctx context.Context) ([]*Batch, error)
+}
+
+// MemoryStore - non persistent Store, for tests and as a reference implementation
+type MemoryStore struct {
+	intents map[string]Intent
+	batches map[string]Batch
+	mx      sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		intents: map[string]Intent{},
+		batches: map[string]Batch{},
+	}
+}
+
+func (s *MemoryStore) CreateIntent(_ context.Context, intent *Intent) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if _, ok := s.intents[intent.Key]; ok {
+		return ErrIntentExists
+	}
+	s.intents[intent.Key] = *intent
+	return nil
+}
+
+func (s *MemoryStore) GetIntent(_ context.Context, key string) (*Intent, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	intent, ok := s.intents[key]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	return &intent, nil
+}
+
+func (s *MemoryStore) ListPendingIntents(_ context.Context, limit int) ([]*Intent, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	var list []*Intent
+	for _, intent := range s.intents {
+		if intent.Status == IntentStatusPending {
+			intent := intent
+			list = append(list, &intent)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].Key < list[j].Key
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) SaveBatch(_ context.Context, batch *Batch, intents []*Intent) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.batches[batch.ID] = *batch
+	for _, intent := range intents {
+		s.intents[intent.Key] = *intent
+	}
+	return nil
+}
+
+func (s *MemoryStore) ListSentBatches(_ context.Context) ([]*Batch, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	var list []*Batch
+	for _, batch := range s.batches {
+		if batch.Status == BatchStatusSent {
+			batch := batch
+			list = append(list, &batch)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SentAt.Before(list[j].SentAt)
+	})
+	return list, nil
+}
@@ -336,14 +336,33 @@ func CheckForwardBlockProof(from, to *BlockIDExt, toKey bool, configProof, destP
 	return nil
 }
 
+// CheckBlockSignatures - verifies that block is signed by more than 2/3 (by weight) of its validators,
+// validators are computed from catchain config (param 28) and validator set (param 34).
+// For shard blocks validator subgroup of the shard is computed, set should be the current one for block catchain seqno.
+func CheckBlockSignatures(block *BlockIDExt, sigs *SignatureSet, catchainConfig tlb.CatchainConfig, validatorSet tlb.ValidatorSetAny) error {
+	var validators []*tlb.ValidatorAddr
+	var err error
+	if block.Workchain == address.MasterchainID {
+		validators, err = getMainValidators(block, catchainConfig, validatorSet, uint32(sigs.CatchainSeqno))
+	} else {
+		validators, err = getShardValidators(block, catchainConfig, validatorSet, uint32(sigs.CatchainSeqno))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get block validators: %w", err)
+	}
+
+	if err = checkBlockSignatures(block, sigs, validators); err != nil {
+		return fmt.Errorf("failed to check validators signatures: %w", err)
+	}
+	return nil
+}
+
 func getMainValidators(block *BlockIDExt, catConfig tlb.CatchainConfig, validatorConfig tlb.ValidatorSetAny, ccSeqno uint32) ([]*tlb.ValidatorAddr, error) {
 	if block.Workchain != address.MasterchainID {
 		return nil, fmt.Errorf("only masterchain blocks currently supported")
 	}
 
 	var shuffle = false
-	var validatorsNum int
-	var validatorsListDict *cell.Dictionary
 
 	switch t := catConfig.Config.(type) {
 	case tlb.CatchainConfigV1:
@@ -353,6 +372,44 @@ func getMainValidators(block *BlockIDExt, catConfig tlb.CatchainConfig, validato
 		return nil, fmt.Errorf("unknown validator set type")
 	}
 
+	list, validatorsNum, err := loadValidatorsList(validatorConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if validatorsNum > len(list) {
+		validatorsNum = len(list)
+	}
+
+	var validators = make([]*tlb.ValidatorAddr, validatorsNum)
+	if shuffle {
+		prng := NewValidatorSetPRNG(block.Shard, block.Workchain, ccSeqno, nil)
+
+		idx := make([]uint32, validatorsNum)
+		for i := 0; i < validatorsNum; i++ {
+			j := prng.NextRanged(uint64(i) + 1)
+			idx[i] = idx[j]
+			idx[j] = uint32(i)
+		}
+
+		for i := 0; i < validatorsNum; i++ {
+			validators[i] = list[idx[i]]
+		}
+
+		return validators, nil
+	}
+
+	for i := 0; i < validatorsNum; i++ {
+		validators[i] = list[i]
+	}
+
+	return validators, nil
+}
+
+// loadValidatorsList - returns validators sorted by their index in set, and number of masterchain validators
+func loadValidatorsList(validatorConfig tlb.ValidatorSetAny) ([]*tlb.ValidatorAddr, int, error) {
+	var validatorsNum int
+	var validatorsListDict *cell.Dictionary
 	var definedWeight *uint64
 	switch t := validatorConfig.Validators.(type) {
 	case tlb.ValidatorSet:
@@ -363,7 +420,7 @@ func getMainValidators(block *BlockIDExt, catConfig tlb.CatchainConfig, validato
 		validatorsNum = int(t.Main)
 		validatorsListDict = t.List
 	default:
-		return nil, fmt.Errorf("unknown validator set type")
+		return nil, 0, fmt.Errorf("unknown validator set type")
 	}
 
 	type validatorWithKey struct {
@@ -373,7 +430,7 @@ func getMainValidators(block *BlockIDExt, catConfig tlb.CatchainConfig, validato
 
 	kvs, err := validatorsListDict.LoadAll()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load validators list dict: %w", err)
+		return nil, 0, fmt.Errorf("failed to load validators list dict: %w", err)
 	}
 
 	var totalWeight uint64
@@ -381,12 +438,12 @@ func getMainValidators(block *BlockIDExt, catConfig tlb.CatchainConfig, validato
 	for i, kv := range kvs {
 		var val tlb.ValidatorAddr
 		if err := tlb.LoadFromCell(&val, kv.Value); err != nil {
-			return nil, fmt.Errorf("failed to parse validator addr: %w", err)
+			return nil, 0, fmt.Errorf("failed to parse validator addr: %w", err)
 		}
 
 		key, err := kv.Key.LoadUInt(16)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse validator key: %w", err)
+			return nil, 0, fmt.Errorf("failed to parse validator key: %w", err)
 		}
 
 		totalWeight += val.Weight
@@ -395,43 +452,93 @@ func getMainValidators(block *BlockIDExt, catConfig tlb.CatchainConfig, validato
 	}
 
 	if definedWeight != nil && totalWeight != *definedWeight {
-		return nil, fmt.Errorf("incorrect sum of weights")
+		return nil, 0, fmt.Errorf("incorrect sum of weights")
 	}
 
 	if len(validatorsKeys) == 0 {
-		return nil, fmt.Errorf("zero validators")
+		return nil, 0, fmt.Errorf("zero validators")
 	}
 
 	sort.Slice(validatorsKeys, func(i, j int) bool {
 		return validatorsKeys[i].key < validatorsKeys[j].key
 	})
 
-	if validatorsNum > len(validatorsKeys) {
-		validatorsNum = len(validatorsKeys)
+	list := make([]*tlb.ValidatorAddr, len(validatorsKeys))
+	for i, v := range validatorsKeys {
+		list[i] = v.addr
 	}
+	return list, validatorsNum, nil
+}
 
-	var validators = make([]*tlb.ValidatorAddr, validatorsNum)
-	if shuffle {
-		prng := NewValidatorSetPRNG(block.Shard, block.Workchain, ccSeqno, nil)
+// getShardValidators - computes validator subgroup of the shard, like validator does it:
+// validators are selected pseudo-randomly by weight without repetition, and all have weight 1 in subgroup
+func getShardValidators(block *BlockIDExt, catConfig tlb.CatchainConfig, validatorConfig tlb.ValidatorSetAny, ccSeqno uint32) ([]*tlb.ValidatorAddr, error) {
+	var count int
+	switch t := catConfig.Config.(type) {
+	case tlb.CatchainConfigV1:
+		count = int(t.ShardValidatorsNum)
+	case tlb.CatchainConfigV2:
+		count = int(t.ShardValidatorsNum)
+	default:
+		return nil, fmt.Errorf("unknown catchain config type")
+	}
 
-		idx := make([]uint32, validatorsNum)
-		for i := 0; i < validatorsNum; i++ {
-			j := prng.NextRanged(uint64(i) + 1)
-			idx[i] = idx[j]
-			idx[j] = uint32(i)
-		}
+	list, _, err := loadValidatorsList(validatorConfig)
+	if err != nil {
+		return nil, err
+	}
 
-		for i := 0; i < validatorsNum; i++ {
-			validators[i] = validatorsKeys[idx[i]].addr
-		}
+	if count > len(list) {
+		count = len(list)
+	}
 
-		return validators, nil
+	cumWeights := make([]uint64, len(list))
+	var totalWeight uint64
+	for i, v := range list {
+		cumWeights[i] = totalWeight
+		totalWeight += v.Weight
 	}
 
-	for i := 0; i < validatorsNum; i++ {
-		validators[i] = validatorsKeys[i].addr
+	type hole struct {
+		from, weight uint64
 	}
+	var holes []hole
+
+	prng := NewValidatorSetPRNG(block.Shard, block.Workchain, ccSeqno, nil)
+	validators := make([]*tlb.ValidatorAddr, 0, count)
+	for i := 0; i < count; i++ {
+		if totalWeight == 0 {
+			return nil, fmt.Errorf("zero validators weight")
+		}
 
+		// skip weights of already selected validators
+		p := prng.NextRanged(totalWeight)
+		for _, h := range holes {
+			if p < h.from {
+				break
+			}
+			p += h.weight
+		}
+
+		n := sort.Search(len(cumWeights), func(j int) bool {
+			return cumWeights[j] > p
+		}) - 1
+		v := list[n]
+
+		validators = append(validators, &tlb.ValidatorAddr{
+			PublicKey: v.PublicKey,
+			Weight:    1,
+			ADNLAddr:  v.ADNLAddr,
+		})
+		totalWeight -= v.Weight
+
+		at := sort.Search(len(holes), func(j int) bool {
+			return holes[j].from > cumWeights[n]
+		})
+		holes = append(holes, hole{})
+		copy(holes[at+1:], holes[at:])
+		holes[at] = hole{from: cumWeights[n], weight: v.Weight}
+	}
 	return validators, nil
 }
 
@@ -0,0 +1,104 @@
+package ton
+
+import (
+	"crypto/ed25519"
+	"math/big"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/adnl"
+	"github.com/chaindead/tonutils-go/tl"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+func TestCheckBlockSignatures_Shard(t *testing.T) {
+	keys := map[string]ed25519.PrivateKey{}
+	list := cell.NewDict(16)
+	for i := 0; i < 10; i++ {
+		seed := make([]byte, 32)
+		seed[0] = byte(i + 1)
+		key := ed25519.NewKeyFromSeed(seed)
+		pub := key.Public().(ed25519.PublicKey)
+		keys[string(pub)] = key
+
+		v, err := tlb.ToCell(tlb.ValidatorAddr{
+			PublicKey: tlb.SigPubKeyED25519{Key: pub},
+			Weight:    uint64(i+1) * 1000,
+			ADNLAddr:  make([]byte, 32),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = list.SetIntKey(big.NewInt(int64(i)), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set := tlb.ValidatorSetAny{Validators: tlb.ValidatorSet{Total: 10, Main: 5, List: list}}
+	catchain := tlb.CatchainConfig{Config: tlb.CatchainConfigV2{ShuffleMcValidators: true, ShardValidatorsNum: 4}}
+	block := &BlockIDExt{
+		Workchain: 0,
+		Shard:     -0x8000000000000000,
+		SeqNo:     100,
+		RootHash:  make([]byte, 32),
+		FileHash:  make([]byte, 32),
+	}
+
+	validators, err := getShardValidators(block, catchain, set, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(validators) != 4 {
+		t.Fatal("incorrect subgroup size", len(validators))
+	}
+
+	unique := map[string]bool{}
+	for _, v := range validators {
+		if v.Weight != 1 {
+			t.Fatal("shard validators should have weight 1")
+		}
+		unique[string(v.PublicKey.Key)] = true
+	}
+	if len(unique) != 4 {
+		t.Fatal("validators should not repeat")
+	}
+
+	again, _ := getShardValidators(block, catchain, set, 7)
+	for i := range again {
+		if string(again[i].PublicKey.Key) != string(validators[i].PublicKey.Key) {
+			t.Fatal("subgroup should be deterministic")
+		}
+	}
+
+	hash, err := calcValidatorSetHash(7, validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toSign, err := tl.Serialize(BlockID{RootHash: block.RootHash, FileHash: block.FileHash}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigs := &SignatureSet{ValidatorSetHash: int32(hash), CatchainSeqno: 7}
+	for _, v := range validators[:3] {
+		id, err := tl.Hash(adnl.PublicKeyED25519{Key: v.PublicKey.Key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs.Signatures = append(sigs.Signatures, Signature{
+			NodeIDShort: id,
+			Signature:   ed25519.Sign(keys[string(v.PublicKey.Key)], toSign),
+		})
+	}
+
+	if err = CheckBlockSignatures(block, sigs, catchain, set); err != nil {
+		t.Fatal(err)
+	}
+
+	sigs.Signatures = sigs.Signatures[:2]
+	if err = CheckBlockSignatures(block, sigs, catchain, set); err == nil {
+		t.Fatal("2 of 4 signatures should not be enough")
+	}
+}
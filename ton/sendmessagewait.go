@@ -12,24 +12,38 @@ import (
 
 var ErrTxWasNotConfirmed = errors.New("transaction was not confirmed in a given deadline, but it may still be confirmed later")
 
+// TransactionWaiterAPI - methods which are used to track transaction of the sent external message
+type TransactionWaiterAPI interface {
+	Client() LiteClient
+	CurrentMasterchainInfo(ctx context.Context) (*BlockIDExt, error)
+	WaitForBlock(seqno uint32) APIClientWrapped
+}
+
 func (c *APIClient) SendExternalMessageWaitTransaction(ctx context.Context, ext *tlb.ExternalMessage) (*tlb.Transaction, *BlockIDExt, []byte, error) {
-	block, err := c.CurrentMasterchainInfo(ctx)
+	return SendExternalMessageWaitTransactionWith(ctx, c, ext, c.SendExternalMessage)
+}
+
+// SendExternalMessageWaitTransactionWith - sends external message using send function and waits for its transaction,
+// account is polled through api, and message is sent again using send when it was not processed in the next block.
+// It can be used to deliver messages in alternative way, for example directly to validators through overlays.
+func SendExternalMessageWaitTransactionWith(ctx context.Context, api TransactionWaiterAPI, ext *tlb.ExternalMessage, send func(ctx context.Context, ext *tlb.ExternalMessage) error) (*tlb.Transaction, *BlockIDExt, []byte, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to get block: %w", err)
 	}
 
-	acc, err := c.WaitForBlock(block.SeqNo).GetAccount(ctx, block, ext.DstAddr)
+	acc, err := api.WaitForBlock(block.SeqNo).GetAccount(ctx, block, ext.DstAddr)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to get account state: %w", err)
 	}
 
 	inMsgHash := ext.Body.Hash()
 
-	if err = c.SendExternalMessage(ctx, ext); err != nil {
+	if err = send(ctx, ext); err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to send message: %w", err)
 	}
 
-	tx, block, err := c.waitConfirmation(ctx, block, acc, ext)
+	tx, block, err := waitConfirmation(ctx, api, block, acc, ext, send)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -37,7 +51,7 @@ func (c *APIClient) SendExternalMessageWaitTransaction(ctx context.Context, ext
 	return tx, block, inMsgHash, nil
 }
 
-func (c *APIClient) waitConfirmation(ctx context.Context, block *BlockIDExt, acc *tlb.Account, ext *tlb.ExternalMessage) (*tlb.Transaction, *BlockIDExt, error) {
+func waitConfirmation(ctx context.Context, api TransactionWaiterAPI, block *BlockIDExt, acc *tlb.Account, ext *tlb.ExternalMessage, send func(ctx context.Context, ext *tlb.ExternalMessage) error) (*tlb.Transaction, *BlockIDExt, error) {
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
 		// fallback timeout to not stuck forever with background context
 		var cancel context.CancelFunc
@@ -46,15 +60,15 @@ func (c *APIClient) waitConfirmation(ctx context.Context, block *BlockIDExt, acc
 	}
 	till, _ := ctx.Deadline()
 
-	ctx = c.Client().StickyContext(ctx)
+	ctx = api.Client().StickyContext(ctx)
 
 	for time.Now().Before(till) {
-		blockNew, err := c.WaitForBlock(block.SeqNo + 1).GetMasterchainInfo(ctx)
+		blockNew, err := api.WaitForBlock(block.SeqNo + 1).GetMasterchainInfo(ctx)
 		if err != nil {
 			continue
 		}
 
-		accNew, err := c.WaitForBlock(blockNew.SeqNo).GetAccount(ctx, blockNew, ext.DstAddr)
+		accNew, err := api.WaitForBlock(blockNew.SeqNo).GetAccount(ctx, blockNew, ext.DstAddr)
 		if err != nil {
 			continue
 		}
@@ -62,7 +76,7 @@ func (c *APIClient) waitConfirmation(ctx context.Context, block *BlockIDExt, acc
 
 		if accNew.LastTxLT == acc.LastTxLT {
 			// if not in block, maybe LS lost our message, send it again
-			if err = c.SendExternalMessage(ctx, ext); err != nil {
+			if err = send(ctx, ext); err != nil {
 				continue
 			}
 
@@ -75,7 +89,7 @@ func (c *APIClient) waitConfirmation(ctx context.Context, block *BlockIDExt, acc
 		// to prevent this we will scan till we reach last seen offset.
 		for time.Now().Before(till) {
 			// we try to get last 5 transactions, and check if we have our new there.
-			txList, err := c.WaitForBlock(block.SeqNo).ListTransactions(ctx, ext.DstAddr, 5, lastLt, lastHash)
+			txList, err := api.WaitForBlock(block.SeqNo).ListTransactions(ctx, ext.DstAddr, 5, lastLt, lastHash)
 			if err != nil {
 				continue
 			}
@@ -43,12 +43,16 @@ func GetWalletVersion(account *tlb.Account) Version {
 		}
 	}
 
+	if v, ok := customVersionByCode(account.Code); ok {
+		return v
+	}
+
 	return Unknown
 }
 
 func GetStateInit(pubKey ed25519.PublicKey, version VersionConfig, subWallet uint32) (*tlb.StateInit, error) {
 	var ver Version
-	switch v := version.(type) {
+	switch v := resolveCustomVersion(version).(type) {
 	case Version:
 		ver = v
 		switch ver {
@@ -126,6 +130,11 @@ func GetStateInit(pubKey ed25519.PublicKey, version VersionConfig, subWallet uin
 			MustStoreSlice(pubKey, 256).
 			MustStoreDict(nil). // old queries
 			EndCell()
+	case PreprocessedV2:
+		data = cell.BeginCell().
+			MustStoreSlice(pubKey, 256).
+			MustStoreUInt(0, 16). // seqno
+			EndCell()
 	case HighloadV3:
 		timeout := version.(ConfigHighloadV3).MessageTTL
 		if timeout >= 1<<22 {
@@ -0,0 +1,175 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// DefaultBIP44Path - derivation path used by multi-chain wallets (Trust, Ledger-like) for TON, coin type 607
+const DefaultBIP44Path = "m/44'/607'/0'"
+
+const (
+	_BIP39Iterations = 2048
+	_BIP39Salt       = "mnemonic"
+	_SLIP10Curve     = "ed25519 seed"
+)
+
+var ErrInvalidBIP39Mnemonic = errors.New("invalid bip39 mnemonic")
+
+// BIP39 english wordlist is sorted, and it is the same as TON one, so index of the word is its position in sorted list
+var bip39Words = func() []string {
+	wa := make([]string, 0, len(words))
+	for w := range words {
+		wa = append(wa, w)
+	}
+	sort.Strings(wa)
+	return wa
+}()
+
+var bip39Index = func() map[string]int {
+	idx := make(map[string]int, len(bip39Words))
+	for i, w := range bip39Words {
+		idx[w] = i
+	}
+	return idx
+}()
+
+// NewBIP39Mnemonic - generates new bip39 mnemonic, words num should be 12, 15, 18, 21 or 24
+func NewBIP39Mnemonic(wordsNum int) ([]string, error) {
+	if wordsNum < 12 || wordsNum > 24 || wordsNum%3 != 0 {
+		return nil, fmt.Errorf("incorrect words num")
+	}
+
+	entropy := make([]byte, wordsNum*11*32/33/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, fmt.Errorf("rand gen err: %w", err)
+	}
+
+	checksumBits := uint(len(entropy) / 4)
+	sum := sha256.Sum256(entropy)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, checksumBits)
+	data.Or(data, big.NewInt(int64(sum[0]>>(8-checksumBits))))
+
+	mnemonic := make([]string, wordsNum)
+	mask := big.NewInt(1<<11 - 1)
+	for i := wordsNum - 1; i >= 0; i-- {
+		mnemonic[i] = bip39Words[new(big.Int).And(data, mask).Int64()]
+		data.Rsh(data, 11)
+	}
+	return mnemonic, nil
+}
+
+// ValidateBIP39Mnemonic - checks words and checksum of the mnemonic
+func ValidateBIP39Mnemonic(mnemonic []string) error {
+	if len(mnemonic) < 12 || len(mnemonic) > 24 || len(mnemonic)%3 != 0 {
+		return fmt.Errorf("%w: incorrect words num %d", ErrInvalidBIP39Mnemonic, len(mnemonic))
+	}
+
+	data := new(big.Int)
+	for _, w := range mnemonic {
+		idx, ok := bip39Index[w]
+		if !ok {
+			return fmt.Errorf("%w: unknown word '%s'", ErrInvalidBIP39Mnemonic, w)
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(int64(idx)))
+	}
+
+	checksumBits := uint(len(mnemonic) * 11 / 33)
+	checksum := new(big.Int).And(data, big.NewInt(1<<checksumBits-1)).Uint64()
+
+	entropy := data.Rsh(data, checksumBits).FillBytes(make([]byte, len(mnemonic)*11*32/33/8))
+	sum := sha256.Sum256(entropy)
+
+	if uint64(sum[0]>>(8-checksumBits)) != checksum {
+		return fmt.Errorf("%w: incorrect checksum", ErrInvalidBIP39Mnemonic)
+	}
+	return nil
+}
+
+// BIP39Seed - validates mnemonic and returns 64 bytes seed for it.
+// Passphrase is used as is, it should be NFKD normalized if it contains non ascii characters.
+func BIP39Seed(mnemonic []string, passphrase string) ([]byte, error) {
+	if err := ValidateBIP39Mnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	return pbkdf2.Key([]byte(strings.Join(mnemonic, " ")), []byte(_BIP39Salt+passphrase), _BIP39Iterations, 64, sha512.New), nil
+}
+
+// DeriveED25519Key - derives ed25519 private key from seed using SLIP-0010,
+// only hardened derivation is supported for ed25519, so each path index should be marked with ' or h.
+func DeriveED25519Key(seed []byte, path string) (ed25519.PrivateKey, error) {
+	indexes, err := parseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte(_SLIP10Curve))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+
+	for _, index := range indexes {
+		data := make([]byte, 1+32+4)
+		copy(data[1:], key)
+		binary.BigEndian.PutUint32(data[33:], index)
+
+		mac = hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum = mac.Sum(nil)
+		key, chainCode = sum[:32], sum[32:]
+	}
+
+	return ed25519.NewKeyFromSeed(key), nil
+}
+
+func parseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path should start with m")
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		if !strings.HasSuffix(p, "'") && !strings.HasSuffix(p, "h") && !strings.HasSuffix(p, "H") {
+			return nil, fmt.Errorf("only hardened derivation is supported for ed25519, index '%s' is not hardened", p)
+		}
+
+		idx, err := strconv.ParseUint(p[:len(p)-1], 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect path index '%s': %w", p, err)
+		}
+		indexes = append(indexes, uint32(idx)|0x80000000)
+	}
+	return indexes, nil
+}
+
+// FromBIP39Mnemonic - initializes wallet from bip39 mnemonic, key is derived by path using SLIP-0010,
+// DefaultBIP44Path can be used for most of the multi-chain wallets.
+func FromBIP39Mnemonic(api TonAPI, mnemonic []string, passphrase, path string, version VersionConfig) (*Wallet, error) {
+	seed, err := BIP39Seed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := DeriveED25519Key(seed, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	return FromPrivateKey(api, key, version)
+}
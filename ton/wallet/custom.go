@@ -1,8 +1,12 @@
 package wallet
 
 import (
+	"bytes"
 	"context"
 	"crypto/ed25519"
+	"fmt"
+	"sync"
+
 	"github.com/chaindead/tonutils-go/tlb"
 	"github.com/chaindead/tonutils-go/tvm/cell"
 )
@@ -15,3 +19,97 @@ type ConfigCustom interface {
 type MessageBuilder interface {
 	BuildMessage(ctx context.Context, messages []*Message) (*cell.Cell, error)
 }
+
+// CustomVersion - describes wallet contract which is not built in the library,
+// it can be registered using RegisterCustomVersion and then used as a regular Version.
+type CustomVersion struct {
+	// Name is returned by Version.String
+	Name string
+	// Code is used to detect version in GetWalletVersion
+	Code *cell.Cell
+	// Config is used to build state init and message builder for the wallet
+	Config ConfigCustom
+}
+
+var (
+	customVersions   = map[Version]CustomVersion{}
+	customVersionsMx sync.RWMutex
+)
+
+// RegisterCustomVersion - registers wallet contract under the given version number,
+// after registration, version can be passed to FromPrivateKey, FromSeed, AddressFromPubKey and others,
+// the same way as built-in versions.
+func RegisterCustomVersion(ver Version, custom CustomVersion) error {
+	if custom.Config == nil {
+		return fmt.Errorf("config should be set")
+	}
+	if custom.Code == nil {
+		return fmt.Errorf("code should be set")
+	}
+	if ver == Unknown {
+		return fmt.Errorf("version should not be zero")
+	}
+	if _, ok := walletCodeHex[ver]; ok {
+		return fmt.Errorf("version %d is already used by built-in wallet", ver)
+	}
+
+	customVersionsMx.Lock()
+	defer customVersionsMx.Unlock()
+
+	if _, ok := customVersions[ver]; ok {
+		return fmt.Errorf("version %d is already registered", ver)
+	}
+	customVersions[ver] = custom
+	return nil
+}
+
+// UnregisterCustomVersion - removes version registered using RegisterCustomVersion
+func UnregisterCustomVersion(ver Version) {
+	customVersionsMx.Lock()
+	defer customVersionsMx.Unlock()
+
+	delete(customVersions, ver)
+}
+
+func resolveCustomVersion(version VersionConfig) VersionConfig {
+	ver, ok := version.(Version)
+	if !ok {
+		return version
+	}
+
+	customVersionsMx.RLock()
+	defer customVersionsMx.RUnlock()
+
+	if custom, ok := customVersions[ver]; ok {
+		return custom.Config
+	}
+	return version
+}
+
+func customVersionName(ver Version) (string, bool) {
+	customVersionsMx.RLock()
+	defer customVersionsMx.RUnlock()
+
+	custom, ok := customVersions[ver]
+	if !ok || custom.Name == "" {
+		return "", false
+	}
+	return custom.Name, true
+}
+
+func customVersionByCode(code *cell.Cell) (Version, bool) {
+	if code == nil {
+		return Unknown, false
+	}
+
+	customVersionsMx.RLock()
+	defer customVersionsMx.RUnlock()
+
+	hash := code.Hash()
+	for ver, custom := range customVersions {
+		if bytes.Equal(custom.Code.Hash(), hash) {
+			return ver, true
+		}
+	}
+	return Unknown, false
+}
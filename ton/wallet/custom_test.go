@@ -173,3 +173,73 @@ func TestConfigCustom_V3BocTx(t *testing.T) {
 		t.Error("orig and custom ext boc msg mismatch")
 	}
 }
+
+func TestRegisterCustomVersion(t *testing.T) {
+	const ver Version = 1001
+
+	code := walletCode[V5R1Final]
+	if err := RegisterCustomVersion(ver, CustomVersion{
+		Name:   "custom v5",
+		Code:   code,
+		Config: newConfigCustomV5R1(code),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterCustomVersion(ver)
+
+	if err := RegisterCustomVersion(ver, CustomVersion{Code: code, Config: newConfigCustomV5R1(code)}); err == nil {
+		t.Fatal("should fail on duplicate version")
+	}
+	if err := RegisterCustomVersion(V4R2, CustomVersion{Code: code, Config: newConfigCustomV5R1(code)}); err == nil {
+		t.Fatal("should fail on built-in version")
+	}
+
+	if ver.String() != "custom v5" {
+		t.Fatal("incorrect name", ver.String())
+	}
+
+	pkey := ed25519.NewKeyFromSeed([]byte("12345678901234567890123456789012"))
+	w, err := FromPrivateKey(nil, pkey, ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := w.GetSpec().(*customSpecV5R1); !ok {
+		t.Fatal("incorrect spec")
+	}
+
+	addr, err := AddressFromPubKey(pkey.Public().(ed25519.PublicKey), ver, DefaultSubwallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !addr.Equals(w.Address()) {
+		t.Fatal("address mismatch")
+	}
+
+	acc := &tlb.Account{
+		IsActive: true,
+		State: &tlb.AccountState{
+			AccountStorage: tlb.AccountStorage{Status: tlb.AccountStatusActive},
+		},
+		Code: cell.BeginCell().MustStoreUInt(777, 32).EndCell(),
+	}
+	if GetWalletVersion(acc) != Unknown {
+		t.Fatal("should be unknown")
+	}
+
+	if err = RegisterCustomVersion(ver+1, CustomVersion{Code: acc.Code, Config: newConfigCustomV5R1(acc.Code)}); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterCustomVersion(ver + 1)
+
+	if GetWalletVersion(acc) != ver+1 {
+		t.Fatal("should be detected as custom")
+	}
+
+	// built-in versions have priority on detection
+	acc.Code = walletCode[V5R1Final]
+	if GetWalletVersion(acc) != V5R1Final {
+		t.Fatal("should be v5")
+	}
+}
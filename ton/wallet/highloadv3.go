@@ -17,7 +17,8 @@ type ConfigHighloadV3 struct {
 	MessageTTL uint32
 
 	// This function wil be used to get query id and creation time for the new message.
-	// ID can be iterator from your database, max id is 1<<23, when it is higher, start from 0 and repeat
+	// ID can be iterator from your database, max id is 1<<23, when it is higher, start from 0 and repeat,
+	// or you can use Allocate method of HighloadQueryManager, which does it for you.
 	// MessageBuilder should be defined if you want to send transactions
 	MessageBuilder func(ctx context.Context, subWalletId uint32) (id uint32, createdAt int64, err error)
 }
@@ -28,6 +29,17 @@ type SpecHighloadV3 struct {
 	config ConfigHighloadV3
 }
 
+// SetMessageBuilder - replaces MessageBuilder from config, can be used for wallets opened with Open,
+// or together with HighloadQueryManager
+func (s *SpecHighloadV3) SetMessageBuilder(builder func(ctx context.Context, subWalletId uint32) (id uint32, createdAt int64, err error)) {
+	s.config.MessageBuilder = builder
+}
+
+// GetMessageTTL - returns ttl of the messages in seconds, from config
+func (s *SpecHighloadV3) GetMessageTTL() uint32 {
+	return s.config.MessageTTL
+}
+
 func (s *SpecHighloadV3) BuildMessage(ctx context.Context, messages []*Message) (_ *cell.Cell, err error) {
 	if s.config.MessageBuilder == nil {
 		return nil, errors.New("query fetcher is not defined in spec config")
@@ -0,0 +1,256 @@
+package wallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/ton"
+)
+
+const (
+	highloadQueryBitNumberSize = 10
+	highloadQueryMaxBitNumber  = 1022
+	highloadQueryMaxShift      = 8191
+
+	// how many processed ids we can skip in a single allocation
+	highloadQueryMaxSkip = 64
+)
+
+var (
+	ErrHighloadQueryIDOverflow  = errors.New("highload query id overflow")
+	ErrHighloadQueryIDsNotReady = errors.New("all highload query ids of the cycle are used, and previous cycle is not expired yet")
+)
+
+// HighloadQueryID - query id of highload v3 wallet,
+// contract stores processed queries as bit Number in dictionary by Shift.
+type HighloadQueryID struct {
+	Shift     uint16 // 13 bits
+	BitNumber uint16 // 10 bits
+}
+
+func HighloadQueryIDFromUint(id uint32) HighloadQueryID {
+	return HighloadQueryID{
+		Shift:     uint16(id >> highloadQueryBitNumberSize),
+		BitNumber: uint16(id & (1<<highloadQueryBitNumberSize - 1)),
+	}
+}
+
+func (q HighloadQueryID) Uint() uint32 {
+	return uint32(q.Shift)<<highloadQueryBitNumberSize | uint32(q.BitNumber)
+}
+
+// Next - returns next query id, last one (max shift and max bit number) is left for emergency cases,
+// ErrHighloadQueryIDOverflow is returned when there are no more ids.
+func (q HighloadQueryID) Next() (HighloadQueryID, error) {
+	bit, shift := q.BitNumber+1, q.Shift
+	if bit > highloadQueryMaxBitNumber {
+		bit = 0
+		shift++
+	}
+
+	if shift > highloadQueryMaxShift || (shift == highloadQueryMaxShift && bit >= highloadQueryMaxBitNumber) {
+		return HighloadQueryID{}, ErrHighloadQueryIDOverflow
+	}
+	return HighloadQueryID{Shift: shift, BitNumber: bit}, nil
+}
+
+// HighloadQueryState - allocation state of the highload v3 wallet, which is persisted in HighloadQueryStore
+type HighloadQueryState struct {
+	// LastQueryID - last allocated query id
+	LastQueryID uint32
+	// LastCreatedAt - creation time of the last allocated query
+	LastCreatedAt int64
+	// PrevCycleEndedAt - creation time of the last query of the previous cycle,
+	// ids of the current cycle can be used only when it is expired.
+	// Zero for the first cycle.
+	PrevCycleEndedAt int64
+}
+
+type HighloadQueryStore interface {
+	// GetHighloadQueryState - should return nil state without error when wallet has no allocations yet
+	GetHighloadQueryState(ctx context.Context, addr *address.Address) (*HighloadQueryState, error)
+	SetHighloadQueryState(ctx context.Context, addr *address.Address, state *HighloadQueryState) error
+}
+
+// HighloadQueryMemoryStore - in memory HighloadQueryStore, state is lost on restart,
+// so use it only for tests or together with long enough pause before start (> ttl).
+type HighloadQueryMemoryStore struct {
+	states map[string]HighloadQueryState
+	mx     sync.RWMutex
+}
+
+func NewHighloadQueryMemoryStore() *HighloadQueryMemoryStore {
+	return &HighloadQueryMemoryStore{
+		states: map[string]HighloadQueryState{},
+	}
+}
+
+func (s *HighloadQueryMemoryStore) GetHighloadQueryState(_ context.Context, addr *address.Address) (*HighloadQueryState, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	st, ok := s.states[addr.String()]
+	if !ok {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func (s *HighloadQueryMemoryStore) SetHighloadQueryState(_ context.Context, addr *address.Address, state *HighloadQueryState) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.states[addr.String()] = *state
+	return nil
+}
+
+// HighloadQueryManager - allocates query ids and creation time for highload v3 messages.
+// Ids are allocated sequentially, and after the last one, allocation starts from 0 again,
+// when all messages of the previous cycle are expired. Each allocation is persisted to the store
+// before it is returned, and id is checked using contract's 'processed?' get method, already processed ids are skipped.
+// Use its Allocate method as MessageBuilder of ConfigHighloadV3.
+type HighloadQueryManager struct {
+	api     TonAPI
+	pubKey  ed25519.PublicKey
+	version VersionConfig
+	ttl     uint32
+	store   HighloadQueryStore
+
+	// createdAt is set to now - lag, because liteservers emulate externals with block time,
+	// and message from the future will be rejected
+	lag time.Duration
+
+	mx sync.Mutex
+}
+
+// NewHighloadQueryManager - creates manager for highload v3 wallet and all its subwallets,
+// addresses of subwallets are used as keys in the store.
+func NewHighloadQueryManager(w *Wallet, store HighloadQueryStore) (*HighloadQueryManager, error) {
+	cfg, ok := w.ver.(ConfigHighloadV3)
+	if !ok {
+		return nil, fmt.Errorf("wallet is not highload v3")
+	}
+
+	if cfg.MessageTTL <= 5 || cfg.MessageTTL >= 1<<22 {
+		return nil, fmt.Errorf("incorrect ttl")
+	}
+
+	lag := 30 * time.Second
+	if half := time.Duration(cfg.MessageTTL) * time.Second / 2; half < lag {
+		lag = half
+	}
+
+	return &HighloadQueryManager{
+		api:     w.api,
+		pubKey:  w.key.Public().(ed25519.PublicKey),
+		version: w.ver,
+		ttl:     cfg.MessageTTL,
+		store:   store,
+		lag:     lag,
+	}, nil
+}
+
+// SetCreatedAtLag - sets how much creation time of the message should be less than current time, default is min(30s, ttl/2)
+func (m *HighloadQueryManager) SetCreatedAtLag(lag time.Duration) {
+	m.lag = lag
+}
+
+// Allocate - returns next unused query id and creation time for the subwallet
+func (m *HighloadQueryManager) Allocate(ctx context.Context, subWalletId uint32) (id uint32, createdAt int64, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	addr, err := AddressFromPubKey(m.pubKey, m.version, subWalletId)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to calc subwallet address: %w", err)
+	}
+
+	state, err := m.store.GetHighloadQueryState(ctx, addr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load query state: %w", err)
+	}
+
+	now := timeNow().Unix()
+	createdAt = now - int64(m.lag/time.Second)
+
+	var next HighloadQueryState
+	var qid HighloadQueryID
+	if state != nil {
+		if createdAt < state.LastCreatedAt {
+			// clock went back, we keep creation time monotonic
+			createdAt = state.LastCreatedAt
+		}
+
+		next.PrevCycleEndedAt = state.PrevCycleEndedAt
+		qid, err = HighloadQueryIDFromUint(state.LastQueryID).Next()
+		if err != nil {
+			if !errors.Is(err, ErrHighloadQueryIDOverflow) {
+				return 0, 0, err
+			}
+			// start new cycle
+			qid = HighloadQueryID{}
+			next.PrevCycleEndedAt = state.LastCreatedAt
+		}
+	}
+
+	if next.PrevCycleEndedAt != 0 && now-int64(m.ttl) <= next.PrevCycleEndedAt {
+		// messages of the previous cycle with the same ids can still be accepted by contract
+		return 0, 0, ErrHighloadQueryIDsNotReady
+	}
+
+	for i := 0; ; i++ {
+		processed, err := m.isProcessed(ctx, addr, qid.Uint())
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to check query id: %w", err)
+		}
+
+		if !processed {
+			break
+		}
+
+		if i >= highloadQueryMaxSkip {
+			return 0, 0, fmt.Errorf("too many processed query ids in a row, state in store seems outdated")
+		}
+
+		if qid, err = qid.Next(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	next.LastQueryID = qid.Uint()
+	next.LastCreatedAt = createdAt
+
+	if err = m.store.SetHighloadQueryState(ctx, addr, &next); err != nil {
+		return 0, 0, fmt.Errorf("failed to save query state: %w", err)
+	}
+
+	return next.LastQueryID, createdAt, nil
+}
+
+func (m *HighloadQueryManager) isProcessed(ctx context.Context, addr *address.Address, queryID uint32) (bool, error) {
+	block, err := m.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	// need_clean = true, to consider cleanup of old queries which will happen on the next message
+	res, err := m.api.WaitForBlock(block.SeqNo).RunGetMethod(ctx, block, addr, "processed?", uint64(queryID), -1)
+	if err != nil {
+		var cErr ton.ContractExecError
+		if errors.As(err, &cErr) && cErr.Code == ton.ErrCodeContractNotInitialized {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to run processed? method: %w", err)
+	}
+
+	processed, err := res.Int(0)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse result: %w", err)
+	}
+	return processed.Sign() != 0, nil
+}
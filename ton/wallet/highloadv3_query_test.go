@@ -0,0 +1,160 @@
+package wallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+)
+
+func TestHighloadQueryID_Next(t *testing.T) {
+	q := HighloadQueryID{Shift: 5, BitNumber: 1022}
+	if HighloadQueryIDFromUint(q.Uint()) != q {
+		t.Fatal("incorrect conversion")
+	}
+
+	next, err := q.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next != (HighloadQueryID{Shift: 6, BitNumber: 0}) {
+		t.Fatal("incorrect next", next)
+	}
+
+	next, err = HighloadQueryID{Shift: 8191, BitNumber: 1020}.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.Uint() >= 1<<23 {
+		t.Fatal("too big id")
+	}
+
+	if _, err = next.Next(); !errors.Is(err, ErrHighloadQueryIDOverflow) {
+		t.Fatal("should overflow, but:", err)
+	}
+}
+
+func TestHighloadQueryManager_Allocate(t *testing.T) {
+	now := int64(1000000)
+	timeNow = func() time.Time {
+		return time.Unix(now, 0)
+	}
+
+	processed := map[uint64]bool{}
+
+	m := &MockAPI{}
+	m.getBlockInfo = func(ctx context.Context) (*ton.BlockIDExt, error) {
+		return &ton.BlockIDExt{SeqNo: 2}, nil
+	}
+	m.runGetMethod = func(ctx context.Context, blockInfo *ton.BlockIDExt, addr *address.Address, method string, params ...interface{}) (*ton.ExecutionResult, error) {
+		if method != "processed?" {
+			t.Fatal("unexpected method", method)
+		}
+
+		if processed[params[0].(uint64)] {
+			return ton.NewExecutionResult([]any{big.NewInt(-1)}), nil
+		}
+		return ton.NewExecutionResult([]any{big.NewInt(0)}), nil
+	}
+
+	pkey := ed25519.NewKeyFromSeed([]byte("12345678901234567890123456789012"))
+	w, err := FromPrivateKey(m, pkey, ConfigHighloadV3{MessageTTL: 120})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewHighloadQueryMemoryStore()
+	mgr, err := NewHighloadQueryManager(w, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, createdAt, err := mgr.Allocate(context.Background(), w.GetSubwalletID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 0 || createdAt != now-30 {
+		t.Fatal("incorrect first allocation", id, createdAt)
+	}
+
+	processed[1] = true
+	processed[2] = true
+
+	id, _, err = mgr.Allocate(context.Background(), w.GetSubwalletID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 3 {
+		t.Fatal("processed ids should be skipped", id)
+	}
+
+	// other subwallet has its own state
+	id, _, err = mgr.Allocate(context.Background(), w.GetSubwalletID()+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 0 {
+		t.Fatal("incorrect subwallet allocation", id)
+	}
+
+	// state should be restored from store by the new manager
+	mgr, err = NewHighloadQueryManager(w, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.GetSpec().(*SpecHighloadV3).SetMessageBuilder(mgr.Allocate)
+
+	ext, err := w.PrepareExternalMessageForMany(context.Background(), false, []*Message{
+		SimpleMessage(w.WalletAddress(), tlb.MustFromTON("0.1"), nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := ext.Body.BeginParse().MustLoadRef()
+	payload.MustLoadUInt(32)
+	payload.MustLoadRef()
+	payload.MustLoadUInt(8)
+	if payload.MustLoadUInt(23) != 4 {
+		t.Fatal("incorrect restored allocation")
+	}
+
+	// last id of the cycle
+	if err = store.SetHighloadQueryState(context.Background(), w.Address(), &HighloadQueryState{
+		LastQueryID:   HighloadQueryID{Shift: 8191, BitNumber: 1021}.Uint(),
+		LastCreatedAt: now - 30,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = mgr.Allocate(context.Background(), w.GetSubwalletID()); !errors.Is(err, ErrHighloadQueryIDsNotReady) {
+		t.Fatal("should be not ready, but:", err)
+	}
+
+	now += 120
+	id, _, err = mgr.Allocate(context.Background(), w.GetSubwalletID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 0 {
+		t.Fatal("new cycle should start from 0", id)
+	}
+
+	st, _ := store.GetHighloadQueryState(context.Background(), w.Address())
+	if st.PrevCycleEndedAt != now-120-30 {
+		t.Fatal("incorrect prev cycle end", st.PrevCycleEndedAt)
+	}
+}
@@ -0,0 +1,293 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+var (
+	ErrWalletNotInitialized = errors.New("wallet is not initialized")
+	ErrPublicKeyMismatch    = errors.New("public key in wallet data is not matches the signer")
+)
+
+// DetectedWallet - parameters of the deployed wallet, recovered from its code and data
+type DetectedWallet struct {
+	// Version can be used as version config for FromPrivateKey,
+	// it is Version, ConfigV5R1Beta, ConfigV5R1Final or ConfigHighloadV3
+	Version   VersionConfig
+	Subwallet uint32
+	PublicKey ed25519.PublicKey
+}
+
+// Open - fetches deployed wallet, detects its version, config and subwallet, and
+// returns Wallet ready to use with the given key.
+// For highload v3, query ids are allocated by HighloadQueryManager with in-memory store,
+// use SetMessageBuilder of the wallet spec to replace it with a persistent one.
+func Open(ctx context.Context, api TonAPI, addr *address.Address, key ed25519.PrivateKey) (*Wallet, error) {
+	det, err := DetectWallet(ctx, api, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(det.PublicKey, key.Public().(ed25519.PublicKey)) {
+		return nil, ErrPublicKeyMismatch
+	}
+
+	w, err := FromPrivateKey(api, key, det.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init wallet: %w", err)
+	}
+
+	if w.subwallet != det.Subwallet {
+		if w, err = w.GetSubwallet(det.Subwallet); err != nil {
+			return nil, fmt.Errorf("failed to init subwallet: %w", err)
+		}
+	}
+
+	if spec, ok := w.GetSpec().(*SpecHighloadV3); ok {
+		mgr, err := NewHighloadQueryManager(w, NewHighloadQueryMemoryStore())
+		if err != nil {
+			return nil, fmt.Errorf("failed to init highload query manager: %w", err)
+		}
+		spec.SetMessageBuilder(mgr.Allocate)
+	}
+	return w, nil
+}
+
+// DetectWallet - fetches account and recovers wallet parameters from its state
+func DetectWallet(ctx context.Context, api TonAPI, addr *address.Address) (*DetectedWallet, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	acc, err := api.WaitForBlock(block.SeqNo).GetAccount(ctx, block, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account state: %w", err)
+	}
+
+	if !acc.IsActive || acc.State.Status != tlb.AccountStatusActive {
+		return nil, ErrWalletNotInitialized
+	}
+
+	return DetectWalletFromAccount(addr, acc)
+}
+
+// DetectWalletFromAccount - recovers wallet parameters from already fetched account state
+func DetectWalletFromAccount(addr *address.Address, acc *tlb.Account) (*DetectedWallet, error) {
+	ver := GetWalletVersion(acc)
+	if ver == Unknown {
+		return nil, fmt.Errorf("unknown wallet code: %w", ErrUnsupportedWalletVersion)
+	}
+
+	if acc.Data == nil {
+		return nil, fmt.Errorf("wallet has no data")
+	}
+
+	det, err := parseWalletData(addr, ver, acc.Data.BeginParse())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s wallet data: %w", ver.String(), err)
+	}
+
+	// we verify that parameters are correct by comparing the address
+	calcAddr, err := AddressFromPubKey(det.PublicKey, det.Version, det.Subwallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calc address: %w", err)
+	}
+
+	if !calcAddr.Equals(addr) {
+		return nil, fmt.Errorf("wallet was deployed with non-standard initial data, cannot recover config")
+	}
+	return det, nil
+}
+
+func parseWalletData(addr *address.Address, ver Version, data *cell.Slice) (*DetectedWallet, error) {
+	det := &DetectedWallet{
+		Version: ver,
+	}
+
+	switch ver {
+	case V3R1, V3R2, V4R1, V4R2:
+		if _, err := data.LoadUInt(32); err != nil {
+			return nil, fmt.Errorf("failed to load seqno: %w", err)
+		}
+
+		sub, err := data.LoadUInt(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subwallet: %w", err)
+		}
+		det.Subwallet = uint32(sub)
+
+		if det.PublicKey, err = data.LoadSlice(256); err != nil {
+			return nil, fmt.Errorf("failed to load public key: %w", err)
+		}
+	case V5R1Beta:
+		if _, err := data.LoadUInt(33); err != nil {
+			return nil, fmt.Errorf("failed to load seqno: %w", err)
+		}
+
+		networkID, err := data.LoadInt(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load network id: %w", err)
+		}
+
+		wc, err := data.LoadInt(8)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load workchain: %w", err)
+		}
+
+		if _, err = data.LoadUInt(8); err != nil {
+			return nil, fmt.Errorf("failed to load version: %w", err)
+		}
+
+		sub, err := data.LoadUInt(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subwallet: %w", err)
+		}
+		det.Subwallet = uint32(sub)
+
+		if det.PublicKey, err = data.LoadSlice(256); err != nil {
+			return nil, fmt.Errorf("failed to load public key: %w", err)
+		}
+
+		det.Version = ConfigV5R1Beta{
+			NetworkGlobalID: int32(networkID),
+			Workchain:       int8(wc),
+		}
+	case V5R1Final:
+		if _, err := data.LoadBoolBit(); err != nil {
+			return nil, fmt.Errorf("failed to load signature flag: %w", err)
+		}
+
+		if _, err := data.LoadUInt(32); err != nil {
+			return nil, fmt.Errorf("failed to load seqno: %w", err)
+		}
+
+		walletID, err := data.LoadUInt(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load wallet id: %w", err)
+		}
+
+		pubKey, err := data.LoadSlice(256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public key: %w", err)
+		}
+		det.PublicKey = pubKey
+
+		// network id is xored with wallet id, so we try known networks,
+		// and select one which gives the same address. Serialized id is the same
+		// for some pairs of network and subwallet, such configs are equivalent for the contract,
+		// so we prefer one with the smaller subwallet number.
+		found := false
+		for _, networkID := range []int32{MainnetGlobalID, TestnetGlobalID} {
+			id, ok := ParseV5R1ID(uint32(walletID), networkID)
+			if !ok || id.WalletVersion != 0 || id.WorkChain != int8(addr.Workchain()) {
+				continue
+			}
+
+			if found && uint32(id.SubwalletNumber) >= det.Subwallet {
+				continue
+			}
+
+			cfg := ConfigV5R1Final{
+				NetworkGlobalID: id.NetworkGlobalID,
+				Workchain:       id.WorkChain,
+			}
+
+			calcAddr, err := AddressFromPubKey(pubKey, cfg, uint32(id.SubwalletNumber))
+			if err != nil {
+				return nil, fmt.Errorf("failed to calc address: %w", err)
+			}
+
+			if bytes.Equal(calcAddr.Data(), addr.Data()) {
+				det.Version = cfg
+				det.Subwallet = uint32(id.SubwalletNumber)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("wallet id is not matches any known network")
+		}
+	case HighloadV2R2, HighloadV2Verified:
+		sub, err := data.LoadUInt(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subwallet: %w", err)
+		}
+		det.Subwallet = uint32(sub)
+
+		if _, err = data.LoadUInt(64); err != nil {
+			return nil, fmt.Errorf("failed to load last cleaned: %w", err)
+		}
+
+		if det.PublicKey, err = data.LoadSlice(256); err != nil {
+			return nil, fmt.Errorf("failed to load public key: %w", err)
+		}
+	case HighloadV3:
+		pubKey, err := data.LoadSlice(256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public key: %w", err)
+		}
+		det.PublicKey = pubKey
+
+		sub, err := data.LoadUInt(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subwallet: %w", err)
+		}
+		det.Subwallet = uint32(sub)
+
+		if _, err = data.LoadMaybeRef(); err != nil {
+			return nil, fmt.Errorf("failed to load old queries: %w", err)
+		}
+
+		if _, err = data.LoadMaybeRef(); err != nil {
+			return nil, fmt.Errorf("failed to load queries: %w", err)
+		}
+
+		if _, err = data.LoadUInt(64); err != nil {
+			return nil, fmt.Errorf("failed to load last clean time: %w", err)
+		}
+
+		timeout, err := data.LoadUInt(22)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timeout: %w", err)
+		}
+
+		det.Version = ConfigHighloadV3{
+			MessageTTL: uint32(timeout),
+		}
+	case PreprocessedV2:
+		pubKey, err := data.LoadSlice(256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public key: %w", err)
+		}
+		det.PublicKey = pubKey
+	default:
+		return nil, ErrUnsupportedWalletVersion
+	}
+
+	return det, nil
+}
+
+// ParseV5R1ID - reverses V5R1ID.Serialized for the given network id,
+// returns false if wallet id is not a client context id in this network.
+func ParseV5R1ID(walletID uint32, networkGlobalID int32) (V5R1ID, bool) {
+	ctx := walletID ^ uint32(networkGlobalID)
+	if ctx>>31 != 1 {
+		return V5R1ID{}, false
+	}
+
+	return V5R1ID{
+		NetworkGlobalID: networkGlobalID,
+		WorkChain:       int8(uint8(ctx >> 23)),
+		WalletVersion:   uint8(ctx >> 15),
+		SubwalletNumber: uint16(ctx & 0x7FFF),
+	}, true
+}
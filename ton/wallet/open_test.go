@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/chaindead/tonutils-go/address"
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+)
+
+func TestOpen(t *testing.T) {
+	pkey := ed25519.NewKeyFromSeed([]byte("12345678901234567890123456789012"))
+	pub := pkey.Public().(ed25519.PublicKey)
+
+	tests := []struct {
+		name      string
+		version   VersionConfig
+		subwallet uint32
+	}{
+		{"v3r2", V3R2, 55},
+		{"v4r2", V4R2, DefaultSubwallet + 1},
+		{"v5beta", ConfigV5R1Beta{NetworkGlobalID: TestnetGlobalID}, 7},
+		{"v5 mainnet", ConfigV5R1Final{NetworkGlobalID: MainnetGlobalID}, 0},
+		{"v5 testnet", ConfigV5R1Final{NetworkGlobalID: TestnetGlobalID}, 3},
+		{"highload v2", HighloadV2Verified, 12},
+		{"highload v3", ConfigHighloadV3{MessageTTL: 120}, 8},
+		{"preprocessed v2", PreprocessedV2, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, err := GetStateInit(pub, test.version, test.subwallet)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			addr, err := AddressFromPubKey(pub, test.version, test.subwallet)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := &MockAPI{}
+			m.getBlockInfo = func(ctx context.Context) (*ton.BlockIDExt, error) {
+				return &ton.BlockIDExt{SeqNo: 2}, nil
+			}
+			m.getAccount = func(ctx context.Context, block *ton.BlockIDExt, a *address.Address) (*tlb.Account, error) {
+				if !a.Equals(addr) {
+					t.Fatal("incorrect address requested")
+				}
+
+				return &tlb.Account{
+					IsActive: true,
+					State: &tlb.AccountState{
+						IsValid: true,
+						Address: a,
+						AccountStorage: tlb.AccountStorage{
+							Status: tlb.AccountStatusActive,
+						},
+					},
+					Code: state.Code,
+					Data: state.Data,
+				}, nil
+			}
+
+			w, err := Open(context.Background(), m, addr, pkey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !w.Address().Equals(addr) {
+				t.Fatal("address mismatch")
+			}
+
+			if w.GetSubwalletID() != test.subwallet {
+				t.Fatal("subwallet mismatch", w.GetSubwalletID())
+			}
+
+			if cfg, ok := test.version.(ConfigHighloadV3); ok {
+				if w.ver.(ConfigHighloadV3).MessageTTL != cfg.MessageTTL {
+					t.Fatal("ttl mismatch")
+				}
+				if w.GetSpec().(*SpecHighloadV3).config.MessageBuilder == nil {
+					t.Fatal("message builder should be set")
+				}
+			} else if w.ver != test.version {
+				t.Fatal("version mismatch", w.ver)
+			}
+
+			_, anotherKey, _ := ed25519.GenerateKey(nil)
+			if _, err = Open(context.Background(), m, addr, anotherKey); !errors.Is(err, ErrPublicKeyMismatch) {
+				t.Fatal("should be key mismatch error, but:", err)
+			}
+		})
+	}
+}
+
+func TestOpen_NotInitialized(t *testing.T) {
+	m := &MockAPI{}
+	m.getBlockInfo = func(ctx context.Context) (*ton.BlockIDExt, error) {
+		return &ton.BlockIDExt{SeqNo: 2}, nil
+	}
+	m.getAccount = func(ctx context.Context, block *ton.BlockIDExt, a *address.Address) (*tlb.Account, error) {
+		return &tlb.Account{IsActive: false}, nil
+	}
+
+	pkey := ed25519.NewKeyFromSeed([]byte("12345678901234567890123456789012"))
+	_, err := Open(context.Background(), m, address.MustParseAddr("EQCvoBT5Keb46oUhI_DpX0WXFDdX9ZyxXBfX3FC9cZa90nQP"), pkey)
+	if !errors.Is(err, ErrWalletNotInitialized) {
+		t.Fatal("should be not initialized error, but:", err)
+	}
+}
+
+func TestParseV5R1ID(t *testing.T) {
+	id := V5R1ID{
+		NetworkGlobalID: TestnetGlobalID,
+		WorkChain:       -1,
+		SubwalletNumber: 0x7ABC,
+		WalletVersion:   0,
+	}
+
+	parsed, ok := ParseV5R1ID(id.Serialized(), TestnetGlobalID)
+	if !ok {
+		t.Fatal("should be parsed")
+	}
+
+	if parsed != id {
+		t.Fatal("incorrect parsed id", parsed)
+	}
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chaindead/tonutils-go/tlb"
+	"github.com/chaindead/tonutils-go/ton"
+	"github.com/chaindead/tonutils-go/tvm/cell"
+)
+
+// https://github.com/pyAndr3w/ton-preprocessed-wallet-v2
+const _PreprocessedV2CodeHex = "B5EE9C7241010101003D000076FF00DDD40120F90001D0D33FD30FD74CED44D0D3FFD70B0F20A4830FA90822C8CBFFCB0FC9ED5444301046BAF2A1F823BEF2A2F910F2A3F800ED552E766412"
+
+// SpecPreprocessedV2 - gas optimized wallet, it has no get methods and no subwallet id,
+// contract just verifies signature and seqno, and sets received action list as is.
+// Storage: pub_key:bits256 seq_no:uint16
+type SpecPreprocessedV2 struct {
+	SpecRegular
+	SpecSeqno
+}
+
+func (s *SpecPreprocessedV2) BuildMessage(ctx context.Context, _ bool, _ *ton.BlockIDExt, messages []*Message) (_ *cell.Cell, err error) {
+	if len(messages) > 255 {
+		return nil, errors.New("for this type of wallet max 255 messages can be sent in the same time")
+	}
+
+	seq, err := s.seqnoFetcher(ctx, s.wallet.subwallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch seqno: %w", err)
+	}
+
+	actions, err := packOutList(messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build actions: %w", err)
+	}
+
+	payload := cell.BeginCell().
+		MustStoreUInt(uint64(timeNow().Add(time.Duration(s.messagesTTL)*time.Second).UTC().Unix()), 64). // valid until
+		MustStoreUInt(uint64(seq), 16).
+		MustStoreRef(actions).
+		EndCell()
+
+	return cell.BeginCell().
+		MustStoreSlice(payload.Sign(s.wallet.key), 512).
+		MustStoreRef(payload).
+		EndCell(), nil
+}
+
+// preprocessedV2SeqnoFetcher - contract has no seqno get method, so we read it from the data directly
+func preprocessedV2SeqnoFetcher(w *Wallet) func(ctx context.Context, subWallet uint32) (uint32, error) {
+	return func(ctx context.Context, _ uint32) (uint32, error) {
+		block, err := w.api.CurrentMasterchainInfo(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get block: %w", err)
+		}
+
+		acc, err := w.api.WaitForBlock(block.SeqNo).GetAccount(ctx, block, w.addr)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get account state: %w", err)
+		}
+
+		if !acc.IsActive || acc.State.Status != tlb.AccountStatusActive || acc.Data == nil {
+			return 0, nil
+		}
+
+		data := acc.Data.BeginParse()
+		if _, err = data.LoadSlice(256); err != nil {
+			return 0, fmt.Errorf("failed to load public key: %w", err)
+		}
+
+		seq, err := data.LoadUInt(16)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load seqno: %w", err)
+		}
+		return uint32(seq), nil
+	}
+}
+
+// packOutList - packs messages to the standard OutList, as it is expected in c5 register
+func packOutList(messages []*Message) (*cell.Cell, error) {
+	var list = cell.BeginCell().EndCell()
+	for i, message := range messages {
+		if message.InternalMessage == nil {
+			return nil, fmt.Errorf("internal message %d cannot be nil", i)
+		}
+
+		outMsg, err := tlb.ToCell(message.InternalMessage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert internal message %d to cell: %w", i, err)
+		}
+
+		/*
+			out_list_empty$_ = OutList 0;
+			out_list$_ {n:#} prev:^(OutList n) action:OutAction
+			  = OutList (n + 1);
+			action_send_msg#0ec3c86d mode:(## 8)
+			  out_msg:^(MessageRelaxed Any) = OutAction;
+		*/
+		list = cell.BeginCell().MustStoreRef(list).
+			MustStoreUInt(0x0ec3c86d, 32).
+			MustStoreUInt(uint64(message.Mode), 8).
+			MustStoreRef(outMsg).
+			EndCell()
+	}
+	return list, nil
+}
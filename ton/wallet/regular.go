@@ -24,6 +24,11 @@ func (s *SpecRegular) SetMessagesTTL(ttl uint32) {
 	s.messagesTTL = ttl
 }
 
+// GetMessagesTTL - returns ttl of the messages in seconds
+func (s *SpecRegular) GetMessagesTTL() uint32 {
+	return s.messagesTTL
+}
+
 type SpecSeqno struct {
 	// Instead of calling contract 'seqno' method,
 	// this function wil be used (if not nil) to get seqno for new transaction.
@@ -1,6 +1,9 @@
 package wallet
 
 import (
+	"encoding/hex"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -45,3 +48,79 @@ func TestNewSeedWithPassword(t *testing.T) {
 		t.Fatal("should be invalid 5", seedNoPass)
 	}
 }
+
+func TestBIP39Seed(t *testing.T) {
+	mnemonic := strings.Split("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", " ")
+
+	seed, err := BIP39Seed(mnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hex.EncodeToString(seed) != "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04" {
+		t.Fatal("incorrect seed", hex.EncodeToString(seed))
+	}
+
+	mnemonic[11] = "abandon"
+	if err = ValidateBIP39Mnemonic(mnemonic); !errors.Is(err, ErrInvalidBIP39Mnemonic) {
+		t.Fatal("checksum should be invalid, but:", err)
+	}
+
+	for _, num := range []int{12, 15, 18, 21, 24} {
+		m, err := NewBIP39Mnemonic(num)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m) != num {
+			t.Fatal("incorrect words num")
+		}
+
+		if err = ValidateBIP39Mnemonic(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeriveED25519Key(t *testing.T) {
+	// SLIP-0010 test vector 1 for ed25519
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	for path, key := range map[string]string{
+		"m":          "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+		"m/0'":       "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+		"m/0H/1H":    "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2",
+		"m/0'/1'/2h": "92a5b23c0b8a99e37d07df3fb9966917f5d06e02ddbd909c7e184371463e9fc9",
+	} {
+		k, err := DeriveED25519Key(seed, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hex.EncodeToString(k.Seed()) != key {
+			t.Fatal("incorrect key for", path, hex.EncodeToString(k.Seed()))
+		}
+	}
+
+	if _, err := DeriveED25519Key(seed, "m/44'/607'/0"); err == nil {
+		t.Fatal("not hardened should fail")
+	}
+}
+
+func TestFromBIP39Mnemonic(t *testing.T) {
+	mnemonic, err := NewBIP39Mnemonic(24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := FromBIP39Mnemonic(nil, mnemonic, "", DefaultBIP44Path, V4R2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed, _ := BIP39Seed(mnemonic, "")
+	key, _ := DeriveED25519Key(seed, DefaultBIP44Path)
+	if !w.PrivateKey().Equal(key) {
+		t.Fatal("incorrect key")
+	}
+}
@@ -46,6 +46,7 @@ const (
 	HighloadV2R2       Version = 122
 	HighloadV2Verified Version = 123
 	HighloadV3         Version = 300
+	PreprocessedV2     Version = 400
 	Lockup             Version = 200
 	Unknown            Version = 0
 )
@@ -70,6 +71,12 @@ func (v Version) String() string {
 		return fmt.Sprintf("highload V2R2 verified")
 	case HighloadV3:
 		return fmt.Sprintf("highload V3")
+	case PreprocessedV2:
+		return fmt.Sprintf("preprocessed V2")
+	}
+
+	if name, ok := customVersionName(v); ok {
+		return name
 	}
 
 	if v/100 == 2 {
@@ -90,8 +97,9 @@ var (
 		V5R1Beta:     _V5R1BetaCodeHex,
 		V5R1Final:    _V5R1FinalCodeHex,
 		HighloadV2R2: _HighloadV2R2CodeHex, HighloadV2Verified: _HighloadV2VerifiedCodeHex,
-		HighloadV3: _HighloadV3CodeHex,
-		Lockup:     _LockupCodeHex,
+		HighloadV3:     _HighloadV3CodeHex,
+		PreprocessedV2: _PreprocessedV2CodeHex,
+		Lockup:         _LockupCodeHex,
 	}
 	walletCodeBOC = map[Version][]byte{}
 	walletCode    = map[Version]*cell.Cell{}
@@ -138,6 +146,12 @@ type TonAPI interface {
 	FindLastTransactionByOutMsgHash(ctx context.Context, addr *address.Address, msgHash []byte, maxTxNumToScan ...int) (*tlb.Transaction, error)
 }
 
+// MessageSender - alternative way to deliver external messages to the network,
+// for example node.MessageBroadcaster which sends them directly to validators through overlays
+type MessageSender interface {
+	SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error
+}
+
 type Message struct {
 	Mode            uint8
 	InternalMessage *tlb.InternalMessage
@@ -155,16 +169,27 @@ type Wallet struct {
 
 	// Stores a pointer to implementation of the version related functionality
 	spec any
+
+	// Optional sender of external messages, when not set, messages are sent through api
+	sender MessageSender
 }
 
 func FromPrivateKey(api TonAPI, key ed25519.PrivateKey, version VersionConfig) (*Wallet, error) {
 	var subwallet uint32 = DefaultSubwallet
 
+	// versions registered with RegisterCustomVersion are processed as their configs
+	version = resolveCustomVersion(version)
+
 	// default subwallet depends on wallet type
 	switch version.(type) {
 	case ConfigV5R1Beta:
 	case ConfigV5R1Final:
 		subwallet = 0
+	case Version:
+		if version == PreprocessedV2 {
+			// has no subwallet in data
+			subwallet = 0
+		}
 	}
 
 	addr, err := AddressFromPubKey(key.Public().(ed25519.PublicKey), version, subwallet)
@@ -237,6 +262,8 @@ func getSpec(w *Wallet) (any, error) {
 			return &SpecV4R2{regular, SpecSeqno{seqnoFetcher: seqnoFetcher}}, nil
 		case HighloadV2R2, HighloadV2Verified:
 			return &SpecHighloadV2R2{regular, SpecQuery{}}, nil
+		case PreprocessedV2:
+			return &SpecPreprocessedV2{regular, SpecSeqno{seqnoFetcher: preprocessedV2SeqnoFetcher(w)}}, nil
 		case HighloadV3:
 			return nil, fmt.Errorf("use ConfigHighloadV3 for highload v3 spec")
 		case V5R1Beta:
@@ -281,6 +308,7 @@ func (w *Wallet) GetSubwallet(subwallet uint32) (*Wallet, error) {
 		addr:      addr,
 		ver:       w.ver,
 		subwallet: subwallet,
+		sender:    w.sender,
 	}
 
 	sub.spec, err = getSpec(sub)
@@ -308,6 +336,12 @@ func (w *Wallet) GetBalance(ctx context.Context, block *ton.BlockIDExt) (tlb.Coi
 	return acc.State.Balance, nil
 }
 
+// SetMessageSender - sets alternative sender of external messages, nil resets it to api.
+// When confirmation is awaited, message is sent and resent only through sender, api is used to track its transaction.
+func (w *Wallet) SetMessageSender(sender MessageSender) {
+	w.sender = sender
+}
+
 func (w *Wallet) GetSpec() any {
 	return w.spec
 }
@@ -358,7 +392,7 @@ func (w *Wallet) PrepareExternalMessageForMany(ctx context.Context, withStateIni
 		}
 
 		switch v {
-		case V3R2, V3R1, V4R2, V4R1, V5R1Beta, V5R1Final:
+		case V3R2, V3R1, V4R2, V4R1, V5R1Beta, V5R1Final, PreprocessedV2:
 			msg, err = w.spec.(RegularBuilder).BuildMessage(ctx, !withStateInit, nil, messages)
 			if err != nil {
 				return nil, fmt.Errorf("build message err: %w", err)
@@ -493,9 +527,19 @@ func (w *Wallet) sendMany(ctx context.Context, messages []*Message, waitConfirma
 	}
 
 	if len(waitConfirmation) > 0 && waitConfirmation[0] {
+		if w.sender != nil {
+			return ton.SendExternalMessageWaitTransactionWith(ctx, w.api, ext, w.sender.SendExternalMessage)
+		}
 		return w.api.SendExternalMessageWaitTransaction(ctx, ext)
 	}
 
+	if w.sender != nil {
+		if err = w.sender.SendExternalMessage(ctx, ext); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to send message: %w", err)
+		}
+		return nil, nil, ext.Body.Hash(), nil
+	}
+
 	if err = w.api.SendExternalMessage(ctx, ext); err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to send message: %w", err)
 	}
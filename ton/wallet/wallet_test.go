@@ -28,6 +28,7 @@ type MockAPI struct {
 	listTransactions        func(ctx context.Context, addr *address.Address, limit uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error)
 
 	extMsgSent *tlb.ExternalMessage
+	client     ton.LiteClient
 }
 
 func (m MockAPI) SendExternalMessageWaitTransaction(ctx context.Context, ext *tlb.ExternalMessage) (*tlb.Transaction, *ton.BlockIDExt, []byte, error) {
@@ -56,10 +57,21 @@ func (m MockAPI) WaitForBlock(seqno uint32) ton.APIClientWrapped {
 }
 
 func (m MockAPI) Client() ton.LiteClient {
+	if m.client != nil {
+		return m.client
+	}
 	//TODO implement me
 	panic("implement me")
 }
 
+type liteClientMock struct {
+	ton.LiteClient
+}
+
+func (l liteClientMock) StickyContext(ctx context.Context) context.Context {
+	return ctx
+}
+
 func (m MockAPI) WaitNextMasterBlock(ctx context.Context, master *ton.BlockIDExt) (*ton.BlockIDExt, error) {
 	//TODO implement me
 	panic("implement me")
@@ -607,6 +619,113 @@ func (w WaiterMock) GetTransaction(ctx context.Context, block *ton.BlockIDExt, a
 	return w.MGetTransaction(ctx, block, addr, lt)
 }
 
+type senderMock struct {
+	sent []*tlb.ExternalMessage
+}
+
+func (s *senderMock) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestWallet_SetMessageSender(t *testing.T) {
+	pkey := ed25519.NewKeyFromSeed([]byte("12345678901234567890123456789012"))
+
+	var apiSent int
+	m := &MockAPI{
+		getBlockInfo: func(ctx context.Context) (*ton.BlockIDExt, error) {
+			return &ton.BlockIDExt{SeqNo: 2}, nil
+		},
+		getAccount: func(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error) {
+			return &tlb.Account{IsActive: true, State: &tlb.AccountState{IsValid: true, Address: addr,
+				AccountStorage: tlb.AccountStorage{Status: tlb.AccountStatusActive}}}, nil
+		},
+		runGetMethod: func(ctx context.Context, blockInfo *ton.BlockIDExt, addr *address.Address, method string, params ...interface{}) (*ton.ExecutionResult, error) {
+			return ton.NewExecutionResult([]any{big.NewInt(3)}), nil
+		},
+		sendExternalMessage: func(ctx context.Context, msg *tlb.ExternalMessage) error {
+			apiSent++
+			return nil
+		},
+	}
+
+	w, err := FromPrivateKey(m, pkey, V4R2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := &senderMock{}
+	w.SetMessageSender(sender)
+
+	if err = w.Send(context.Background(), &Message{
+		Mode:            PayGasSeparately,
+		InternalMessage: &tlb.InternalMessage{DstAddr: w.Address(), Amount: tlb.MustFromTON("0.1"), Body: cell.BeginCell().EndCell()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.sent) != 1 || apiSent != 0 {
+		t.Fatal("message should be sent only through custom sender")
+	}
+	if !sender.sent[0].DstAddr.Equals(w.Address()) {
+		t.Fatal("incorrect message destination")
+	}
+
+	sub, err := w.GetSubwallet(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.sender != sender {
+		t.Fatal("sender should be inherited by subwallet")
+	}
+
+	w.SetMessageSender(nil)
+	if err = w.Send(context.Background(), &Message{
+		Mode:            PayGasSeparately,
+		InternalMessage: &tlb.InternalMessage{DstAddr: w.Address(), Amount: tlb.MustFromTON("0.1"), Body: cell.BeginCell().EndCell()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 1 || apiSent != 1 {
+		t.Fatal("message should be sent through api")
+	}
+
+	// confirmation is tracked through api, but message is sent only through sender
+	m.client = liteClientMock{}
+	m.getAccount = func(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error) {
+		acc := &tlb.Account{IsActive: true, State: &tlb.AccountState{IsValid: true, Address: addr,
+			AccountStorage: tlb.AccountStorage{Status: tlb.AccountStatusActive}}}
+		if len(sender.sent) > 2 {
+			acc.LastTxLT = 10
+			acc.LastTxHash = make([]byte, 32)
+		}
+		return acc, nil
+	}
+	m.listTransactions = func(ctx context.Context, addr *address.Address, limit uint32, lt uint64, txHash []byte) ([]*tlb.Transaction, error) {
+		tx := &tlb.Transaction{LT: 10}
+		tx.IO.In = &tlb.Message{MsgType: tlb.MsgTypeExternalIn, Msg: sender.sent[len(sender.sent)-1]}
+		return []*tlb.Transaction{tx}, nil
+	}
+
+	w.SetMessageSender(sender)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, _, err := w.SendWaitTransaction(ctx, &Message{
+		Mode:            PayGasSeparately,
+		InternalMessage: &tlb.InternalMessage{DstAddr: w.Address(), Amount: tlb.MustFromTON("0.1"), Body: cell.BeginCell().EndCell()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.LT != 10 {
+		t.Fatal("incorrect transaction")
+	}
+	if len(sender.sent) != 3 || apiSent != 1 {
+		t.Fatal("message should be sent and resent only through custom sender", len(sender.sent), apiSent)
+	}
+}
+
 func TestCreateEncryptedCommentCell(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		pub1, priv1, err := ed25519.GenerateKey(nil)
@@ -646,3 +765,119 @@ func TestCreateEncryptedCommentCell(t *testing.T) {
 		}
 	}
 }
+
+func TestWallet_PreprocessedV2(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Unix(1000000, 0)
+	}
+
+	pkey := ed25519.NewKeyFromSeed([]byte("12345678901234567890123456789012"))
+
+	m := &MockAPI{}
+	m.getBlockInfo = func(ctx context.Context) (*ton.BlockIDExt, error) {
+		return &ton.BlockIDExt{SeqNo: 2}, nil
+	}
+	m.getAccount = func(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error) {
+		return &tlb.Account{
+			IsActive: true,
+			State: &tlb.AccountState{
+				IsValid: true,
+				Address: addr,
+				AccountStorage: tlb.AccountStorage{
+					Status: tlb.AccountStatusActive,
+				},
+			},
+			Data: cell.BeginCell().
+				MustStoreSlice(pkey.Public().(ed25519.PublicKey), 256).
+				MustStoreUInt(7, 16).
+				EndCell(),
+		}, nil
+	}
+
+	w, err := FromPrivateKey(m, pkey, PreprocessedV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.GetSubwalletID() != 0 {
+		t.Fatal("subwallet should be 0")
+	}
+
+	if _, ok := w.GetSpec().(*SpecPreprocessedV2); !ok {
+		t.Fatal("incorrect spec")
+	}
+
+	msgs := make([]*Message, 255)
+	for i := range msgs {
+		msgs[i] = SimpleMessage(w.WalletAddress(), tlb.MustFromTON("0.1"), nil)
+	}
+
+	ext, err := w.BuildExternalMessageForMany(context.Background(), msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ext.StateInit != nil {
+		t.Fatal("state init should not be attached")
+	}
+
+	body := ext.Body.BeginParse()
+	sign := body.MustLoadSlice(512)
+	payload := body.MustLoadRef()
+
+	if !ed25519.Verify(pkey.Public().(ed25519.PublicKey), payload.MustToCell().Hash(), sign) {
+		t.Fatal("incorrect signature")
+	}
+
+	if payload.MustLoadUInt(64) != 1000000+60*3 {
+		t.Fatal("incorrect valid until")
+	}
+
+	if payload.MustLoadUInt(16) != 7 {
+		t.Fatal("incorrect seqno")
+	}
+
+	list := payload.MustLoadRef()
+	num := 0
+	for list.RefsNum() > 0 {
+		prev := list.MustLoadRef()
+		if list.MustLoadUInt(32) != 0x0ec3c86d {
+			t.Fatal("incorrect action")
+		}
+		if list.MustLoadUInt(8) != PayGasSeparately+IgnoreErrors {
+			t.Fatal("incorrect mode")
+		}
+		list = prev
+		num++
+	}
+
+	if num != 255 {
+		t.Fatal("incorrect actions num", num)
+	}
+
+	_, err = w.BuildExternalMessageForMany(context.Background(), append(msgs, msgs[0]))
+	if err == nil || !strings.Contains(err.Error(), "max 255 messages") {
+		t.Fatal("should fail on too many messages, but:", err)
+	}
+}
+
+func TestGetStateInit_PreprocessedV2(t *testing.T) {
+	pkey := ed25519.NewKeyFromSeed([]byte("12345678901234567890123456789012"))
+
+	state, err := GetStateInit(pkey.Public().(ed25519.PublicKey), PreprocessedV2, 123)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(state.Code.Hash(), walletCode[PreprocessedV2].Hash()) {
+		t.Fatal("incorrect code")
+	}
+
+	data := state.Data.BeginParse()
+	if !bytes.Equal(data.MustLoadSlice(256), pkey.Public().(ed25519.PublicKey)) {
+		t.Fatal("incorrect key")
+	}
+	if data.MustLoadUInt(16) != 0 || data.BitsLeft() != 0 {
+		t.Fatal("incorrect data")
+	}
+}